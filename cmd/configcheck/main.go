@@ -0,0 +1,67 @@
+// configcheck 按config.JSONSchema导出的JSON Schema校验配置文件,
+// 多个文件按顺序深度合并后再校验,与WithProfile的分层方式相同
+//
+// 用法示例:
+//
+//	configcheck -schema app.schema.json config.yaml
+//	configcheck -schema app.schema.json config.yaml config.prod.yaml
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Cospk/base-tools/config"
+	"github.com/Cospk/base-tools/errs"
+)
+
+// errInvalid 配置未通过校验,违规项已经输出
+var errInvalid = errors.New("config is invalid")
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if err != errInvalid {
+			fmt.Fprintln(os.Stderr, "configcheck:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("configcheck", flag.ContinueOnError)
+	schemaPath := fs.String("schema", "", "JSON Schema文件路径,通常由config.JSONSchema生成")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: configcheck -schema app.schema.json config.yaml [config.prod.yaml ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *schemaPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("-schema and at least one config file are required")
+	}
+
+	schema, err := config.LoadSchema(*schemaPath)
+	if err != nil {
+		return err
+	}
+	files := strings.Join(fs.Args(), " + ")
+	err = schema.ValidateFile(fs.Args()...)
+	var fe *errs.FieldErrors
+	if errors.As(err, &fe) {
+		fmt.Printf("%s: %d problem(s)\n", files, fe.Len())
+		for _, f := range fe.Fields() {
+			fmt.Printf("  %s: %s (%s)\n", f.Field, f.Msg, f.Rule)
+		}
+		return errInvalid
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: ok\n", files)
+	return nil
+}
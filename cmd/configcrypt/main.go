@@ -0,0 +1,170 @@
+// configcrypt 加密、解密和编辑*.enc配置文件,格式与config.EncryptConfig相同,
+// 密钥默认从环境变量CONFIG_ENC_KEY或CONFIG_ENC_KEY_FILE指定的文件读取
+//
+// 用法示例:
+//
+//	configcrypt encrypt config.yaml                # 生成config.yaml.enc
+//	configcrypt decrypt config.yaml.enc            # 输出明文到标准输出
+//	configcrypt decrypt -o config.yaml config.yaml.enc
+//	configcrypt edit -key-file app.key config.yaml.enc
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Cospk/base-tools/config"
+)
+
+const usage = `用法: configcrypt <command> [-key-file file] [-o output] <file>
+
+命令:
+  encrypt  加密配置文件,默认输出到<file>.enc
+  decrypt  解密配置文件,默认输出到标准输出
+  edit     解密到临时文件并用$EDITOR打开,保存后重新加密
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "configcrypt:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("command is required")
+	}
+	cmd := args[0]
+	if cmd != "encrypt" && cmd != "decrypt" && cmd != "edit" {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	fs := flag.NewFlagSet("configcrypt "+cmd, flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "密钥文件,未指定时使用环境变量"+config.EncKeyEnv+"或"+config.EncKeyFileEnv)
+	output := fs.String("o", "", "输出文件,\"-\"表示标准输出")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("exactly one file is required")
+	}
+	file := fs.Arg(0)
+
+	var key []byte
+	var err error
+	if *keyFile != "" {
+		key, err = config.ReadEncKeyFile(*keyFile)
+	} else {
+		key, err = config.LoadEncKey()
+	}
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "encrypt":
+		return encrypt(file, *output, key)
+	case "decrypt":
+		return decrypt(file, *output, key)
+	default:
+		return edit(file, key)
+	}
+}
+
+func encrypt(file, output string, key []byte) error {
+	plain, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if config.IsEncryptedConfig(plain) {
+		return fmt.Errorf("%s is already encrypted", file)
+	}
+	if output == "" {
+		output = file + config.EncryptedExt
+	}
+	if output == "-" {
+		data, err := config.EncryptConfig(plain, key)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return config.WriteEncryptedFile(output, plain, key)
+}
+
+func decrypt(file, output string, key []byte) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	plain, err := config.DecryptConfig(data, key)
+	if err != nil {
+		return err
+	}
+	if output == "" || output == "-" {
+		_, err = os.Stdout.Write(plain)
+		return err
+	}
+	return os.WriteFile(output, plain, 0o600)
+}
+
+// edit 解密到权限为0600的临时文件,编辑器退出后内容有变化才重新加密写回,临时文件总会被删除
+func edit(file string, key []byte) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	plain, err := config.DecryptConfig(data, key)
+	if err != nil {
+		return err
+	}
+
+	// 临时文件保留原扩展名,方便编辑器识别格式
+	ext := filepath.Ext(strings.TrimSuffix(file, config.EncryptedExt))
+	tmp, err := os.CreateTemp("", "configcrypt-*"+ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(plain)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	parts := strings.Fields(editor)
+	c := exec.Command(parts[0], append(parts[1:], tmp.Name())...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, plain) {
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+	return config.WriteEncryptedFile(file, edited, key)
+}
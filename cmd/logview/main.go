@@ -0,0 +1,112 @@
+// logview 查看ZapLogger输出的轮转日志:按时间合并多个文件、过滤并格式化输出,支持跟随当前日志文件
+//
+// 用法示例:
+//
+//	logview -pattern './logs/app.%Y-%m-%d' -level warn -op 1700000000000
+//	logview -pattern './logs/app.*' -since 1h -kv userID=42
+//	logview -link ./logs/app.log -follow -user u1
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Cospk/base-tools/log/logview"
+)
+
+// kvFlags 可重复的-kv参数
+type kvFlags []string
+
+func (f *kvFlags) String() string { return strings.Join(*f, ",") }
+
+func (f *kvFlags) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "logview:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("logview", flag.ContinueOnError)
+	var (
+		pattern  = fs.String("pattern", "", "rotatelogs文件名模式(如./logs/app.%Y-%m-%d)或glob模式")
+		link     = fs.String("link", "", "WithLinkName设置的符号链接路径,配合-follow使用")
+		follow   = fs.Bool("follow", false, "输出历史日志后持续跟随符号链接指向的当前文件")
+		level    = fs.String("level", "", "最低日志级别: debug|info|warn|error|panic|fatal")
+		opID     = fs.String("op", "", "按operationID过滤")
+		opUserID = fs.String("user", "", "按opUserID过滤")
+		since    = fs.String("since", "", "起始时间,支持\"2006-01-02 15:04:05\"、RFC3339或相对时长如1h")
+		until    = fs.String("until", "", "截止时间,格式同-since")
+		raw      = fs.Bool("raw", false, "输出原始日志行")
+		color    = fs.Bool("color", true, "输出带颜色的日志级别")
+		interval = fs.Duration("interval", logview.DefaultFollowInterval, "跟随模式下的轮询间隔")
+		kvs      kvFlags
+	)
+	fs.Var(&kvs, "kv", "按任意字段过滤,格式key=value,可重复")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *pattern == "" && *link == "" {
+		fs.Usage()
+		return fmt.Errorf("one of -pattern or -link is required")
+	}
+	if *follow && *link == "" {
+		return fmt.Errorf("-follow requires -link")
+	}
+
+	filter := &logview.Filter{OperationID: *opID, OpUserID: *opUserID}
+	var err error
+	if *level != "" {
+		if filter.MinLevel, err = logview.ParseLevel(*level); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	if filter.Since, err = logview.ParseTime(*since, now); err != nil {
+		return err
+	}
+	if filter.Until, err = logview.ParseTime(*until, now); err != nil {
+		return err
+	}
+	if filter.KV, err = logview.ParseKV(kvs); err != nil {
+		return err
+	}
+
+	emit := func(e *logview.Entry) error {
+		var err error
+		if *raw {
+			_, err = fmt.Fprintln(os.Stdout, e.Raw)
+		} else {
+			_, err = fmt.Fprintln(os.Stdout, logview.Format(e, *color))
+		}
+		return err
+	}
+
+	if *pattern != "" {
+		files, err := logview.Files(*pattern)
+		if err != nil {
+			return err
+		}
+		if err := logview.Merge(files, filter, emit); err != nil {
+			return err
+		}
+	}
+	if !*follow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return logview.Follow(ctx, *link, *interval, filter, emit)
+}
@@ -1,13 +1,20 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/log"
 	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -20,6 +27,31 @@ type ViperConfig struct {
 	envPrefix  string
 	// 配置变更回调函数
 	onChangeCallbacks []func()
+	// 热加载失败时的回调函数
+	onErrorCallbacks []func(error)
+	// 环境特定配置，如 prod 对应 config.prod.yaml
+	profile string
+	lists   ListStrategy
+	// 远程配置来源,按添加顺序合并到本地配置文件之上
+	providers      []Provider
+	remoteCacheDir string
+	// 保护以下配置层
+	layerMutex    sync.Mutex
+	fileLayers    []*mapLayer // 基础配置文件和环境特定配置文件
+	remoteContent [][]byte    // 每个远程来源最近一次的内容
+	localLoaded   bool        // 是否读取到了本地配置文件
+	// 用于 Explain 的来源记录
+	defaultKeys  map[string]struct{}
+	overrideKeys map[string]struct{}
+	envBindings  map[string][]string
+	flags        []*pflag.FlagSet
+	// 解析密钥引用的Secrets
+	secrets *Secrets
+	// 解密 *.enc 配置文件的密钥，以及已加载的加密基础配置文件
+	configKey []byte
+	encFile   string
+	// 加载前读入进程环境变量的 .env 文件
+	envFiles []string
 }
 
 // ViperOption 配置选项
@@ -53,6 +85,58 @@ func WithEnvPrefix(prefix string) ViperOption {
 	}
 }
 
+// WithRemoteProvider 添加远程配置来源（etcd、Consul KV或HTTP），内容格式与配置类型相同。
+// 远程配置深度合并到本地配置文件之上，后添加的来源优先级更高，环境变量仍然优先
+func WithRemoteProvider(p Provider) ViperOption {
+	return func(c *ViperConfig) {
+		c.providers = append(c.providers, p)
+	}
+}
+
+// WithRemoteCacheDir 设置远程配置的本地缓存目录，远程不可达时使用上一次成功获取的内容
+func WithRemoteCacheDir(dir string) ViperOption {
+	return func(c *ViperConfig) {
+		c.remoteCacheDir = dir
+	}
+}
+
+// WithProfile 设置运行环境，加载 config.yaml 后再深度合并同目录下的 config.<profile>.yaml（存在时）
+func WithProfile(profile string) ViperOption {
+	return func(c *ViperConfig) {
+		c.profile = profile
+	}
+}
+
+// WithListMergeStrategy 设置多层配置中同一列表的合并方式，默认 ListReplace
+func WithListMergeStrategy(s ListStrategy) ViperOption {
+	return func(c *ViperConfig) {
+		c.lists = s
+	}
+}
+
+// WithSecretResolvers 设置 Unmarshal 时解析 ${scheme:ref} 密钥引用的 Secrets，
+// 默认只支持 env 和 file，需要解密 ${enc:...} 时传入注册了 NewEncResolver 的 Secrets
+func WithSecretResolvers(s *Secrets) ViperOption {
+	return func(c *ViperConfig) {
+		c.secrets = s
+	}
+}
+
+// WithEnvFile 在加载配置前按顺序读取 .env 文件（不存在的文件会被忽略）并设置为进程的环境变量，
+// 使 WithEnvPrefix 能读到其中的值。进程中已经设置的变量不会被覆盖
+func WithEnvFile(files ...string) ViperOption {
+	return func(c *ViperConfig) {
+		c.envFiles = append(c.envFiles, files...)
+	}
+}
+
+// WithConfigKey 设置解密 *.enc 配置文件的密钥，未设置时使用 LoadEncKey 从环境变量或密钥文件读取
+func WithConfigKey(key []byte) ViperOption {
+	return func(c *ViperConfig) {
+		c.configKey = key
+	}
+}
+
 // NewViperConfig 创建新的 Viper 配置管理器
 func NewViperConfig(opts ...ViperOption) *ViperConfig {
 	vc := &ViperConfig{
@@ -61,6 +145,11 @@ func NewViperConfig(opts ...ViperOption) *ViperConfig {
 		configType: "yaml",
 		configPath: []string{"."},
 		envPrefix:  "",
+		secrets:    defaultSecrets,
+
+		defaultKeys:  make(map[string]struct{}),
+		overrideKeys: make(map[string]struct{}),
+		envBindings:  make(map[string][]string),
 	}
 
 	// 应用选项
@@ -87,32 +176,238 @@ func NewViperConfig(opts ...ViperOption) *ViperConfig {
 	return vc
 }
 
-// Load 加载配置文件
+// Load 加载配置文件，搜索路径中没有明文配置文件时查找加密的 <name>.<type>.enc
 func (vc *ViperConfig) Load() error {
+	if err := loadEnvFiles(vc.envFiles); err != nil {
+		return err
+	}
 	// 尝试读取配置文件
 	if err := vc.viper.ReadInConfig(); err != nil {
 		// 如果配置文件不存在，不一定是错误（可能只使用环境变量）
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return errs.WrapMsg(err, "读取配置文件失败")
 		}
+		for _, dir := range vc.configPath {
+			if file := filepath.Join(dir, vc.configName+"."+vc.configType+EncryptedExt); fileExists(file) {
+				return vc.LoadWithFile(file)
+			}
+		}
+	} else if err := vc.loadFiles(); err != nil {
+		return err
 	}
 
-	return nil
+	return vc.loadRemote()
 }
 
-// LoadWithFile 从指定文件加载配置
+// LoadWithFile 从指定文件加载配置，以 .enc 结尾的文件（如 app.yaml.enc）先解密，
+// 再按去掉 .enc 后的扩展名解析
 func (vc *ViperConfig) LoadWithFile(configFile string) error {
+	if err := loadEnvFiles(vc.envFiles); err != nil {
+		return err
+	}
+	if IsEncryptedFile(configFile) {
+		vc.layerMutex.Lock()
+		vc.encFile = configFile
+		err := vc.readEncFileNolock()
+		vc.layerMutex.Unlock()
+		if err != nil {
+			return err
+		}
+		if err := vc.loadFiles(); err != nil {
+			return err
+		}
+		return vc.loadRemote()
+	}
+
 	vc.viper.SetConfigFile(configFile)
 	if err := vc.viper.ReadInConfig(); err != nil {
 		return errs.WrapMsg(err, "读取配置文件失败", "file", configFile)
 	}
+	if err := vc.loadFiles(); err != nil {
+		return err
+	}
+
+	return vc.loadRemote()
+}
 
+// loadFiles 在基础配置文件读取成功后，记录其内容并合并环境特定配置文件
+func (vc *ViperConfig) loadFiles() error {
+	vc.layerMutex.Lock()
+	defer vc.layerMutex.Unlock()
+	vc.localLoaded = true
+	if err := vc.readFilesNolock(); err != nil {
+		return err
+	}
+	return vc.applyLayersNolock()
+}
+
+// readEncFileNolock 解密加密的基础配置文件并读入 Viper
+func (vc *ViperConfig) readEncFileNolock() error {
+	content, err := readConfigBytes(vc.encFile, vc.configKey)
+	if err != nil {
+		return err
+	}
+	if ext := strings.TrimPrefix(plainExt(vc.encFile), "."); ext != "" {
+		vc.configType = ext
+		vc.viper.SetConfigType(ext)
+	}
+	if err := vc.viper.ReadConfig(bytes.NewReader(content)); err != nil {
+		return errs.WrapMsg(err, "解析配置文件失败", "file", vc.encFile)
+	}
 	return nil
 }
 
-// Unmarshal 将配置解析到结构体
+// readFilesNolock 读取基础配置文件和环境特定配置文件的内容
+func (vc *ViperConfig) readFilesNolock() error {
+	base := vc.GetConfigFile()
+	files := []string{base}
+	if vc.profile != "" {
+		files = ProfileFiles(base, vc.profile)
+	}
+	layers := make([]*mapLayer, 0, len(files))
+	for _, file := range files {
+		content, err := readConfigBytes(file, vc.configKey)
+		if err != nil {
+			return err
+		}
+		data, err := vc.parseSettings(content)
+		if err != nil {
+			return errs.WrapMsg(err, "解析配置文件失败", "file", file)
+		}
+		layers = append(layers, &mapLayer{source: Source{Kind: SourceFile, Name: file}, data: data})
+	}
+	vc.fileLayers = layers
+	return nil
+}
+
+// parseSettings 按配置类型解析内容，支持 Viper 支持的所有格式
+func (vc *ViperConfig) parseSettings(content []byte) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigType(vc.configType)
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// loadRemote 获取所有远程配置并合并，远程不可达且没有缓存时返回错误
+func (vc *ViperConfig) loadRemote() error {
+	if len(vc.providers) == 0 {
+		return nil
+	}
+	cache := remoteCache{dir: vc.remoteCacheDir}
+	contents := make([][]byte, len(vc.providers))
+	for i, p := range vc.providers {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteFetchTimeout)
+		content, err := cache.fetch(ctx, p)
+		cancel()
+		if err != nil {
+			return err
+		}
+		contents[i] = content
+	}
+
+	vc.layerMutex.Lock()
+	defer vc.layerMutex.Unlock()
+	vc.remoteContent = contents
+	return vc.applyLayersNolock()
+}
+
+// layersNolock 返回按优先级从低到高排列的配置文件和远程配置
+func (vc *ViperConfig) layersNolock() ([]*mapLayer, error) {
+	layers := append([]*mapLayer(nil), vc.fileLayers...)
+	for i, content := range vc.remoteContent {
+		if content == nil {
+			continue
+		}
+		data, err := vc.parseSettings(content)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "解析远程配置失败", "provider", vc.providers[i].Name())
+		}
+		layers = append(layers, &mapLayer{source: Source{Kind: SourceRemote, Name: vc.providers[i].Name()}, data: data})
+	}
+	return layers, nil
+}
+
+// applyLayersNolock 将所有配置层深度合并后覆盖到 Viper 的配置层之上，列表按合并策略处理
+func (vc *ViperConfig) applyLayersNolock() error {
+	layers, err := vc.layersNolock()
+	if err != nil {
+		return err
+	}
+	if len(layers) == 0 {
+		return nil
+	}
+	merged := map[string]any{}
+	for _, l := range layers {
+		merged = DeepMerge(merged, l.data, vc.lists)
+	}
+	if err := vc.viper.MergeConfigMap(merged); err != nil {
+		return errs.WrapMsg(err, "合并配置失败")
+	}
+	return nil
+}
+
+// rebuildNolock 从本地配置文件和所有远程配置重新构建配置，
+// 没有本地配置文件时以第一个远程配置为基础
+func (vc *ViperConfig) rebuildNolock() error {
+	if vc.localLoaded && vc.encFile != "" {
+		if err := vc.readEncFileNolock(); err != nil {
+			return err
+		}
+	} else if vc.localLoaded {
+		if err := vc.viper.ReadInConfig(); err != nil {
+			return errs.WrapMsg(err, "读取配置文件失败")
+		}
+	} else if err := vc.viper.ReadConfig(bytes.NewReader(vc.remoteContent[0])); err != nil {
+		return errs.WrapMsg(err, "解析远程配置失败", "provider", vc.providers[0].Name())
+	}
+	return vc.applyLayersNolock()
+}
+
+// WatchRemote 监听所有远程配置来源，直到ctx结束。
+// 内容变化时重新合并配置、更新本地缓存并调用OnConfigChange注册的回调；
+// 新内容无法解析时保留原配置并调用OnError注册的回调。与WatchConfig相同，更新与读取之间不加锁，
+// 需要一致的配置快照时使用NewViperWatcher
+func (vc *ViperConfig) WatchRemote(ctx context.Context) {
+	cache := remoteCache{dir: vc.remoteCacheDir}
+	for i, p := range vc.providers {
+		go func(i int, p Provider) {
+			_ = p.Watch(ctx, func(content []byte) {
+				vc.layerMutex.Lock()
+				if len(vc.remoteContent) != len(vc.providers) {
+					vc.remoteContent = make([][]byte, len(vc.providers))
+				}
+				old := vc.remoteContent[i]
+				vc.remoteContent[i] = content
+				if err := vc.rebuildNolock(); err != nil {
+					vc.remoteContent[i] = old
+					_ = vc.rebuildNolock()
+					vc.layerMutex.Unlock()
+					vc.notifyError(errs.WrapMsg(err, "远程配置更新失败,继续使用原配置", "provider", p.Name()))
+					return
+				}
+				vc.layerMutex.Unlock()
+
+				cache.store(p, content)
+				vc.notifyChange()
+			})
+		}(i, p)
+	}
+}
+
+// decodeHook 在 Viper 默认的转换之前解析密钥引用
+func (vc *ViperConfig) decodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		vc.secrets.decodeHook(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+}
+
+// Unmarshal 将配置解析到结构体，同时解析 ${env:NAME}、${file:PATH} 等密钥引用
 func (vc *ViperConfig) Unmarshal(config interface{}) error {
-	if err := vc.viper.Unmarshal(config); err != nil {
+	if err := vc.viper.Unmarshal(config, vc.decodeHook()); err != nil {
 		return errs.WrapMsg(err, "解析配置到结构体失败")
 	}
 	return nil
@@ -120,7 +415,7 @@ func (vc *ViperConfig) Unmarshal(config interface{}) error {
 
 // UnmarshalKey 将指定键的配置解析到结构体
 func (vc *ViperConfig) UnmarshalKey(key string, rawVal interface{}) error {
-	if err := vc.viper.UnmarshalKey(key, rawVal); err != nil {
+	if err := vc.viper.UnmarshalKey(key, rawVal, vc.decodeHook()); err != nil {
 		return errs.WrapMsg(err, "解析配置键失败", "key", key)
 	}
 	return nil
@@ -166,14 +461,20 @@ func (vc *ViperConfig) IsSet(key string) bool {
 	return vc.viper.IsSet(key)
 }
 
-// Set 设置配置值
+// Set 设置配置值，优先级最高
 func (vc *ViperConfig) Set(key string, value interface{}) {
 	vc.viper.Set(key, value)
+	vc.layerMutex.Lock()
+	vc.overrideKeys[strings.ToLower(key)] = struct{}{}
+	vc.layerMutex.Unlock()
 }
 
 // SetDefault 设置默认值
 func (vc *ViperConfig) SetDefault(key string, value interface{}) {
 	vc.viper.SetDefault(key, value)
+	vc.layerMutex.Lock()
+	vc.defaultKeys[strings.ToLower(key)] = struct{}{}
+	vc.layerMutex.Unlock()
 }
 
 // BindEnv 绑定环境变量到配置键
@@ -181,35 +482,77 @@ func (vc *ViperConfig) BindEnv(key string, envVars ...string) error {
 	if err := vc.viper.BindEnv(append([]string{key}, envVars...)...); err != nil {
 		return errs.WrapMsg(err, "绑定环境变量失败", "key", key)
 	}
+	vc.layerMutex.Lock()
+	vc.envBindings[strings.ToLower(key)] = envVars
+	vc.layerMutex.Unlock()
 	return nil
 }
 
 // WatchConfig 监听配置文件变化
 func (vc *ViperConfig) WatchConfig() {
+	if vc.encFile != "" {
+		vc.watchEncFile()
+		return
+	}
+	vc.viper.OnConfigChange(vc.onFileChange)
 	vc.viper.WatchConfig()
 }
 
-// OnConfigChange 注册配置变更回调
+// OnConfigChange 注册配置变更回调，配置文件或远程配置变化时调用
 func (vc *ViperConfig) OnConfigChange(fn func()) {
 	vc.onChangeCallbacks = append(vc.onChangeCallbacks, fn)
-	vc.viper.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Printf("配置文件已更新: %s\n", e.Name)
-		for _, callback := range vc.onChangeCallbacks {
-			callback()
-		}
-	})
+	vc.viper.OnConfigChange(vc.onFileChange)
+}
+
+// onFileChange 基础配置文件被重新读取后，环境特定配置和远程配置需要重新合并到其上
+func (vc *ViperConfig) onFileChange(e fsnotify.Event) {
+	vc.layerMutex.Lock()
+	if err := vc.readFilesNolock(); err == nil {
+		_ = vc.applyLayersNolock()
+	}
+	vc.layerMutex.Unlock()
+
+	log.ZInfo(context.Background(), "配置文件已更新", "file", e.Name)
+	vc.notifyChange()
+}
+
+func (vc *ViperConfig) notifyChange() {
+	for _, callback := range vc.onChangeCallbacks {
+		callback()
+	}
+}
+
+// OnError 注册热加载失败时的回调，此时仍在使用原配置。没有注册回调时通过 log 输出警告
+func (vc *ViperConfig) OnError(fn func(error)) {
+	vc.onErrorCallbacks = append(vc.onErrorCallbacks, fn)
 }
 
-// WriteConfig 将当前配置写入文件
+func (vc *ViperConfig) notifyError(err error) {
+	if len(vc.onErrorCallbacks) == 0 {
+		log.ZWarn(context.Background(), "配置热加载失败", err)
+		return
+	}
+	for _, callback := range vc.onErrorCallbacks {
+		callback(err)
+	}
+}
+
+// WriteConfig 将当前配置写入文件，从加密文件加载时重新加密后写回
 func (vc *ViperConfig) WriteConfig() error {
+	if vc.encFile != "" {
+		return vc.writeEncFile(vc.encFile)
+	}
 	if err := vc.viper.WriteConfig(); err != nil {
 		return errs.WrapMsg(err, "写入配置文件失败")
 	}
 	return nil
 }
 
-// WriteConfigAs 将配置写入指定文件
+// WriteConfigAs 将配置写入指定文件，以 .enc 结尾时加密后写入
 func (vc *ViperConfig) WriteConfigAs(filename string) error {
+	if IsEncryptedFile(filename) {
+		return vc.writeEncFile(filename)
+	}
 	if err := vc.viper.WriteConfigAs(filename); err != nil {
 		return errs.WrapMsg(err, "写入配置文件失败", "file", filename)
 	}
@@ -218,12 +561,15 @@ func (vc *ViperConfig) WriteConfigAs(filename string) error {
 
 // GetConfigFile 获取使用的配置文件路径
 func (vc *ViperConfig) GetConfigFile() string {
+	if vc.encFile != "" {
+		return vc.encFile
+	}
 	return vc.viper.ConfigFileUsed()
 }
 
-// AllSettings 获取所有配置
+// AllSettings 获取所有配置，密钥引用和 password、token 等敏感键的值显示为 "******"
 func (vc *ViperConfig) AllSettings() map[string]interface{} {
-	return vc.viper.AllSettings()
+	return vc.secrets.maskSettings(vc.viper.AllSettings())
 }
 
 // Debug 打印配置调试信息
@@ -233,6 +579,94 @@ func (vc *ViperConfig) Debug() {
 	for k, v := range vc.AllSettings() {
 		fmt.Printf("  %s: %v\n", k, v)
 	}
+	fmt.Printf("配置来源:\n")
+	sources := vc.ExplainAll()
+	keys := make([]string, 0, len(sources))
+	for k := range sources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %s: %s\n", k, sources[k])
+	}
+}
+
+// Explain 返回配置键当前生效值的来源，按优先级从高到低依次为：
+// Set > 命令行参数 > 环境变量 > 远程配置 > 环境特定配置文件 > 配置文件 > 默认值。
+// 列表按 ListAppend 等策略合并时返回提供了元素的最高优先级来源
+func (vc *ViperConfig) Explain(key string) (Source, bool) {
+	vc.layerMutex.Lock()
+	defer vc.layerMutex.Unlock()
+	layers, _ := vc.layersNolock()
+	return vc.explainNolock(strings.ToLower(key), layers)
+}
+
+// ExplainAll 返回所有已设置的配置键（如 "server.port"）的来源
+func (vc *ViperConfig) ExplainAll() map[string]Source {
+	keys := vc.viper.AllKeys()
+	vc.layerMutex.Lock()
+	defer vc.layerMutex.Unlock()
+	layers, _ := vc.layersNolock()
+	sources := make(map[string]Source, len(keys))
+	for _, key := range keys {
+		if src, ok := vc.explainNolock(key, layers); ok {
+			sources[key] = src
+		}
+	}
+	return sources
+}
+
+func (vc *ViperConfig) explainNolock(key string, layers []*mapLayer) (Source, bool) {
+	if hasKeyOrParent(vc.overrideKeys, key) {
+		return Source{Kind: SourceOverride}, true
+	}
+	if name, ok := vc.lookupFlagNolock(key); ok {
+		return Source{Kind: SourceFlag, Name: name}, true
+	}
+	if name, ok := vc.lookupEnvNolock(key); ok {
+		return Source{Kind: SourceEnv, Name: name}, true
+	}
+	path := strings.Split(key, ".")
+	for i := len(layers) - 1; i >= 0; i-- {
+		if _, src, ok := layers[i].lookup(path); ok {
+			return src, true
+		}
+	}
+	if hasKeyOrParent(vc.defaultKeys, key) || vc.hasFlagNolock(key) {
+		return Source{Kind: SourceDefault}, true
+	}
+	return Source{}, false
+}
+
+// lookupEnvNolock 按 Viper 的规则查找为配置键提供值的环境变量，空值视为未设置
+func (vc *ViperConfig) lookupEnvNolock(key string) (string, bool) {
+	names, bound := vc.envBindings[key]
+	if !bound || len(names) == 0 {
+		if !bound && vc.envPrefix == "" {
+			return "", false
+		}
+		names = []string{envName(vc.envPrefix, strings.Split(key, "."))}
+	}
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// hasKeyOrParent 判断键本身或其上级键（如 "server" 之于 "server.port"）是否在集合中
+func hasKeyOrParent(keys map[string]struct{}, key string) bool {
+	for {
+		if _, ok := keys[key]; ok {
+			return true
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return false
+		}
+		key = key[:i]
+	}
 }
 
 // GlobalConfig 全局配置实例
@@ -263,8 +697,8 @@ func QuickLoad(configFile string, config interface{}, envPrefix string) error {
 		// 从文件路径提取配置信息
 		dir := filepath.Dir(configFile)
 		base := filepath.Base(configFile)
-		ext := filepath.Ext(base)
-		name := strings.TrimSuffix(base, ext)
+		ext := plainExt(base)
+		name := strings.TrimSuffix(strings.TrimSuffix(base, EncryptedExt), ext)
 		
 		if ext != "" {
 			ext = strings.TrimPrefix(ext, ".")
@@ -305,13 +739,12 @@ func QuickLoad(configFile string, config interface{}, envPrefix string) error {
 	return vc.Unmarshal(config)
 }
 
-// MergeConfig 合并多个配置源
+// MergeConfig 深度合并两个配置：按叶子键（如 "database.port"）逐个比较，
+// primary 中尚未设置的键使用 secondary 的值，作为 primary 的默认值加入，不会覆盖 primary 的任何来源
 func MergeConfig(primary, secondary *ViperConfig) error {
-	// 获取次要配置的所有设置
-	for key, value := range secondary.AllSettings() {
-		// 如果主配置中没有设置该键，则使用次要配置的值
+	for _, key := range secondary.viper.AllKeys() {
 		if !primary.IsSet(key) {
-			primary.Set(key, value)
+			primary.SetDefault(key, secondary.viper.Get(key))
 		}
 	}
 	return nil
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/internal/conv"
+)
+
+var (
+	durationType        = conv.DurationType
+	timeType            = conv.TimeType
+	urlType             = conv.URLType
+	textUnmarshalerType = conv.TextUnmarshalerType
+)
+
+// decoder 将多层配置来源解析到结构体,记录每个键的来源并收集错误
+type decoder struct {
+	layers  []layer // 按优先级从低到高排列
+	secrets *Secrets
+	lists   ListStrategy
+	sources map[string]Source
+	errors  *errs.FieldErrors
+}
+
+// lookup 从优先级最高的来源开始查找配置键
+func (d *decoder) lookup(path []string) (any, Source, bool) {
+	for i := len(d.layers) - 1; i >= 0; i-- {
+		if v, src, ok := d.layers[i].lookup(path); ok {
+			return v, src, true
+		}
+	}
+	return nil, Source{}, false
+}
+
+// lookupList 按列表合并策略合并所有来源中的列表,非ListReplace时使用
+func (d *decoder) lookupList(path []string) []any {
+	var out []any
+	for _, l := range d.layers {
+		if v, _, ok := l.lookup(path); ok {
+			if list, ok := v.([]any); ok {
+				out = mergeLists(out, list, d.lists)
+			}
+		}
+	}
+	return out
+}
+
+// decodeStruct 按字段逐个查找并设置值,返回是否设置了任何字段
+func (d *decoder) decodeStruct(v reflect.Value, path []string) bool {
+	set := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := fieldKey(sf)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if squash {
+			set = d.decodeStruct(fv, path) || set
+			continue
+		}
+		fieldPath := append(append([]string(nil), path...), name)
+
+		if isNestedStruct(sf.Type) {
+			if sf.Type.Kind() == reflect.Pointer {
+				// 只有当子字段有值时才分配指针
+				nv := reflect.New(sf.Type.Elem())
+				if fv.IsNil() {
+					if d.decodeStruct(nv.Elem(), fieldPath) {
+						fv.Set(nv)
+						set = true
+					}
+					continue
+				}
+				fv = fv.Elem()
+			}
+			set = d.decodeStruct(fv, fieldPath) || set
+			continue
+		}
+
+		key := strings.Join(fieldPath, ".")
+		raw, src, found := d.lookup(fieldPath)
+		if !found {
+			def, ok := sf.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			raw, src = def, Source{Kind: SourceDefault}
+		} else if _, ok := raw.([]any); ok && d.lists != ListReplace {
+			raw = d.lookupList(fieldPath)
+		}
+		raw, err := d.secrets.resolveValue(raw)
+		if err != nil {
+			d.errors.Add(key, "secret", fmt.Sprintf("%s (from %s)", errs.Unwrap(err).Error(), src))
+			continue
+		}
+		if err := setValue(fv, raw); err != nil {
+			d.errors.Add(key, "type", fmt.Sprintf("%s (from %s)", err.Error(), src))
+			continue
+		}
+		d.sources[key] = src
+		set = true
+	}
+	return set
+}
+
+// fieldKey 返回字段对应的配置键名(取mapstructure标签,没有标签时使用小写字段名),
+// squash表示内嵌结构体的字段展开到当前层级,ok为false表示跳过该字段
+func fieldKey(sf reflect.StructField) (name string, squash bool, ok bool) {
+	if !sf.IsExported() {
+		return "", false, false
+	}
+	tag := sf.Tag.Get("mapstructure")
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return "", false, false
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "squash" {
+			return "", true, true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name, false, true
+}
+
+// isNestedStruct 判断字段是否为需要逐字段展开的结构体(time.Time、url.URL等可整体解析的类型除外)
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !conv.IsScalar(t)
+}
+
+// setValue 将配置值转换为目标类型并设置,转换规则见conv.SetValue。
+// 切片和map中的结构体元素通过decodeMap解析
+func setValue(v reflect.Value, raw any) error {
+	return conv.SetValue(v, raw, &conv.Options{DecodeStruct: decodeMap})
+}
+
+// decodeMap 将map解析到结构体,用于切片和map中的结构体元素,同样会应用default标签
+func decodeMap(v reflect.Value, m map[string]any) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := fieldKey(sf)
+		if !ok {
+			continue
+		}
+		if squash {
+			if err := decodeMap(v.Field(i), m); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, found := mapIndex(m, name)
+		if !found || raw == nil {
+			def, ok := sf.Tag.Lookup("default")
+			if !ok || isNestedStruct(sf.Type) {
+				continue
+			}
+			raw = def
+		}
+		if err := setValue(v.Field(i), raw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/fsnotify/fsnotify"
+)
+
+// 加密配置文件相关的约定
+const (
+	EncryptedExt  = ".enc"                // 加密配置文件的扩展名,如app.yaml.enc
+	EncKeyEnv     = "CONFIG_ENC_KEY"      // 存放密钥的环境变量
+	EncKeyFileEnv = "CONFIG_ENC_KEY_FILE" // 存放密钥文件路径的环境变量
+)
+
+// encHeader 加密配置文件的首行,之后为base64编码的nonce和密文
+const encHeader = "BASE-TOOLS-ENC;v1;AES256-GCM\n"
+
+// encLineWidth 密文每行的字符数,便于在版本库中比较
+const encLineWidth = 76
+
+// ErrEncKeyNotFound 读取加密配置文件时没有可用的密钥
+var ErrEncKeyNotFound = errs.ErrArgs.WrapMsg("config encryption key not found, set " + EncKeyEnv + " or " + EncKeyFileEnv)
+
+// IsEncryptedFile 判断路径是否为加密配置文件
+func IsEncryptedFile(path string) bool {
+	return strings.HasSuffix(path, EncryptedExt)
+}
+
+// plainExt 返回配置文件内容的扩展名,加密文件返回去掉.enc后的扩展名,如app.yaml.enc返回".yaml"
+func plainExt(path string) string {
+	return filepath.Ext(strings.TrimSuffix(path, EncryptedExt))
+}
+
+// LoadEncKey 依次从环境变量CONFIG_ENC_KEY和CONFIG_ENC_KEY_FILE指定的文件读取密钥,
+// 文件末尾的换行会被去掉。都没有设置时返回ErrEncKeyNotFound
+func LoadEncKey() ([]byte, error) {
+	if key := os.Getenv(EncKeyEnv); key != "" {
+		return []byte(key), nil
+	}
+	if path := os.Getenv(EncKeyFileEnv); path != "" {
+		return ReadEncKeyFile(path)
+	}
+	return nil, ErrEncKeyNotFound
+}
+
+// ReadEncKeyFile 从文件读取密钥,去掉末尾的换行
+func ReadEncKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "读取密钥文件失败", "file", path)
+	}
+	key := bytes.TrimRight(b, "\r\n")
+	if len(key) == 0 {
+		return nil, errs.ErrArgs.WrapMsg("config encryption key file is empty", "file", path)
+	}
+	return key, nil
+}
+
+// newGCM 由任意长度的密钥经SHA-256得到AES-256密钥
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, ErrEncKeyNotFound
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, errs.WrapMsg(err, "NewCipher failed")
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptConfig 使用AES-256-GCM加密配置内容,key为任意长度的密钥(经SHA-256派生)。
+// 结果为带文件头的文本,可以直接写入*.enc文件
+func EncryptConfig(plain, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errs.WrapMsg(err, "生成nonce失败")
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, []byte(encHeader))
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+
+	var buf bytes.Buffer
+	buf.WriteString(encHeader)
+	for len(encoded) > 0 {
+		n := min(encLineWidth, len(encoded))
+		buf.WriteString(encoded[:n])
+		buf.WriteByte('\n')
+		encoded = encoded[n:]
+	}
+	return buf.Bytes(), nil
+}
+
+// DecryptConfig 解密EncryptConfig的结果,密钥错误或内容被篡改时返回错误
+func DecryptConfig(data, key []byte) ([]byte, error) {
+	if !IsEncryptedConfig(data) {
+		return nil, errs.ErrArgs.WrapMsg("not an encrypted config")
+	}
+	body := strings.Join(strings.Fields(string(data[len(encHeader):])), "")
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "加密配置不是有效的base64")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errs.ErrArgs.WrapMsg("encrypted config is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, []byte(encHeader))
+	if err != nil {
+		return nil, errs.WrapMsg(err, "解密配置失败,密钥错误或文件已损坏")
+	}
+	return plain, nil
+}
+
+// IsEncryptedConfig 判断内容是否为EncryptConfig的结果
+func IsEncryptedConfig(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encHeader))
+}
+
+// readConfigBytes 读取配置文件内容,*.enc文件使用key解密,key为空时调用LoadEncKey
+func readConfigBytes(path string, key []byte) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "读取配置文件失败", "file", path)
+	}
+	if !IsEncryptedFile(path) {
+		return content, nil
+	}
+	if len(key) == 0 {
+		if key, err = LoadEncKey(); err != nil {
+			return nil, err
+		}
+	}
+	plain, err := DecryptConfig(content, key)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "解密配置文件失败", "file", path)
+	}
+	return plain, nil
+}
+
+// WriteEncryptedFile 加密配置内容后原子地写入文件,权限为0600
+func WriteEncryptedFile(path string, plain, key []byte) error {
+	data, err := EncryptConfig(plain, key)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return errs.WrapMsg(err, "写入配置文件失败", "file", path)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errs.WrapMsg(err, "写入配置文件失败", "file", path)
+	}
+	return nil
+}
+
+// writeEncFile 将当前配置按配置类型序列化并加密写入文件
+func (vc *ViperConfig) writeEncFile(path string) error {
+	key := vc.configKey
+	if len(key) == 0 {
+		var err error
+		if key, err = LoadEncKey(); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if err := vc.viper.WriteConfigTo(&buf); err != nil {
+		return errs.WrapMsg(err, "写入配置文件失败", "file", path)
+	}
+	return WriteEncryptedFile(path, buf.Bytes(), key)
+}
+
+// watchEncFile 监听加密的基础配置文件所在目录,文件变化时重新解密并合并各配置层
+func (vc *ViperConfig) watchEncFile() {
+	abs, err := filepath.Abs(vc.encFile)
+	if err != nil {
+		vc.notifyError(errs.WrapMsg(err, "监听配置文件失败", "file", vc.encFile))
+		return
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		vc.notifyError(errs.WrapMsg(err, "监听配置文件失败", "file", vc.encFile))
+		return
+	}
+	if err := fsw.Add(filepath.Dir(abs)); err != nil {
+		fsw.Close()
+		vc.notifyError(errs.WrapMsg(err, "监听配置文件失败", "file", vc.encFile))
+		return
+	}
+	go func() {
+		defer fsw.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case e, ok := <-fsw.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(e.Name) != abs || e.Op == fsnotify.Chmod {
+					continue
+				}
+				if timer == nil {
+					timer = time.AfterFunc(watchDebounce, func() { vc.reloadEncFile(e) })
+				} else {
+					timer.Reset(watchDebounce)
+				}
+			case _, ok := <-fsw.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// reloadEncFile 重新读取加密的基础配置文件,解密或解析失败时保留原配置并调用OnError注册的回调
+func (vc *ViperConfig) reloadEncFile(e fsnotify.Event) {
+	vc.layerMutex.Lock()
+	err := vc.readEncFileNolock()
+	vc.layerMutex.Unlock()
+	if err != nil {
+		vc.notifyError(errs.WrapMsg(err, "配置文件更新失败,继续使用原配置", "file", vc.encFile))
+		return
+	}
+	vc.onFileChange(e)
+}
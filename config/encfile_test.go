@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestEncryptConfig 测试配置内容的加解密
+func TestEncryptConfig(t *testing.T) {
+	plain := []byte("server:\n  port: 9090\n")
+	key := []byte("my-secret-key")
+
+	data, err := EncryptConfig(plain, key)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if !IsEncryptedConfig(data) || bytes.Contains(data, []byte("9090")) {
+		t.Errorf("加密结果不正确: %s", data)
+	}
+	got, err := DecryptConfig(data, key)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("解密结果不正确: %s, %v", got, err)
+	}
+	if _, err := DecryptConfig(data, []byte("wrong")); err == nil {
+		t.Error("密钥错误时应该返回错误")
+	}
+	data[len(data)-5] ^= 1
+	if _, err := DecryptConfig(data, key); err == nil {
+		t.Error("内容被篡改时应该返回错误")
+	}
+	if _, err := DecryptConfig(plain, key); err == nil {
+		t.Error("明文内容应该返回错误")
+	}
+}
+
+// TestLoadEncKey 测试从环境变量和密钥文件读取密钥
+func TestLoadEncKey(t *testing.T) {
+	t.Setenv(EncKeyEnv, "")
+	t.Setenv(EncKeyFileEnv, "")
+	if _, err := LoadEncKey(); err == nil {
+		t.Error("未设置密钥时应该返回错误")
+	}
+
+	file := filepath.Join(t.TempDir(), "config.key")
+	writeFile(t, file, "from-file\n")
+	t.Setenv(EncKeyFileEnv, file)
+	if key, err := LoadEncKey(); err != nil || string(key) != "from-file" {
+		t.Errorf("期望从密钥文件读取 from-file, 实际为 %q, %v", key, err)
+	}
+
+	t.Setenv(EncKeyEnv, "from-env")
+	if key, err := LoadEncKey(); err != nil || string(key) != "from-env" {
+		t.Errorf("环境变量应该优先, 实际为 %q, %v", key, err)
+	}
+}
+
+// TestQuickLoadEncrypted 测试QuickLoad对明文和加密配置文件的行为一致
+func TestQuickLoadEncrypted(t *testing.T) {
+	t.Setenv(EncKeyEnv, "quick-load-key")
+	dir := t.TempDir()
+	content := "server:\n  host: enc-host\n  port: 9090\ndatabase:\n  dsn: root@tcp\n"
+	plainFile := filepath.Join(dir, "app.yaml")
+	writeFile(t, plainFile, content)
+	encFile := filepath.Join(dir, "app.yaml.enc")
+	if err := WriteEncryptedFile(encFile, []byte(content), []byte("quick-load-key")); err != nil {
+		t.Fatal(err)
+	}
+
+	var plainCfg, encCfg ExampleConfig
+	if err := QuickLoad(plainFile, &plainCfg, ""); err != nil {
+		t.Fatalf("加载明文配置失败: %v", err)
+	}
+	if err := QuickLoad(encFile, &encCfg, ""); err != nil {
+		t.Fatalf("加载加密配置失败: %v", err)
+	}
+	if encCfg != plainCfg || encCfg.Server.Host != "enc-host" {
+		t.Errorf("加密配置解析结果不一致: %+v, %+v", encCfg, plainCfg)
+	}
+
+	t.Setenv(EncKeyEnv, "")
+	if err := QuickLoad(encFile, &encCfg, ""); err == nil {
+		t.Error("没有密钥时应该返回错误")
+	}
+}
+
+// TestViperEncryptedFile 测试搜索加密配置文件、环境特定配置、写回和监听
+func TestViperEncryptedFile(t *testing.T) {
+	key := []byte("viper-key")
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.yaml.enc")
+	if err := WriteEncryptedFile(base, []byte("server:\n  host: localhost\n  port: 8080\ndatabase:\n  dsn: root@tcp\n"), key); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteEncryptedFile(ProfileFile(base, "prod"), []byte("server:\n  port: 9090\n"), key); err != nil {
+		t.Fatal(err)
+	}
+	if ProfileFile(base, "prod") != filepath.Join(dir, "app.prod.yaml.enc") {
+		t.Errorf("加密文件的环境特定配置路径错误: %s", ProfileFile(base, "prod"))
+	}
+
+	vc := NewViperConfig(WithConfigName("app"), WithConfigPath(dir), WithProfile("prod"), WithConfigKey(key))
+	if err := vc.Load(); err != nil {
+		t.Fatalf("加载加密配置失败: %v", err)
+	}
+	if vc.GetConfigFile() != base || vc.GetString("server.host") != "localhost" || vc.GetInt("server.port") != 9090 {
+		t.Errorf("加密配置加载错误: %s %v", vc.GetConfigFile(), vc.AllSettings())
+	}
+	if src, _ := vc.Explain("server.port"); src.Name != ProfileFile(base, "prod") {
+		t.Errorf("来源说明错误: %s", src)
+	}
+
+	out := filepath.Join(dir, "out.yaml.enc")
+	if err := vc.WriteConfigAs(out); err != nil {
+		t.Fatalf("写入加密配置失败: %v", err)
+	}
+	res, err := Load[ExampleConfig](WithFile(out), WithFileKey(key))
+	if err != nil || res.Config.Server.Port != 9090 {
+		t.Errorf("读取写入的加密配置失败: %v", err)
+	}
+
+	changed := make(chan int, 1)
+	vc.OnConfigChange(func() {
+		select {
+		case changed <- vc.GetInt("server.port"):
+		default:
+		}
+	})
+	vc.WatchConfig()
+	if err := WriteEncryptedFile(ProfileFile(base, "prod"), []byte("server:\n  port: 7070\n"), key); err != nil {
+		t.Fatal(err)
+	}
+	// 只监听基础配置文件,修改后重新合并环境特定配置
+	if err := WriteEncryptedFile(base, []byte("server:\n  host: localhost\n  port: 8080\n"), key); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case port := <-changed:
+		if port != 7070 {
+			t.Errorf("期望重新加载后端口为 7070, 实际为 %d", port)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("修改加密配置文件后应该触发回调")
+	}
+
+	// 无法解密时保留原配置并通知错误回调
+	errors := make(chan error, 1)
+	vc.OnError(func(err error) {
+		select {
+		case errors <- err:
+		default:
+		}
+	})
+	if err := WriteEncryptedFile(base, []byte("server:\n  port: 1\n"), []byte("wrong-key")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errors:
+		if vc.GetInt("server.port") != 7070 {
+			t.Errorf("解密失败时应该保留原配置, 实际端口为 %d", vc.GetInt("server.port"))
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("无法解密的配置文件应该通知错误回调")
+	}
+}
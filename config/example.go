@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 )
 
 // ExampleConfig 示例配置结构体
@@ -14,30 +16,30 @@ type ExampleConfig struct {
 }
 
 type ServerConfig struct {
-	Host    string `mapstructure:"host"`
-	Port    int    `mapstructure:"port"`
-	Timeout int    `mapstructure:"timeout"`
+	Host    string `mapstructure:"host" default:"0.0.0.0" usage:"监听地址"`
+	Port    int    `mapstructure:"port" default:"8080" validate:"required,min=1,max=65535" usage:"监听端口"`
+	Timeout int    `mapstructure:"timeout" default:"30" validate:"min=0" usage:"请求超时(秒)"`
 }
 
 type DatabaseConfig struct {
-	Driver          string `mapstructure:"driver"`
-	DSN             string `mapstructure:"dsn"`
-	MaxConnections  int    `mapstructure:"max_connections"`
-	MaxIdleConns    int    `mapstructure:"max_idle_conns"`
-	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime"`
+	Driver          string `mapstructure:"driver" default:"mysql" validate:"oneof=mysql postgres sqlite" usage:"数据库驱动"`
+	DSN             string `mapstructure:"dsn" validate:"required" usage:"数据源,建议使用${env:DB_DSN}引用"`
+	MaxConnections  int    `mapstructure:"max_connections" default:"100" validate:"min=1" usage:"最大连接数"`
+	MaxIdleConns    int    `mapstructure:"max_idle_conns" default:"10" validate:"min=0" usage:"最大空闲连接数"`
+	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime" default:"3600" validate:"min=0" usage:"连接最长存活时间(秒)"`
 }
 
 type RedisConfig struct {
-	Addr     string `mapstructure:"addr"`
-	Password string `mapstructure:"password"`
-	DB       int    `mapstructure:"db"`
-	PoolSize int    `mapstructure:"pool_size"`
+	Addr     string `mapstructure:"addr" default:"127.0.0.1:6379" validate:"required" usage:"Redis地址"`
+	Password string `mapstructure:"password" usage:"Redis密码"`
+	DB       int    `mapstructure:"db" validate:"min=0,max=15" usage:"数据库编号"`
+	PoolSize int    `mapstructure:"pool_size" default:"10" validate:"min=1" usage:"连接池大小"`
 }
 
 type LogConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
-	Output string `mapstructure:"output"`
+	Level  string `mapstructure:"level" default:"info" validate:"oneof=debug info warn error" usage:"日志级别"`
+	Format string `mapstructure:"format" default:"console" validate:"oneof=console json" usage:"日志格式"`
+	Output string `mapstructure:"output" default:"stdout" usage:"输出位置,stdout或文件路径"`
 }
 
 // Example1_BasicUsage 基本使用示例
@@ -171,7 +173,7 @@ func Example6_MultipleConfigs() {
 	)
 	_ = dbConfig.Load()
 
-	// 合并配置（数据库配置优先级更高）
+	// 合并配置（主配置中未设置的键使用数据库配置的值）
 	_ = MergeConfig(mainConfig, dbConfig)
 
 	// 使用合并后的配置
@@ -258,3 +260,45 @@ func Example9_ConfigDebug() {
 		fmt.Printf("配置键: %s\n", key)
 	}
 }
+
+// Example10_TypedLoad 类型化加载与校验示例
+func Example10_TypedLoad() {
+	// 按 default 标签 < 配置文件 < 环境变量 的优先级加载，并按 validate 标签校验
+	res, err := Load[ExampleConfig](
+		WithFile("./config/app.yaml"),
+		WithEnv("APP"),
+	)
+	if err != nil {
+		// 所有不合法的字段会一次性列出，例如：
+		// 1001 ArgsError database.dsn: is required; server.port: must be <= 65535
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	fmt.Printf("端口: %d\n", res.Config.Server.Port)
+
+	// 查看每个配置项的来源
+	if src, ok := res.Source("server.port"); ok {
+		fmt.Printf("server.port 来自: %s\n", src) // 例如 env(APP_SERVER_PORT)
+	}
+}
+
+// Example11_Schema 导出 JSON Schema 和带注释的示例配置
+func Example11_Schema() {
+	// 导出 JSON Schema，可以交给 configcheck 或编辑器使用
+	schema, err := JSONSchema(&ExampleConfig{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, _ := json.MarshalIndent(schema, "", "  ")
+	_ = os.WriteFile("./config/app.schema.json", b, 0644)
+
+	// 生成带注释的示例配置
+	sample, _ := SampleYAML(&ExampleConfig{})
+	_ = os.WriteFile("./config/app.sample.yaml", sample, 0644)
+
+	// 在代码中校验配置文件，多个文件按顺序深度合并后校验
+	if err := schema.ValidateFile("./config/app.yaml", "./config/app.prod.yaml"); err != nil {
+		// 例如 1001 ArgsError server.hots: unknown key; database.driver: must be one of [mysql postgres sqlite]
+		log.Printf("配置不合法: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/spf13/pflag"
+)
+
+// RegisterFlags 按配置结构体的mapstructure标签在fs中生成命令行参数,参数名为完整的配置键,
+// 如server.port对应--server.port,帮助信息取usage标签,默认值取default标签。
+// 生成的参数需要通过ViperConfig.BindFlags绑定后才会生效,cfg为结构体指针或结构体
+func RegisterFlags(fs *pflag.FlagSet, cfg any) error {
+	t := reflect.TypeOf(cfg)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return errs.ErrArgs.WrapMsg("config must be a struct", "type", reflect.TypeOf(cfg))
+	}
+	return registerFlags(fs, t, nil)
+}
+
+// RegisterGoFlags 与RegisterFlags相同,但生成标准库flag的参数
+func RegisterGoFlags(fs *flag.FlagSet, cfg any) error {
+	pfs := pflag.NewFlagSet(fs.Name(), pflag.ContinueOnError)
+	if err := RegisterFlags(pfs, cfg); err != nil {
+		return err
+	}
+	// pflag的Value同样实现了flag.Value,布尔值实现了IsBoolFlag,可以直接使用-debug
+	pfs.VisitAll(func(f *pflag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return nil
+}
+
+func registerFlags(fs *pflag.FlagSet, t reflect.Type, path []string) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := fieldKey(sf)
+		if !ok {
+			continue
+		}
+		ft := sf.Type
+		if squash {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if err := registerFlags(fs, ft, path); err != nil {
+				return err
+			}
+			continue
+		}
+		fieldPath := append(append([]string(nil), path...), name)
+		if isNestedStruct(ft) {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if err := registerFlags(fs, ft, fieldPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := strings.Join(fieldPath, ".")
+		if fs.Lookup(key) != nil {
+			continue
+		}
+		if err := addFlag(fs, key, ft, sf.Tag.Get("default"), sf.Tag.Get("usage")); err != nil {
+			return errs.WrapMsg(err, "生成命令行参数失败", "flag", key)
+		}
+	}
+	return nil
+}
+
+// addFlag 按字段类型生成参数,无法直接表示的类型(时间、URL、结构体列表等)生成字符串参数
+func addFlag(fs *pflag.FlagSet, name string, t reflect.Type, def, usage string) error {
+	v := reflect.New(t).Elem()
+	if def != "" {
+		if err := setValue(v, def); err != nil {
+			return err
+		}
+	}
+	switch {
+	case t == durationType:
+		fs.Duration(name, time.Duration(v.Int()), usage)
+		return nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		fs.StringSlice(name, v.Convert(reflect.TypeOf([]string(nil))).Interface().([]string), usage)
+		return nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Int:
+		fs.IntSlice(name, v.Convert(reflect.TypeOf([]int(nil))).Interface().([]int), usage)
+		return nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String:
+		fs.StringToString(name, v.Convert(reflect.TypeOf(map[string]string(nil))).Interface().(map[string]string), usage)
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		fs.String(name, v.String(), usage)
+	case reflect.Bool:
+		fs.Bool(name, v.Bool(), usage)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		fs.Int(name, int(v.Int()), usage)
+	case reflect.Int64:
+		fs.Int64(name, v.Int(), usage)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		fs.Uint(name, uint(v.Uint()), usage)
+	case reflect.Uint64:
+		fs.Uint64(name, v.Uint(), usage)
+	case reflect.Float32, reflect.Float64:
+		fs.Float64(name, v.Float(), usage)
+	default:
+		fs.String(name, def, usage)
+	}
+	return nil
+}
+
+// BindFlags 绑定命令行参数,命令行中显式指定的参数优先级高于环境变量和配置文件,
+// 未指定的参数以其默认值作为最低优先级的默认值。参数名即配置键,通常由RegisterFlags生成
+func (vc *ViperConfig) BindFlags(fs *pflag.FlagSet) error {
+	if err := vc.viper.BindPFlags(fs); err != nil {
+		return errs.WrapMsg(err, "绑定命令行参数失败")
+	}
+	vc.layerMutex.Lock()
+	vc.flags = append(vc.flags, fs)
+	vc.layerMutex.Unlock()
+	return nil
+}
+
+// BindGoFlags 绑定标准库flag的参数,需要在fs.Parse之后调用
+func (vc *ViperConfig) BindGoFlags(fs *flag.FlagSet) error {
+	pfs := pflag.NewFlagSet(fs.Name(), pflag.ContinueOnError)
+	pfs.AddGoFlagSet(fs)
+	// 标准库flag解析时不会设置pflag的Changed,按实际出现在命令行中的参数补上
+	fs.Visit(func(f *flag.Flag) {
+		if pf := pfs.Lookup(f.Name); pf != nil {
+			pf.Changed = true
+		}
+	})
+	return vc.BindFlags(pfs)
+}
+
+// lookupFlagNolock 查找在命令行中显式指定了配置键的参数
+func (vc *ViperConfig) lookupFlagNolock(key string) (string, bool) {
+	for _, fs := range vc.flags {
+		if f := fs.Lookup(key); f != nil && f.Changed {
+			return "--" + f.Name, true
+		}
+	}
+	return "", false
+}
+
+// hasFlagNolock 判断配置键是否绑定了命令行参数(未指定时参数默认值作为默认值生效)
+func (vc *ViperConfig) hasFlagNolock(key string) bool {
+	for _, fs := range vc.flags {
+		if fs.Lookup(key) != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// flagTestConfig 测试命令行参数生成的配置结构体
+type flagTestConfig struct {
+	Server struct {
+		Host    string        `mapstructure:"host" default:"0.0.0.0" usage:"监听地址"`
+		Port    int           `mapstructure:"port" default:"8080" usage:"监听端口"`
+		Timeout time.Duration `mapstructure:"timeout" default:"30s" usage:"请求超时"`
+	} `mapstructure:"server"`
+	Debug  bool              `mapstructure:"debug" usage:"调试模式"`
+	Tags   []string          `mapstructure:"tags" usage:"标签"`
+	Labels map[string]string `mapstructure:"labels"`
+	Ignore string            `mapstructure:"-"`
+}
+
+// TestRegisterFlags 测试按结构体生成参数及帮助信息
+func TestRegisterFlags(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := RegisterFlags(fs, &flagTestConfig{}); err != nil {
+		t.Fatalf("生成参数失败: %v", err)
+	}
+	want := map[string]struct{ typ, def, usage string }{
+		"server.host":    {"string", "0.0.0.0", "监听地址"},
+		"server.port":    {"int", "8080", "监听端口"},
+		"server.timeout": {"duration", "30s", "请求超时"},
+		"debug":          {"bool", "false", "调试模式"},
+		"tags":           {"stringSlice", "[]", "标签"},
+		"labels":         {"stringToString", "[]", ""},
+	}
+	for name, w := range want {
+		f := fs.Lookup(name)
+		if f == nil {
+			t.Errorf("缺少参数 --%s", name)
+			continue
+		}
+		if f.Value.Type() != w.typ || f.DefValue != w.def || f.Usage != w.usage {
+			t.Errorf("--%s 期望 %v, 实际为 type=%s def=%s usage=%s", name, w, f.Value.Type(), f.DefValue, f.Usage)
+		}
+	}
+	if fs.Lookup("ignore") != nil {
+		t.Error("mapstructure:\"-\" 的字段不应生成参数")
+	}
+	if err := RegisterFlags(fs, "not a struct"); err == nil {
+		t.Error("非结构体应该返回错误")
+	}
+}
+
+// TestViperBindFlags 测试命令行参数的优先级高于环境变量和配置文件
+func TestViperBindFlags(t *testing.T) {
+	file := writeTestFile(t, "app.yaml", `
+server:
+  host: localhost
+  port: 9000
+debug: false
+`)
+	t.Setenv("FLAG_SERVER_PORT", "9100")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := RegisterFlags(fs, &flagTestConfig{}); err != nil {
+		t.Fatalf("生成参数失败: %v", err)
+	}
+	if err := fs.Parse([]string{"--server.port=8081", "--debug", "--tags=a,b"}); err != nil {
+		t.Fatalf("解析参数失败: %v", err)
+	}
+
+	vc := NewViperConfig(WithEnvPrefix("FLAG"))
+	if err := vc.BindFlags(fs); err != nil {
+		t.Fatalf("绑定参数失败: %v", err)
+	}
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+
+	var cfg flagTestConfig
+	if err := vc.Unmarshal(&cfg); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if cfg.Server.Port != 8081 || !cfg.Debug || !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) {
+		t.Errorf("命令行参数未生效: %+v", cfg)
+	}
+	if cfg.Server.Host != "localhost" || cfg.Server.Timeout != 30*time.Second {
+		t.Errorf("未指定的参数不应覆盖配置文件, 默认值应该生效: %+v", cfg)
+	}
+	if src, _ := vc.Explain("server.port"); src != (Source{Kind: SourceFlag, Name: "--server.port"}) {
+		t.Errorf("server.port来源错误: %v", src)
+	}
+	if src, _ := vc.Explain("server.timeout"); src.Kind != SourceDefault {
+		t.Errorf("server.timeout来源错误: %v", src)
+	}
+}
+
+// TestGoFlags 测试标准库flag的生成和绑定
+func TestGoFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := RegisterGoFlags(fs, &flagTestConfig{}); err != nil {
+		t.Fatalf("生成参数失败: %v", err)
+	}
+	if err := fs.Parse([]string{"-server.port=8082", "-debug"}); err != nil {
+		t.Fatalf("解析参数失败: %v", err)
+	}
+	vc := NewViperConfig()
+	if err := vc.BindGoFlags(fs); err != nil {
+		t.Fatalf("绑定参数失败: %v", err)
+	}
+	vc.Set("server.host", "override")
+	if vc.GetInt("server.port") != 8082 || !vc.GetBool("debug") || vc.GetString("server.host") != "override" {
+		t.Errorf("标准库参数未生效: %v", vc.viper.AllSettings())
+	}
+	if vc.viper.GetDuration("server.timeout") != 30*time.Second {
+		t.Errorf("参数默认值未生效: %v", vc.viper.Get("server.timeout"))
+	}
+}
+
+// TestLoadWithFlags 测试类型化加载使用命令行参数
+func TestLoadWithFlags(t *testing.T) {
+	file := writeTestFile(t, "app.yaml", `
+server:
+  port: 9000
+database:
+  dsn: local
+`)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	if err := RegisterFlags(fs, &loadTestConfig{}); err != nil {
+		t.Fatalf("生成参数失败: %v", err)
+	}
+	if err := fs.Parse([]string{"--server.port", "8083", "--tags=x,y", "--labels=a=1,b=2"}); err != nil {
+		t.Fatalf("解析参数失败: %v", err)
+	}
+	res, err := Load[loadTestConfig](WithFile(file), WithFlags(fs))
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if res.Config.Server.Port != 8083 || res.Config.Server.Host != "0.0.0.0" {
+		t.Errorf("命令行参数未生效: %+v", res.Config.Server)
+	}
+	if !reflect.DeepEqual(res.Config.Tags, []string{"x", "y"}) || res.Config.Labels["b"] != 2 {
+		t.Errorf("列表和map参数解析错误: %v %v", res.Config.Tags, res.Config.Labels)
+	}
+	if s, _ := res.Source("server.port"); s.Kind != SourceFlag {
+		t.Errorf("server.port来源错误: %v", s)
+	}
+}
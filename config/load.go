@@ -0,0 +1,366 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Cospk/base-tools/env"
+	"github.com/Cospk/base-tools/errs"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceKind 配置值来源的类型
+type SourceKind string
+
+const (
+	SourceDefault  SourceKind = "default" // 来自default标签
+	SourceFile     SourceKind = "file"    // 来自配置文件
+	SourceEnv      SourceKind = "env"     // 来自环境变量
+	SourceRemote   SourceKind = "remote"  // 来自远程配置中心
+	SourceFlag     SourceKind = "flag"    // 来自命令行参数
+	SourceOverride SourceKind = "set"     // 来自ViperConfig.Set
+)
+
+// Source 配置值的来源,Name为文件路径或环境变量名,默认值没有Name
+type Source struct {
+	Kind SourceKind
+	Name string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return string(s.Kind)
+	}
+	return string(s.Kind) + "(" + s.Name + ")"
+}
+
+// Result Load的结果
+type Result[T any] struct {
+	Config  *T                // 解析并校验通过的配置
+	Sources map[string]Source // 每个已设置的配置键(如"server.port")的来源
+}
+
+// Source 返回配置键的来源,未设置的键返回false
+func (r *Result[T]) Source(key string) (Source, bool) {
+	s, ok := r.Sources[strings.ToLower(key)]
+	return s, ok
+}
+
+// LoadOption Load的配置选项
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	files     []string
+	providers []remoteSource
+	cacheDir  string
+	secrets   *Secrets
+	lists     ListStrategy
+	flags     *pflag.FlagSet
+	fileKey   []byte
+	envFiles  []string
+	env       bool
+	envPrefix string
+}
+
+// remoteSource 一个远程配置来源及其内容格式
+type remoteSource struct {
+	provider Provider
+	format   string
+}
+
+// WithFile 添加配置文件(yaml、yml或json),后添加的文件优先级更高。
+// 以.enc结尾的加密文件(如app.yaml.enc)会先解密再解析,环境特定的配置文件可以用ProfileFiles生成
+func WithFile(paths ...string) LoadOption {
+	return func(o *loadOptions) {
+		o.files = append(o.files, paths...)
+	}
+}
+
+// WithProvider 添加远程配置来源,format为内容格式(yaml、yml或json)。
+// 远程配置的优先级高于所有配置文件、低于环境变量,后添加的来源优先级更高
+func WithProvider(p Provider, format string) LoadOption {
+	return func(o *loadOptions) {
+		o.providers = append(o.providers, remoteSource{provider: p, format: format})
+	}
+}
+
+// WithCacheDir 设置远程配置的本地缓存目录。每次获取成功后写入缓存,
+// 远程不可达时使用缓存中上一次的内容
+func WithCacheDir(dir string) LoadOption {
+	return func(o *loadOptions) {
+		o.cacheDir = dir
+	}
+}
+
+// WithSecrets 设置解析${scheme:ref}密钥引用的Secrets,默认只支持env和file,
+// 需要解密${enc:...}时传入注册了NewEncResolver的Secrets
+func WithSecrets(s *Secrets) LoadOption {
+	return func(o *loadOptions) {
+		o.secrets = s
+	}
+}
+
+// WithFileKey 设置解密*.enc配置文件的密钥,未设置时使用LoadEncKey从环境变量或密钥文件读取
+func WithFileKey(key []byte) LoadOption {
+	return func(o *loadOptions) {
+		o.fileKey = key
+	}
+}
+
+// WithListStrategy 设置多个来源中同一列表的合并方式,默认ListReplace
+func WithListStrategy(s ListStrategy) LoadOption {
+	return func(o *loadOptions) {
+		o.lists = s
+	}
+}
+
+// WithFlags 使用命令行中显式指定的参数覆盖其他所有来源,参数名为完整的配置键,
+// 通常由RegisterFlags生成,需要在fs.Parse之后调用Load
+func WithFlags(fs *pflag.FlagSet) LoadOption {
+	return func(o *loadOptions) {
+		o.flags = fs
+	}
+}
+
+// WithDotenv 在加载前按顺序读取.env文件(不存在的文件会被忽略)并设置为进程的环境变量,
+// 使WithEnv能读到其中的值。进程中已经设置的变量不会被覆盖
+func WithDotenv(files ...string) LoadOption {
+	return func(o *loadOptions) {
+		o.envFiles = append(o.envFiles, files...)
+	}
+}
+
+// WithEnv 允许环境变量覆盖配置文件,变量名为"前缀_键"的大写形式,
+// 键中的"."替换为"_",如前缀APP时server.port对应APP_SERVER_PORT。前缀为空时不加前缀
+func WithEnv(prefix string) LoadOption {
+	return func(o *loadOptions) {
+		o.env = true
+		o.envPrefix = prefix
+	}
+}
+
+// Load 不依赖Viper的类型化配置加载:按default标签 < 配置文件 < 远程配置 < 环境变量 < 命令行参数的优先级
+// 填充T(字段名取mapstructure标签),解析其中的${env:NAME}等密钥引用,再按validate标签校验。
+// 类型转换错误和校验错误会汇总到一个errs.FieldErrors中返回
+func Load[T any](opts ...LoadOption) (*Result[T], error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := loadEnvFiles(o.envFiles); err != nil {
+		return nil, err
+	}
+	var layers []layer
+	for _, path := range o.files {
+		data, err := readConfigFile(path, o.fileKey)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, &mapLayer{source: Source{Kind: SourceFile, Name: path}, data: data})
+	}
+	cache := remoteCache{dir: o.cacheDir}
+	for _, rs := range o.providers {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteFetchTimeout)
+		content, err := cache.fetch(ctx, rs.provider)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		data, err := parseConfig(content, rs.format)
+		if err != nil {
+			return nil, errs.WrapMsg(err, "解析远程配置失败", "provider", rs.provider.Name())
+		}
+		layers = append(layers, &mapLayer{source: Source{Kind: SourceRemote, Name: rs.provider.Name()}, data: data})
+	}
+	if o.env {
+		layers = append(layers, &envLayer{prefix: o.envPrefix})
+	}
+	if o.flags != nil {
+		layers = append(layers, &flagLayer{fs: o.flags})
+	}
+
+	cfg := new(T)
+	v := reflect.ValueOf(cfg).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, errs.ErrArgs.WrapMsg("config type must be a struct", "type", v.Type().String())
+	}
+	secrets := o.secrets
+	if secrets == nil {
+		secrets = defaultSecrets
+	}
+	d := &decoder{layers: layers, secrets: secrets, lists: o.lists, sources: make(map[string]Source), errors: errs.NewFieldErrors()}
+	d.decodeStruct(v, nil)
+	validateStruct(v, nil, d.errors)
+	if err := d.errors.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return &Result[T]{Config: cfg, Sources: d.sources}, nil
+}
+
+// readConfigFile 按扩展名读取yaml或json配置文件,*.enc文件使用key解密后按去掉.enc的扩展名解析
+func readConfigFile(path string, key []byte) (map[string]any, error) {
+	content, err := readConfigBytes(path, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseConfig(content, plainExt(path))
+	if err != nil {
+		return nil, errs.WrapMsg(err, "解析配置文件失败", "file", path)
+	}
+	return data, nil
+}
+
+var (
+	dotenvMu   sync.Mutex
+	dotenvVars = make(map[string]string) // 由.env文件设置的环境变量及其值
+)
+
+// loadEnvFiles 将.env文件中的变量设置到进程环境变量,不覆盖已有的变量。
+// 之前由.env设置且没有被修改过的变量会被更新,因此重新加载时能读到.env文件的修改
+func loadEnvFiles(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+	fromDotenv := func(key string) bool {
+		v, ok := dotenvVars[key]
+		return ok && v == os.Getenv(key)
+	}
+	vars, err := env.ReadDotenv(files, env.NoOverride(), env.Overridable(fromDotenv), env.SkipMissing())
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := os.LookupEnv(k); ok && !fromDotenv(k) {
+			continue
+		}
+		if err := os.Setenv(k, vars[k]); err != nil {
+			return errs.WrapMsg(err, "设置环境变量失败", "key", k)
+		}
+		dotenvVars[k] = vars[k]
+	}
+	return nil
+}
+
+// parseConfig 按格式(yaml、yml或json,可带前导".")解析配置内容
+func parseConfig(content []byte, format string) (map[string]any, error) {
+	data := make(map[string]any)
+	var err error
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(content, &data)
+	case "json":
+		err = json.Unmarshal(content, &data)
+	default:
+		return nil, errs.ErrArgs.WrapMsg("unsupported config format", "format", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// layer 一个配置来源,按键路径查找值
+type layer interface {
+	lookup(path []string) (any, Source, bool)
+}
+
+// mapLayer 来自配置文件等嵌套map的配置
+type mapLayer struct {
+	source Source
+	data   map[string]any
+}
+
+func (l *mapLayer) lookup(path []string) (any, Source, bool) {
+	v, ok := lookupMap(l.data, path)
+	return v, l.source, ok
+}
+
+// lookupMap 按路径在嵌套map中查找值,键不区分大小写,值为nil视为未设置
+func lookupMap(data map[string]any, path []string) (any, bool) {
+	var cur any = data
+	for _, key := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = mapIndex(m, key); !ok {
+			return nil, false
+		}
+	}
+	return cur, cur != nil
+}
+
+// mapIndex 不区分大小写地查找键
+func mapIndex(m map[string]any, key string) (any, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// envLayer 来自环境变量的配置,空值视为未设置
+type envLayer struct {
+	prefix string
+}
+
+func (l *envLayer) lookup(path []string) (any, Source, bool) {
+	name := envName(l.prefix, path)
+	v, ok := os.LookupEnv(name)
+	if !ok || v == "" {
+		return nil, Source{}, false
+	}
+	return v, Source{Kind: SourceEnv, Name: name}, true
+}
+
+// envName 返回配置键对应的环境变量名,如APP_SERVER_PORT
+func envName(prefix string, path []string) string {
+	name := strings.ToUpper(strings.Join(path, "_"))
+	if prefix == "" {
+		return name
+	}
+	return strings.ToUpper(prefix) + "_" + name
+}
+
+// flagLayer 来自命令行参数的配置,只使用显式指定的参数
+type flagLayer struct {
+	fs *pflag.FlagSet
+}
+
+func (l *flagLayer) lookup(path []string) (any, Source, bool) {
+	f := l.fs.Lookup(strings.Join(path, "."))
+	if f == nil || !f.Changed {
+		return nil, Source{}, false
+	}
+	src := Source{Kind: SourceFlag, Name: "--" + f.Name}
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		items := make([]any, 0, len(sv.GetSlice()))
+		for _, item := range sv.GetSlice() {
+			items = append(items, item)
+		}
+		return items, src, true
+	}
+	if f.Value.Type() == "stringToString" {
+		// 形如"[k1=v1,k2=v2]",去掉括号后与map的字符串写法相同
+		return strings.Trim(f.Value.String(), "[]"), src, true
+	}
+	return f.Value.String(), src, true
+}
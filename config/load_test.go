@@ -0,0 +1,221 @@
+package config
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// loadTestConfig 测试类型化加载的配置结构体
+type loadTestConfig struct {
+	Server struct {
+		Host    string        `mapstructure:"host" default:"0.0.0.0"`
+		Port    int           `mapstructure:"port" default:"8080" validate:"required,min=1,max=65535"`
+		Timeout time.Duration `mapstructure:"timeout" default:"30s" validate:"min=1s"`
+	} `mapstructure:"server"`
+	Database struct {
+		DSN    string `mapstructure:"dsn" validate:"required"`
+		Driver string `mapstructure:"driver" default:"mysql" validate:"oneof=mysql postgres"`
+	} `mapstructure:"database"`
+	Endpoint *url.URL         `mapstructure:"endpoint"`
+	Callback string           `mapstructure:"callback" validate:"url"`
+	Interval string           `mapstructure:"interval" validate:"duration"`
+	Tags     []string         `mapstructure:"tags" validate:"max=3"`
+	Labels   map[string]int   `mapstructure:"labels"`
+	Backends []loadTestTarget `mapstructure:"backends"`
+}
+
+type loadTestTarget struct {
+	Addr   string `mapstructure:"addr" validate:"required"`
+	Weight int    `mapstructure:"weight" default:"1"`
+}
+
+// writeTestFile 在临时目录中写入测试文件
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("创建测试文件失败: %v", err)
+	}
+	return path
+}
+
+// TestLoad 测试类型化加载、默认值和来源记录
+func TestLoad(t *testing.T) {
+	file := writeTestFile(t, "app.yaml", `
+server:
+  host: localhost
+  port: 9000
+database:
+  dsn: root@tcp(127.0.0.1:3306)/app
+callback: https://example.com/hook
+interval: 5m
+tags: [a, b]
+labels:
+  x: 1
+backends:
+  - addr: 10.0.0.1:80
+  - addr: 10.0.0.2:80
+    weight: 3
+`)
+	t.Setenv("LOADTEST_SERVER_PORT", "9090")
+	t.Setenv("LOADTEST_ENDPOINT", "http://api.local/v1")
+
+	res, err := Load[loadTestConfig](WithFile(file), WithEnv("LOADTEST"))
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	cfg := res.Config
+
+	if cfg.Server.Host != "localhost" {
+		t.Errorf("主机配置错误，期望: localhost, 实际: %s", cfg.Server.Host)
+	}
+	if cfg.Server.Port != 9090 {
+		t.Errorf("环境变量覆盖失败，期望: 9090, 实际: %d", cfg.Server.Port)
+	}
+	if cfg.Server.Timeout != 30*time.Second {
+		t.Errorf("默认值错误，期望: 30s, 实际: %s", cfg.Server.Timeout)
+	}
+	if cfg.Endpoint == nil || cfg.Endpoint.Host != "api.local" {
+		t.Errorf("URL 解析错误，实际: %v", cfg.Endpoint)
+	}
+	if len(cfg.Tags) != 2 || cfg.Labels["x"] != 1 {
+		t.Errorf("切片或 map 解析错误，实际: %v %v", cfg.Tags, cfg.Labels)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].Weight != 1 || cfg.Backends[1].Weight != 3 {
+		t.Errorf("结构体切片解析错误，实际: %+v", cfg.Backends)
+	}
+
+	sources := map[string]Source{
+		"server.host":     {Kind: SourceFile, Name: file},
+		"server.port":     {Kind: SourceEnv, Name: "LOADTEST_SERVER_PORT"},
+		"server.timeout":  {Kind: SourceDefault},
+		"database.driver": {Kind: SourceDefault},
+		"endpoint":        {Kind: SourceEnv, Name: "LOADTEST_ENDPOINT"},
+	}
+	for key, want := range sources {
+		if got, ok := res.Source(key); !ok || got != want {
+			t.Errorf("%s 来源错误，期望: %s, 实际: %s", key, want, got)
+		}
+	}
+	if _, ok := res.Source("interval_missing"); ok {
+		t.Error("未设置的键不应该有来源")
+	}
+}
+
+// TestLoadValidation 测试所有校验错误被汇总返回
+func TestLoadValidation(t *testing.T) {
+	file := writeTestFile(t, "app.json", `{
+  "server": {"port": 70000, "timeout": "10ms"},
+  "database": {"driver": "oracle"},
+  "callback": "not a url",
+  "interval": "soon",
+  "tags": ["a", "b", "c", "d"],
+  "labels": {"x": "one"},
+  "backends": [{"weight": 2}]
+}`)
+
+	_, err := Load[loadTestConfig](WithFile(file))
+	if err == nil {
+		t.Fatal("非法配置应该返回错误")
+	}
+	if !errors.Is(err, errs.ErrArgs) {
+		t.Errorf("错误应该是 ArgsError，实际: %v", err)
+	}
+	var fe *errs.FieldErrors
+	if !errors.As(err, &fe) {
+		t.Fatalf("错误应该是 *errs.FieldErrors，实际: %T", err)
+	}
+
+	want := map[string]string{
+		"labels":           "type",
+		"server.port":      "max",
+		"server.timeout":   "min",
+		"database.dsn":     "required",
+		"database.driver":  "oneof",
+		"callback":         "url",
+		"interval":         "duration",
+		"tags":             "max",
+		"backends[0].addr": "required",
+	}
+	got := make(map[string]string)
+	for _, f := range fe.Fields() {
+		got[f.Field] = f.Rule
+	}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("%s 应该违反 %s 规则，实际: %q", field, rule, got[field])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("错误数量不符，期望: %d, 实际: %d (%v)", len(want), len(got), err)
+	}
+}
+
+// TestValidateExampleConfig 测试示例配置的校验规则
+func TestValidateExampleConfig(t *testing.T) {
+	var cfg ExampleConfig
+	if err := Validate(&cfg); err == nil {
+		t.Error("零值配置应该校验失败")
+	}
+
+	res, err := Load[ExampleConfig]()
+	if err == nil {
+		t.Fatalf("缺少 DSN 时应该校验失败: %+v", res.Config)
+	}
+	var fe *errs.FieldErrors
+	if !errors.As(err, &fe) || fe.Len() != 1 || fe.Fields()[0].Field != "database.dsn" {
+		t.Errorf("只应该缺少 database.dsn，实际: %v", err)
+	}
+}
+
+// TestDotenv 测试.env文件中的变量对WithEnv和WithEnvPrefix可见,且不覆盖进程中已有的变量
+func TestDotenv(t *testing.T) {
+	dir := t.TempDir()
+	dotenv := filepath.Join(dir, ".env")
+	writeFile(t, dotenv, "DOTAPP_SERVER_PORT=9090\nDOTAPP_SERVER_HOST=dotenv-host\nDOTAPP_DATABASE_DSN=\"root@tcp(${DOTAPP_SERVER_HOST})/app\"\n")
+	t.Setenv("DOTAPP_SERVER_HOST", "process-host")
+	// t.Setenv在测试结束后恢复.env设置的变量
+	for _, k := range []string{"DOTAPP_SERVER_PORT", "DOTAPP_DATABASE_DSN"} {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+
+	res, err := Load[loadTestConfig](WithDotenv(dotenv, filepath.Join(dir, ".env.local")), WithEnv("DOTAPP"))
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if res.Config.Server.Port != 9090 || res.Config.Server.Host != "process-host" {
+		t.Errorf(".env中的变量未生效或覆盖了进程中的变量: %+v", res.Config.Server)
+	}
+	if res.Config.Database.DSN != "root@tcp(process-host)/app" {
+		t.Errorf("${VAR}应该优先使用进程中的变量: %s", res.Config.Database.DSN)
+	}
+
+	os.Unsetenv("DOTAPP_SERVER_PORT")
+	file := filepath.Join(dir, "app.yaml")
+	writeFile(t, file, "server:\n  port: 8080\n")
+	vc := NewViperConfig(WithEnvPrefix("DOTAPP"), WithEnvFile(dotenv))
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if vc.GetInt("server.port") != 9090 || vc.GetString("server.host") != "process-host" {
+		t.Errorf("WithEnvPrefix应该读到.env中的变量: %v", vc.AllSettings())
+	}
+	if src, _ := vc.Explain("server.port"); src.Kind != SourceEnv {
+		t.Errorf("来源应该是环境变量, 实际为 %s", src)
+	}
+	// 重新加载时读到.env的修改,进程中原有的变量仍然不被覆盖
+	writeFile(t, dotenv, "DOTAPP_SERVER_PORT=9191\nDOTAPP_SERVER_HOST=new-host\n")
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if vc.GetInt("server.port") != 9191 || vc.GetString("server.host") != "process-host" {
+		t.Errorf("重新加载时应该读到.env的修改: %v", vc.AllSettings())
+	}
+}
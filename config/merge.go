@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ListStrategy 多层配置合并时列表的处理方式
+type ListStrategy int
+
+const (
+	ListReplace ListStrategy = iota // 高优先级的列表整体替换低优先级的列表(默认)
+	ListAppend                      // 高优先级的元素追加到低优先级的列表之后
+	ListUnique                      // 追加并去掉重复的元素
+)
+
+// DeepMerge 将src深度合并到dst之上并返回新的map,不修改参数。
+// 两边都是map时递归合并,都是列表时按strategy处理,其余情况使用src的值。键不区分大小写
+func DeepMerge(dst, src map[string]any, strategy ListStrategy) map[string]any {
+	out := make(map[string]any, len(dst)+len(src))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, sv := range src {
+		dk := k
+		for ok := range out {
+			if strings.EqualFold(ok, k) {
+				dk = ok
+				break
+			}
+		}
+		dv, exists := out[dk]
+		delete(out, dk)
+		if !exists {
+			out[k] = sv
+			continue
+		}
+		dm, dIsMap := dv.(map[string]any)
+		sm, sIsMap := sv.(map[string]any)
+		if dIsMap && sIsMap {
+			out[k] = DeepMerge(dm, sm, strategy)
+			continue
+		}
+		dl, dIsList := dv.([]any)
+		sl, sIsList := sv.([]any)
+		if dIsList && sIsList {
+			out[k] = mergeLists(dl, sl, strategy)
+			continue
+		}
+		out[k] = sv
+	}
+	return out
+}
+
+// mergeLists 按strategy合并两个列表
+func mergeLists(dst, src []any, strategy ListStrategy) []any {
+	switch strategy {
+	case ListAppend:
+		return append(append([]any(nil), dst...), src...)
+	case ListUnique:
+		out := make([]any, 0, len(dst)+len(src))
+		for _, item := range append(append([]any(nil), dst...), src...) {
+			dup := false
+			for _, o := range out {
+				if reflect.DeepEqual(o, item) {
+					dup = true
+					break
+				}
+			}
+			if !dup {
+				out = append(out, item)
+			}
+		}
+		return out
+	default:
+		return src
+	}
+}
+
+// ProfileFile 返回环境特定的配置文件路径,如ProfileFile("config.yaml", "prod")返回"config.prod.yaml",
+// 加密文件config.yaml.enc返回"config.prod.yaml.enc"
+func ProfileFile(path, profile string) string {
+	var suffix string
+	if IsEncryptedFile(path) {
+		suffix = EncryptedExt
+		path = strings.TrimSuffix(path, suffix)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext + suffix
+}
+
+// ProfileFiles 返回基础配置文件及存在的环境特定配置文件,可以直接传给WithFile:
+//
+//	config.Load[AppConfig](config.WithFile(config.ProfileFiles("config.yaml", os.Getenv("APP_ENV"))...))
+func ProfileFiles(path, profile string) []string {
+	files := []string{path}
+	if profile == "" {
+		return files
+	}
+	if p := ProfileFile(path, profile); fileExists(p) {
+		files = append(files, p)
+	}
+	return files
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
+// flattenKeys 返回嵌套map中所有叶子节点的键(如"server.port"),已排序
+func flattenKeys(m map[string]any) []string {
+	var keys []string
+	var walk func(m map[string]any, prefix string)
+	walk = func(m map[string]any, prefix string) {
+		for k, v := range m {
+			key := strings.ToLower(prefix + k)
+			if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+				walk(sub, key+".")
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	walk(m, "")
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestDeepMerge 测试嵌套map的深度合并和列表合并策略
+func TestDeepMerge(t *testing.T) {
+	base := map[string]any{
+		"server": map[string]any{"host": "localhost", "port": 8080},
+		"tags":   []any{"a", "b"},
+		"Name":   "base",
+	}
+	overlay := map[string]any{
+		"server": map[string]any{"port": 9090},
+		"tags":   []any{"b", "c"},
+		"name":   "prod",
+	}
+
+	tests := []struct {
+		strategy ListStrategy
+		tags     []any
+	}{
+		{ListReplace, []any{"b", "c"}},
+		{ListAppend, []any{"a", "b", "b", "c"}},
+		{ListUnique, []any{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		got := DeepMerge(base, overlay, tt.strategy)
+		server := got["server"].(map[string]any)
+		if server["host"] != "localhost" || server["port"] != 9090 {
+			t.Errorf("嵌套配置合并错误: %v", server)
+		}
+		if !reflect.DeepEqual(got["tags"], tt.tags) {
+			t.Errorf("策略 %d 期望列表 %v, 实际为 %v", tt.strategy, tt.tags, got["tags"])
+		}
+		if got["name"] != "prod" || got["Name"] != nil {
+			t.Errorf("键应该不区分大小写: %v", got)
+		}
+	}
+	if base["server"].(map[string]any)["port"] != 8080 {
+		t.Error("DeepMerge不应修改参数")
+	}
+}
+
+// TestViperLayering 测试多层配置的优先级和来源说明
+func TestViperLayering(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	writeFile(t, base, `
+server:
+  host: localhost
+  port: 8080
+  timeout: 30
+database:
+  dsn: base
+tags: [a, b]
+`)
+	prod := filepath.Join(dir, "config.prod.yaml")
+	writeFile(t, prod, `
+server:
+  port: 9090
+tags: [c]
+`)
+	t.Setenv("LAYER_SERVER_TIMEOUT", "60")
+
+	vc := NewViperConfig(
+		WithEnvPrefix("LAYER"),
+		WithProfile("prod"),
+		WithListMergeStrategy(ListAppend),
+	)
+	vc.SetDefault("server.mode", "release")
+	if err := vc.LoadWithFile(base); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	vc.Set("database.dsn", "override")
+
+	if vc.GetString("server.host") != "localhost" || vc.GetInt("server.port") != 9090 {
+		t.Errorf("环境特定配置未深度合并: %v", vc.AllSettings())
+	}
+	if vc.GetInt("server.timeout") != 60 {
+		t.Errorf("环境变量应该覆盖配置文件, 实际为 %d", vc.GetInt("server.timeout"))
+	}
+	if got := vc.GetStringSlice("tags"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("列表应该追加, 实际为 %v", got)
+	}
+
+	want := map[string]Source{
+		"server.host":    {Kind: SourceFile, Name: base},
+		"server.port":    {Kind: SourceFile, Name: prod},
+		"server.timeout": {Kind: SourceEnv, Name: "LAYER_SERVER_TIMEOUT"},
+		"server.mode":    {Kind: SourceDefault},
+		"database.dsn":   {Kind: SourceOverride},
+		"tags":           {Kind: SourceFile, Name: prod},
+	}
+	all := vc.ExplainAll()
+	for key, src := range want {
+		if all[key] != src {
+			t.Errorf("%s 期望来源 %v, 实际为 %v", key, src, all[key])
+		}
+	}
+	if _, ok := vc.Explain("not.exist"); ok {
+		t.Error("未设置的键不应该有来源")
+	}
+}
+
+// TestMergeConfigNested 测试MergeConfig按叶子键深度合并
+func TestMergeConfigNested(t *testing.T) {
+	primary := NewViperConfig()
+	primary.Set("database.host", "primary")
+
+	secondary := NewViperConfig()
+	secondary.Set("database.host", "secondary")
+	secondary.Set("database.port", 3306)
+
+	if err := MergeConfig(primary, secondary); err != nil {
+		t.Fatalf("合并配置失败: %v", err)
+	}
+	if v := primary.GetString("database.host"); v != "primary" {
+		t.Errorf("主配置值被错误覆盖, 实际为 %s", v)
+	}
+	if v := primary.GetInt("database.port"); v != 3306 {
+		t.Errorf("嵌套配置未合并, 实际为 %d", v)
+	}
+}
+
+// TestLoadProfileFiles 测试类型化加载的环境特定配置文件和列表合并
+func TestLoadProfileFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "app.yaml")
+	writeFile(t, base, `
+server:
+  port: 8080
+database:
+  dsn: base
+tags: [a, b]
+`)
+	writeFile(t, filepath.Join(dir, "app.test.yaml"), `
+server:
+  port: 9090
+tags: [b, c]
+`)
+	if files := ProfileFiles(base, "missing"); len(files) != 1 {
+		t.Errorf("不存在的环境特定配置文件不应加入: %v", files)
+	}
+
+	res, err := Load[loadTestConfig](WithFile(ProfileFiles(base, "test")...), WithListStrategy(ListUnique))
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if res.Config.Server.Port != 9090 || res.Config.Database.DSN != "base" {
+		t.Errorf("合并结果错误: %+v", res.Config)
+	}
+	if !reflect.DeepEqual(res.Config.Tags, []string{"a", "b", "c"}) {
+		t.Errorf("列表应该去重追加, 实际为 %v", res.Config.Tags)
+	}
+}
+
+// writeFile 写入指定路径的测试文件,用于需要多个文件位于同一目录的测试
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("创建测试文件失败: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// 远程配置的默认参数
+const (
+	defaultPollInterval = 30 * time.Second
+	defaultRetryWait    = 5 * time.Second
+	remoteFetchTimeout  = 10 * time.Second // 加载时单次获取的超时时间
+)
+
+// ErrRemoteKeyNotFound 远程配置中心中不存在指定的键
+var ErrRemoteKeyNotFound = errs.ErrRecordNotFound.WrapMsg("remote config key not found")
+
+// Provider 远程配置来源,如etcd、Consul KV或HTTP接口。
+// 内容为完整的配置文档,格式与本地配置文件相同(如yaml或json)
+type Provider interface {
+	// Name 返回来源名称,用于来源说明和本地缓存文件名
+	Name() string
+	// Fetch 获取当前配置内容
+	Fetch(ctx context.Context) ([]byte, error)
+	// Watch 阻塞监听配置变化,内容变化时调用onChange,直到ctx结束。
+	// 远程暂时不可达时会等待后重试,不会返回
+	Watch(ctx context.Context, onChange func([]byte)) error
+}
+
+// remoteCache 远程配置的本地缓存,远程不可达时使用上一次成功获取的内容
+type remoteCache struct {
+	dir string
+}
+
+var cacheNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (c remoteCache) path(p Provider) string {
+	return filepath.Join(c.dir, cacheNameRegexp.ReplaceAllString(p.Name(), "_")+".cache")
+}
+
+// fetch 获取远程配置,成功时更新缓存,失败时回退到缓存
+func (c remoteCache) fetch(ctx context.Context, p Provider) ([]byte, error) {
+	content, err := p.Fetch(ctx)
+	if err == nil {
+		c.store(p, content)
+		return content, nil
+	}
+	if c.dir != "" {
+		if cached, cerr := os.ReadFile(c.path(p)); cerr == nil {
+			return cached, nil
+		}
+	}
+	return nil, errs.WrapMsg(err, "获取远程配置失败", "provider", p.Name())
+}
+
+// store 原子地写入缓存,写入失败时忽略
+func (c remoteCache) store(p Provider, content []byte) {
+	if c.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	path := c.path(p)
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, content) {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// poll 按固定间隔调用fetch,内容与last不同时调用onChange,用于不支持阻塞查询的来源
+func poll(ctx context.Context, interval time.Duration, last []byte, fetch func(context.Context) ([]byte, error), onChange func([]byte)) error {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		content, err := fetch(ctx)
+		if err != nil || content == nil || bytes.Equal(content, last) {
+			continue
+		}
+		last = content
+		onChange(content)
+	}
+}
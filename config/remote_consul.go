@@ -0,0 +1,142 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConsulProvider 通过HTTP API读取Consul KV中的单个键作为配置文档,
+// 不依赖Consul客户端库。监听使用阻塞查询(X-Consul-Index),从上一次Fetch返回的索引开始,变化后立即返回
+type ConsulProvider struct {
+	Address    string        // Agent地址,如"http://127.0.0.1:8500"
+	Key        string        // 存放配置文档的键,如"app/config.yaml"
+	Token      string        // ACL Token
+	Datacenter string        // 数据中心,为空时使用Agent所在的数据中心
+	Client     *http.Client  // 为空时使用http.DefaultClient,其超时需大于WaitTime
+	WaitTime   time.Duration // 阻塞查询的最长等待时间,默认5分钟
+
+	mutex   sync.Mutex
+	fetched bool
+	index   uint64 // 上一次Fetch返回的X-Consul-Index
+	content []byte // 上一次Fetch读到的值,键不存在时为nil
+}
+
+func (p *ConsulProvider) Name() string {
+	return "consul(" + p.Key + ")"
+}
+
+func (p *ConsulProvider) Fetch(ctx context.Context) ([]byte, error) {
+	content, index, err := p.get(ctx, 0)
+	if err == nil || (errors.Is(err, ErrRemoteKeyNotFound) && index > 0) {
+		p.mutex.Lock()
+		p.fetched, p.index, p.content = true, index, content
+		p.mutex.Unlock()
+	}
+	return content, err
+}
+
+func (p *ConsulProvider) Watch(ctx context.Context, onChange func([]byte)) error {
+	// seen表示是否已读取过初始值,启动时键不存在时last为nil,之后创建键也算变化
+	p.mutex.Lock()
+	index, last, seen := p.index, p.content, p.fetched
+	p.mutex.Unlock()
+	for {
+		content, newIndex, err := p.get(ctx, index)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 键不存在时同样使用返回的索引阻塞等待,键被创建后立即返回
+		if errors.Is(err, ErrRemoteKeyNotFound) && newIndex > 0 {
+			content, err = nil, nil
+		}
+		if err != nil {
+			// 出错时等待后重试,并重新获取最新的索引
+			index = 0
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(defaultRetryWait):
+			}
+			continue
+		}
+		// 索引回退说明Consul的状态被重置,需要重新开始
+		if newIndex < index {
+			newIndex = 0
+		}
+		if content != nil {
+			if seen && string(content) != string(last) {
+				onChange(content)
+			}
+			last = content
+		}
+		index, seen = newIndex, true
+	}
+}
+
+// get 读取键的值,index大于0时进行阻塞查询,返回值和新的索引
+func (p *ConsulProvider) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	query := url.Values{}
+	if p.Datacenter != "" {
+		query.Set("dc", p.Datacenter)
+	}
+	if index > 0 {
+		wait := p.WaitTime
+		if wait <= 0 {
+			wait = 5 * time.Minute
+		}
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", wait.String())
+	}
+	u := strings.TrimRight(p.Address, "/") + "/v1/kv/" + strings.TrimLeft(p.Key, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.Token != "" {
+		req.Header.Set("X-Consul-Token", p.Token)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, newIndex, ErrRemoteKeyNotFound
+	default:
+		return nil, 0, fmt.Errorf("unexpected status %s from %s", resp.Status, u)
+	}
+	var pairs []struct {
+		Value string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, 0, err
+	}
+	if len(pairs) == 0 {
+		return nil, newIndex, ErrRemoteKeyNotFound
+	}
+	content, err := base64.StdEncoding.DecodeString(pairs[0].Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, newIndex, nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EtcdProvider 通过etcd v3的gRPC网关(JSON接口)读取单个键作为配置文档,
+// 不依赖etcd客户端库。监听时按Interval轮询并比较mod_revision,从上一次Fetch返回的版本开始
+type EtcdProvider struct {
+	Endpoints []string      // 节点地址,如"http://127.0.0.1:2379",依次尝试
+	Key       string        // 存放配置文档的键
+	Username  string        // 启用认证时的用户名
+	Password  string        // 启用认证时的密码
+	Client    *http.Client  // 为空时使用http.DefaultClient
+	Interval  time.Duration // 轮询间隔,默认30秒
+
+	mutex    sync.Mutex
+	fetched  bool
+	revision int64 // 上一次Fetch读到的mod_revision,键不存在时为0
+}
+
+func (p *EtcdProvider) Name() string {
+	return "etcd(" + p.Key + ")"
+}
+
+func (p *EtcdProvider) Fetch(ctx context.Context) ([]byte, error) {
+	content, revision, err := p.get(ctx)
+	if err == nil || err == ErrRemoteKeyNotFound {
+		p.mutex.Lock()
+		p.fetched, p.revision = true, revision
+		p.mutex.Unlock()
+	}
+	return content, err
+}
+
+func (p *EtcdProvider) Watch(ctx context.Context, onChange func([]byte)) error {
+	p.mutex.Lock()
+	fetched, revision := p.fetched, p.revision
+	p.mutex.Unlock()
+	if !fetched {
+		_, revision, _ = p.get(ctx)
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		content, rev, err := p.get(ctx)
+		if (err != nil && err != ErrRemoteKeyNotFound) || rev == revision {
+			continue
+		}
+		// 键被删除时只记录版本,保留原配置
+		revision = rev
+		if content != nil {
+			onChange(content)
+		}
+	}
+}
+
+// get 依次尝试各节点,返回键的值和mod_revision
+func (p *EtcdProvider) get(ctx context.Context) ([]byte, int64, error) {
+	var lastErr error
+	for _, endpoint := range p.Endpoints {
+		content, revision, err := p.fetch(ctx, strings.TrimRight(endpoint, "/"))
+		if err == nil || err == ErrRemoteKeyNotFound {
+			return content, revision, err
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no etcd endpoints configured")
+	}
+	return nil, 0, lastErr
+}
+
+func (p *EtcdProvider) fetch(ctx context.Context, endpoint string) ([]byte, int64, error) {
+	var token string
+	if p.Username != "" {
+		var auth struct {
+			Token string `json:"token"`
+		}
+		if err := p.post(ctx, endpoint+"/v3/auth/authenticate", "", map[string]string{
+			"name":     p.Username,
+			"password": p.Password,
+		}, &auth); err != nil {
+			return nil, 0, err
+		}
+		token = auth.Token
+	}
+
+	var resp struct {
+		Kvs []struct {
+			Value       string `json:"value"`
+			ModRevision int64  `json:"mod_revision,string"`
+		} `json:"kvs"`
+	}
+	if err := p.post(ctx, endpoint+"/v3/kv/range", token, map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(p.Key)),
+	}, &resp); err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, ErrRemoteKeyNotFound
+	}
+	content, err := base64.StdEncoding.DecodeString(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, resp.Kvs[0].ModRevision, nil
+}
+
+func (p *EtcdProvider) post(ctx context.Context, url, token string, body any, out any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
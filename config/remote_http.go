@@ -0,0 +1,90 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPProvider 从HTTP接口获取配置,GET请求返回完整的配置文档。
+// 监听时按Interval轮询,从上一次Fetch返回的内容开始比较,服务端支持ETag时未变化的内容不会重复下载
+type HTTPProvider struct {
+	URL      string        // 配置地址
+	Header   http.Header   // 附加的请求头,如Authorization
+	Client   *http.Client  // 为空时使用http.DefaultClient
+	Interval time.Duration // 轮询间隔,默认30秒
+
+	mutex   sync.Mutex
+	fetched bool
+	etag    string
+	content []byte
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http(" + p.URL + ")"
+}
+
+func (p *HTTPProvider) Fetch(ctx context.Context) ([]byte, error) {
+	return p.fetch(ctx, false)
+}
+
+func (p *HTTPProvider) Watch(ctx context.Context, onChange func([]byte)) error {
+	fetch := func(ctx context.Context) ([]byte, error) {
+		return p.fetch(ctx, true)
+	}
+	p.mutex.Lock()
+	fetched, last := p.fetched, p.content
+	p.mutex.Unlock()
+	if !fetched {
+		last, _ = fetch(ctx)
+	}
+	return poll(ctx, p.Interval, last, fetch, onChange)
+}
+
+// fetch 获取配置,conditional为true时携带If-None-Match,未变化时返回上一次的内容
+func (p *HTTPProvider) fetch(ctx context.Context, conditional bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+	p.mutex.Lock()
+	etag, cached := p.etag, p.content
+	p.mutex.Unlock()
+	if conditional && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return cached, nil
+	case http.StatusNotFound:
+		return nil, ErrRemoteKeyNotFound
+	default:
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, p.URL)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	p.fetched, p.etag, p.content = true, resp.Header.Get("ETag"), content
+	p.mutex.Unlock()
+	return content, nil
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// remoteStore 测试用的远程配置存储,index在每次修改后递增
+type remoteStore struct {
+	mutex   sync.Mutex
+	content string
+	index   uint64
+	changed chan struct{}
+}
+
+func newRemoteStore(content string) *remoteStore {
+	return &remoteStore{content: content, index: 1, changed: make(chan struct{})}
+}
+
+func (s *remoteStore) get() (string, uint64, chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.content, s.index, s.changed
+}
+
+func (s *remoteStore) set(content string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.content = content
+	s.index++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// newConsulServer Consul KV的本地替身,支持阻塞查询
+func newConsulServer(t *testing.T, key string, store *remoteStore) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/"+key {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		content, index, changed := store.get()
+		if want, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); want >= index {
+			wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+			select {
+			case <-changed:
+			case <-time.After(wait):
+			case <-r.Context().Done():
+				return
+			}
+			content, index, _ = store.get()
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+		if content == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]any{{
+			"Key":         key,
+			"Value":       base64.StdEncoding.EncodeToString([]byte(content)),
+			"ModifyIndex": index,
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newEtcdServer etcd v3 JSON网关的本地替身
+func newEtcdServer(t *testing.T, key string, store *remoteStore) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/kv/range" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Key string `json:"key"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		content, index, _ := store.get()
+		resp := map[string]any{"header": map[string]string{"revision": strconv.FormatUint(index, 10)}}
+		if k, _ := base64.StdEncoding.DecodeString(req.Key); string(k) == key {
+			resp["kvs"] = []map[string]string{{
+				"key":          req.Key,
+				"value":        base64.StdEncoding.EncodeToString([]byte(content)),
+				"mod_revision": strconv.FormatUint(index, 10),
+			}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newHTTPConfigServer 普通HTTP配置接口的本地替身,支持ETag
+func newHTTPConfigServer(t *testing.T, store *remoteStore) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, index, _ := store.get()
+		etag := fmt.Sprintf(`"%d"`, index)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestRemoteProviders 测试各远程来源的获取和监听
+func TestRemoteProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider func(t *testing.T, store *remoteStore) Provider
+	}{
+		{"consul", func(t *testing.T, store *remoteStore) Provider {
+			srv := newConsulServer(t, "app/config.yaml", store)
+			return &ConsulProvider{Address: srv.URL, Key: "app/config.yaml", WaitTime: time.Second}
+		}},
+		{"etcd", func(t *testing.T, store *remoteStore) Provider {
+			srv := newEtcdServer(t, "/app/config", store)
+			return &EtcdProvider{Endpoints: []string{"http://127.0.0.1:1", srv.URL}, Key: "/app/config", Interval: 20 * time.Millisecond}
+		}},
+		{"http", func(t *testing.T, store *remoteStore) Provider {
+			srv := newHTTPConfigServer(t, store)
+			return &HTTPProvider{URL: srv.URL, Interval: 20 * time.Millisecond}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newRemoteStore("port: 1")
+			p := tt.provider(t, store)
+
+			content, err := p.Fetch(context.Background())
+			if err != nil {
+				t.Fatalf("获取远程配置失败: %v", err)
+			}
+			if string(content) != "port: 1" {
+				t.Errorf("期望 port: 1, 实际为 %q", content)
+			}
+
+			// Fetch之后、Watch之前的修改也要通知
+			store.set("port: 2")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			changes := make(chan string, 4)
+			go p.Watch(ctx, func(b []byte) { changes <- string(b) })
+			for _, want := range []string{"port: 2", "port: 3"} {
+				select {
+				case got := <-changes:
+					if got != want {
+						t.Errorf("期望 %s, 实际为 %q", want, got)
+					}
+				case <-time.After(3 * time.Second):
+					t.Fatalf("未收到远程配置变化: %s", want)
+				}
+				if want == "port: 2" {
+					store.set("port: 3")
+				}
+			}
+		})
+	}
+}
+
+// TestConsulKeyCreated 测试启动时不存在的键被创建后触发变化
+func TestConsulKeyCreated(t *testing.T) {
+	store := newRemoteStore("")
+	srv := newConsulServer(t, "app", store)
+	p := &ConsulProvider{Address: srv.URL, Key: "app", WaitTime: time.Second}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string, 4)
+	go p.Watch(ctx, func(b []byte) { changes <- string(b) })
+	time.Sleep(100 * time.Millisecond)
+
+	store.set("port: 1")
+	select {
+	case got := <-changes:
+		if got != "port: 1" {
+			t.Errorf("期望 port: 1, 实际为 %q", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("键被创建后未收到变化")
+	}
+}
+
+// TestLoadWithProvider 测试远程配置与本地文件的合并及缓存回退
+func TestLoadWithProvider(t *testing.T) {
+	file := writeTestFile(t, "app.yaml", `
+server:
+  host: localhost
+  port: 9000
+database:
+  dsn: local
+`)
+	store := newRemoteStore("server:\n  port: 7000\n")
+	srv := newEtcdServer(t, "app", store)
+	p := &EtcdProvider{Endpoints: []string{srv.URL}, Key: "app"}
+	cacheDir := t.TempDir()
+
+	res, err := Load[loadTestConfig](WithFile(file), WithProvider(p, "yaml"), WithCacheDir(cacheDir))
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if res.Config.Server.Port != 7000 || res.Config.Server.Host != "localhost" || res.Config.Database.DSN != "local" {
+		t.Errorf("合并结果错误: %+v", res.Config)
+	}
+	if s, _ := res.Source("server.port"); s.Kind != SourceRemote || s.Name != "etcd(app)" {
+		t.Errorf("server.port来源错误: %v", s)
+	}
+
+	// 远程不可达时回退到缓存
+	srv.Close()
+	res, err = Load[loadTestConfig](WithFile(file), WithProvider(p, "yaml"), WithCacheDir(cacheDir))
+	if err != nil {
+		t.Fatalf("使用缓存加载失败: %v", err)
+	}
+	if res.Config.Server.Port != 7000 {
+		t.Errorf("期望缓存中的端口7000, 实际为 %d", res.Config.Server.Port)
+	}
+	if _, err := Load[loadTestConfig](WithFile(file), WithProvider(p, "yaml")); err == nil {
+		t.Error("没有缓存且远程不可达时应该返回错误")
+	}
+}
+
+// TestViperRemoteProvider 测试ViperConfig合并远程配置并监听变化
+func TestViperRemoteProvider(t *testing.T) {
+	file := writeTestFile(t, "app.yaml", `
+server:
+  host: localhost
+  port: 9000
+`)
+	store := newRemoteStore("server:\n  port: 7000\n")
+	srv := newConsulServer(t, "app", store)
+
+	vc := NewViperConfig(
+		WithRemoteProvider(&ConsulProvider{Address: srv.URL, Key: "app", WaitTime: time.Second}),
+		WithRemoteCacheDir(t.TempDir()),
+	)
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if vc.GetInt("server.port") != 7000 || vc.GetString("server.host") != "localhost" {
+		t.Errorf("合并结果错误: %v", vc.AllSettings())
+	}
+
+	// 回调与配置更新在同一个goroutine中执行,在回调中读取配置
+	ports := make(chan int, 4)
+	vc.OnConfigChange(func() { ports <- vc.GetInt("server.port") })
+	errors := make(chan error, 4)
+	vc.OnError(func(err error) { errors <- err })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vc.WatchRemote(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	// 无法解析的内容不会生效,也不会触发回调
+	store.set("server: [")
+	select {
+	case <-errors:
+	case <-time.After(3 * time.Second):
+		t.Fatal("无法解析的远程配置应该通知错误回调")
+	}
+	store.set("server:\n  port: 7001\n")
+	select {
+	case port := <-ports:
+		if port != 7001 {
+			t.Errorf("期望更新后的端口7001, 实际为 %d", port)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("未收到远程配置变化")
+	}
+	if vc.GetString("server.host") != "localhost" || !strings.Contains(vc.GetConfigFile(), "app.yaml") {
+		t.Errorf("更新后的配置错误: %v", vc.AllSettings())
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Cospk/base-tools/errs"
+	"gopkg.in/yaml.v3"
+)
+
+// SampleYAML 从配置结构体生成带注释的示例yaml,键的顺序与字段顺序相同。
+// 每个键上方的注释为usage说明以及validate标签中的必填、可选值和取值范围,值为default标签中的默认值,
+// 结构体列表生成一个示例元素
+func SampleYAML(cfg any) ([]byte, error) {
+	t := reflect.TypeOf(cfg)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errs.ErrArgs.WrapMsg("config must be a struct", "type", reflect.TypeOf(cfg))
+	}
+	var buf bytes.Buffer
+	writeSampleStruct(&buf, t, 0)
+	return buf.Bytes(), nil
+}
+
+func writeSampleStruct(buf *bytes.Buffer, t reflect.Type, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := fieldKey(sf)
+		if !ok {
+			continue
+		}
+		ft := sf.Type
+		if squash {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			writeSampleStruct(buf, ft, indent)
+			continue
+		}
+		// 顶层的配置段之间空一行
+		if indent == 0 && buf.Len() > 0 && isNestedStruct(ft) {
+			buf.WriteString("\n")
+		}
+		if comment := sampleComment(sf); comment != "" {
+			fmt.Fprintf(buf, "%s# %s\n", pad, comment)
+		}
+		if ft.Kind() == reflect.Pointer && isNestedStruct(ft) {
+			ft = ft.Elem()
+		}
+		switch {
+		case isNestedStruct(ft):
+			fmt.Fprintf(buf, "%s%s:\n", pad, name)
+			writeSampleStruct(buf, ft, indent+2)
+		case ft.Kind() == reflect.Slice && isNestedStruct(ft.Elem()):
+			fmt.Fprintf(buf, "%s%s:\n", pad, name)
+			writeSampleElement(buf, ft.Elem(), indent+2)
+		default:
+			def, hasDefault := sf.Tag.Lookup("default")
+			fmt.Fprintf(buf, "%s%s: %s\n", pad, name, sampleValue(ft, def, hasDefault))
+		}
+	}
+}
+
+// writeSampleElement 写入结构体列表的一个示例元素,第一个键前加"- ",其注释保持在上方
+func writeSampleElement(buf *bytes.Buffer, t reflect.Type, indent int) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var elem bytes.Buffer
+	writeSampleStruct(&elem, t, indent+2)
+	inner := strings.Repeat(" ", indent+2)
+	first := true
+	for _, line := range strings.SplitAfter(elem.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		if first {
+			body := strings.TrimPrefix(line, inner)
+			if strings.HasPrefix(body, "#") {
+				line = strings.Repeat(" ", indent) + body
+			} else {
+				line = strings.Repeat(" ", indent) + "- " + body
+				first = false
+			}
+		}
+		buf.WriteString(line)
+	}
+}
+
+// sampleComment 由usage和validate标签生成注释,如"监听端口 (必填; min=1; max=65535)"
+func sampleComment(sf reflect.StructField) string {
+	var notes []string
+	for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "required":
+			notes = append(notes, "必填")
+		case "oneof":
+			notes = append(notes, "可选值: "+strings.Join(strings.Fields(param), " | "))
+		case "url", "duration":
+			notes = append(notes, "格式: "+name)
+		default:
+			notes = append(notes, strings.TrimSpace(rule))
+		}
+	}
+	usage := sf.Tag.Get("usage")
+	switch {
+	case len(notes) == 0:
+		return usage
+	case usage == "":
+		return strings.Join(notes, "; ")
+	}
+	return usage + " (" + strings.Join(notes, "; ") + ")"
+}
+
+// sampleValue 返回字段示例值的yaml写法,列表和map使用流式写法
+func sampleValue(t reflect.Type, def string, hasDefault bool) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !hasDefault {
+		switch {
+		case t == durationType || t == timeType || t == urlType || t.Kind() == reflect.Struct:
+			return `""`
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+			return "[]"
+		case t.Kind() == reflect.Map:
+			return "{}"
+		}
+		return yamlScalar(reflect.Zero(t).Interface())
+	}
+	v := typedValue(t, def)
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = yamlScalar(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		items := make([]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			items = append(items, yamlScalar(iter.Key().Interface())+": "+yamlScalar(iter.Value().Interface()))
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return yamlScalar(v)
+}
+
+// yamlScalar 返回标量的yaml写法,必要时加引号
+func yamlScalar(v any) string {
+	if s, ok := v.(string); ok && s == "" {
+		return `""`
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(string(b))
+}
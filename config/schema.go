@@ -0,0 +1,470 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// jsonSchemaDraft 生成的JSON Schema版本
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern 匹配time.ParseDuration可以解析的时长,如"30s"、"1h30m"
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// Schema JSON Schema中本包用到的子集,可以序列化为标准的JSON Schema文件,也可以用Validate校验配置
+type Schema struct {
+	Schema               string                `json:"$schema,omitempty"`
+	Title                string                `json:"title,omitempty"`
+	Description          string                `json:"description,omitempty"`
+	Type                 string                `json:"type,omitempty"`
+	Format               string                `json:"format,omitempty"`
+	Pattern              string                `json:"pattern,omitempty"`
+	Default              any                   `json:"default,omitempty"`
+	Enum                 []any                 `json:"enum,omitempty"`
+	Minimum              *float64              `json:"minimum,omitempty"`
+	Maximum              *float64              `json:"maximum,omitempty"`
+	MinLength            *int                  `json:"minLength,omitempty"`
+	MaxLength            *int                  `json:"maxLength,omitempty"`
+	MinItems             *int                  `json:"minItems,omitempty"`
+	MaxItems             *int                  `json:"maxItems,omitempty"`
+	Items                *Schema               `json:"items,omitempty"`
+	Properties           map[string]*Schema    `json:"properties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties,omitempty"`
+}
+
+// AdditionalProperties additionalProperties的取值:Schema不为nil时其他属性需要符合Schema,
+// 否则由Allowed决定是否允许其他属性
+type AdditionalProperties struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a AdditionalProperties) MarshalJSON() ([]byte, error) {
+	if a.Schema != nil {
+		return json.Marshal(a.Schema)
+	}
+	return json.Marshal(a.Allowed)
+}
+
+func (a *AdditionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// JSONSchema 从配置结构体生成JSON Schema:字段名取mapstructure标签,说明取usage标签,
+// 默认值取default标签,validate标签中的required、oneof、min、max和url分别转换为
+// required、enum、取值或长度范围以及uri格式。结构体不允许未定义的键,以便发现拼写错误
+func JSONSchema(cfg any) (*Schema, error) {
+	t := reflect.TypeOf(cfg)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errs.ErrArgs.WrapMsg("config must be a struct", "type", reflect.TypeOf(cfg))
+	}
+	s := structSchema(t)
+	s.Schema = jsonSchemaDraft
+	s.Title = t.Name()
+	return s, nil
+}
+
+// LoadSchema 读取JSON Schema文件
+func LoadSchema(path string) (*Schema, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "读取Schema文件失败", "file", path)
+	}
+	var s Schema
+	if err := json.Unmarshal(content, &s); err != nil {
+		return nil, errs.WrapMsg(err, "解析Schema文件失败", "file", path)
+	}
+	return &s, nil
+}
+
+func structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: &AdditionalProperties{Allowed: false},
+	}
+	addFields(s, t)
+	return s
+}
+
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := fieldKey(sf)
+		if !ok {
+			continue
+		}
+		if squash {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			addFields(s, ft)
+			continue
+		}
+		fs := typeSchema(sf.Type)
+		fs.Description = sf.Tag.Get("usage")
+		def, hasDefault := sf.Tag.Lookup("default")
+		if hasDefault {
+			fs.Default = typedValue(sf.Type, def)
+		}
+		// 有默认值的必填字段在配置文件中可以省略
+		if applyRules(fs, sf.Type, sf.Tag.Get("validate")) && !hasDefault {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// typeSchema 返回类型对应的Schema,不含说明、默认值和校验规则
+func typeSchema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer && !isNestedStruct(t) {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return &Schema{Type: "string", Pattern: durationPattern}
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == urlType:
+		return &Schema{Type: "string", Format: "uri"}
+	case isNestedStruct(t):
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		return structSchema(t)
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: &AdditionalProperties{Schema: typeSchema(t.Elem())}}
+	}
+	return &Schema{}
+}
+
+// typedValue 将default标签或oneof候选值转换为字段类型对应的JSON值,无法转换时保留原字符串
+func typedValue(t reflect.Type, s string) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType || t == timeType || t == urlType || t.Kind() == reflect.Struct {
+		return s
+	}
+	v := reflect.New(t).Elem()
+	if err := setValue(v, s); err != nil {
+		return s
+	}
+	return v.Interface()
+}
+
+// applyRules 将validate标签转换为Schema约束,返回字段是否必填
+func applyRules(s *Schema, t reflect.Type, tag string) (required bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			for _, o := range strings.Fields(param) {
+				s.Enum = append(s.Enum, typedValue(t, o))
+			}
+		case "url":
+			s.Format = "uri"
+		case "duration":
+			s.Pattern = durationPattern
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil || t == durationType {
+				// 时长等无法用数字表示的范围只写入说明,时长的数字参数与Validate一样表示纳秒
+				if n, err := strconv.ParseInt(param, 10, 64); err == nil && t == durationType {
+					param = time.Duration(n).String()
+				}
+				s.Description = strings.TrimSpace(s.Description + " (" + name + " " + param + ")")
+				continue
+			}
+			n := int(limit)
+			switch s.Type {
+			case "integer", "number":
+				if name == "min" {
+					s.Minimum = &limit
+				} else {
+					s.Maximum = &limit
+				}
+			case "string":
+				if name == "min" {
+					s.MinLength = &n
+				} else {
+					s.MaxLength = &n
+				}
+			case "array":
+				if name == "min" {
+					s.MinItems = &n
+				} else {
+					s.MaxItems = &n
+				}
+			}
+		}
+	}
+	return required
+}
+
+// Validate 按Schema校验已解析的配置(如yaml或json解析出的map),
+// 所有违规的键汇总到一个errs.FieldErrors中返回。值为null的键视为未设置
+func (s *Schema) Validate(data any) error {
+	fe := errs.NewFieldErrors()
+	s.validate(data, "", fe)
+	return fe.ErrorOrNil()
+}
+
+// ValidateFile 按顺序深度合并多个yaml或json配置文件(如基础配置和环境特定配置)后按Schema校验,
+// 加密的*.enc文件使用LoadEncKey读取的密钥解密
+func (s *Schema) ValidateFile(paths ...string) error {
+	merged := map[string]any{}
+	for _, path := range paths {
+		data, err := readConfigFile(path, nil)
+		if err != nil {
+			return err
+		}
+		merged = DeepMerge(merged, data, ListReplace)
+	}
+	return s.Validate(merged)
+}
+
+func (s *Schema) validate(v any, key string, fe *errs.FieldErrors) {
+	field := key
+	if field == "" {
+		field = "(root)"
+	}
+	if !s.matchType(v) {
+		fe.Add(field, "type", fmt.Sprintf("must be %s, got %s", s.Type, jsonTypeName(v)))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		options := make([]string, len(s.Enum))
+		for i, o := range s.Enum {
+			options[i] = fmt.Sprint(o)
+		}
+		fe.Add(field, "enum", fmt.Sprintf("must be one of [%s]", strings.Join(options, " ")))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		s.validateObject(val, key, fe)
+	case []any:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			fe.Add(field, "minItems", fmt.Sprintf("must have at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			fe.Add(field, "maxItems", fmt.Sprintf("must have at most %d items", *s.MaxItems))
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				if item != nil {
+					s.Items.validate(item, fmt.Sprintf("%s[%d]", key, i), fe)
+				}
+			}
+		}
+	case string:
+		n := len([]rune(val))
+		if s.MinLength != nil && n < *s.MinLength {
+			fe.Add(field, "minLength", fmt.Sprintf("length must be >= %d", *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fe.Add(field, "maxLength", fmt.Sprintf("length must be <= %d", *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(val) {
+				fe.Add(field, "pattern", fmt.Sprintf("must match %s", s.Pattern))
+			}
+		}
+		if msg := checkFormat(s.Format, val); msg != "" {
+			fe.Add(field, "format", msg)
+		}
+	default:
+		if f, ok := toNumber(v); ok {
+			if s.Minimum != nil && f < *s.Minimum {
+				fe.Add(field, "minimum", fmt.Sprintf("must be >= %v", *s.Minimum))
+			}
+			if s.Maximum != nil && f > *s.Maximum {
+				fe.Add(field, "maximum", fmt.Sprintf("must be <= %v", *s.Maximum))
+			}
+		}
+	}
+}
+
+func (s *Schema) validateObject(m map[string]any, key string, fe *errs.FieldErrors) {
+	join := func(name string) string {
+		if key == "" {
+			return name
+		}
+		return key + "." + name
+	}
+	for _, name := range s.Required {
+		if v, ok := mapIndex(m, name); !ok || v == nil {
+			fe.Add(join(name), "required", "is required")
+		}
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := m[name]
+		if v == nil {
+			continue
+		}
+		if ps, ok := propertyIndex(s.Properties, name); ok {
+			ps.validate(v, join(name), fe)
+			continue
+		}
+		switch ap := s.AdditionalProperties; {
+		case ap == nil || (ap.Schema == nil && ap.Allowed):
+		case ap.Schema != nil:
+			ap.Schema.validate(v, join(name), fe)
+		default:
+			fe.Add(join(name), "additionalProperties", "unknown key")
+		}
+	}
+}
+
+// propertyIndex 不区分大小写地查找属性,与Load的规则相同
+func propertyIndex(props map[string]*Schema, name string) (*Schema, bool) {
+	if s, ok := props[name]; ok {
+		return s, true
+	}
+	for k, s := range props {
+		if strings.EqualFold(k, name) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Schema) matchType(v any) bool {
+	switch s.Type {
+	case "":
+		return true
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		switch v.(type) {
+		case string:
+			return true
+		case time.Time:
+			// yaml会将时间戳直接解析为time.Time
+			return s.Format == "date-time"
+		}
+		return false
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "integer":
+		f, ok := toNumber(v)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := toNumber(v)
+		return ok
+	}
+	return false
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func inEnum(enum []any, v any) bool {
+	s := fmt.Sprint(v)
+	for _, e := range enum {
+		if fmt.Sprint(e) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func checkFormat(format, s string) string {
+	switch format {
+	case "uri":
+		if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid URL"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return "must be an RFC3339 time"
+		}
+	}
+	return ""
+}
+
+// jsonTypeName 返回值对应的JSON类型名,用于错误信息
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string, time.Time:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if f, ok := toNumber(v); ok {
+		if f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
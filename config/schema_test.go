@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+	"gopkg.in/yaml.v3"
+)
+
+// TestJSONSchema 测试从结构体生成Schema
+func TestJSONSchema(t *testing.T) {
+	s, err := JSONSchema(&loadTestConfig{})
+	if err != nil {
+		t.Fatalf("生成Schema失败: %v", err)
+	}
+	if s.Title != "loadTestConfig" || s.Type != "object" || s.AdditionalProperties.Allowed {
+		t.Errorf("根Schema错误: %+v", s)
+	}
+
+	server := s.Properties["server"]
+	port := server.Properties["port"]
+	if port.Type != "integer" || port.Default != 8080 || *port.Minimum != 1 || *port.Maximum != 65535 {
+		t.Errorf("port的Schema错误: %+v", port)
+	}
+	if len(server.Required) != 0 {
+		t.Errorf("有默认值的必填字段不应出现在required中: %v", server.Required)
+	}
+	if timeout := server.Properties["timeout"]; timeout.Type != "string" || timeout.Pattern != durationPattern || timeout.Default != "30s" {
+		t.Errorf("timeout的Schema错误: %+v", timeout)
+	}
+
+	db := s.Properties["database"]
+	if len(db.Required) != 1 || db.Required[0] != "dsn" {
+		t.Errorf("database的required错误: %v", db.Required)
+	}
+	if driver := db.Properties["driver"]; len(driver.Enum) != 2 || driver.Enum[0] != "mysql" {
+		t.Errorf("driver的enum错误: %v", driver.Enum)
+	}
+	if s.Properties["callback"].Format != "uri" || s.Properties["endpoint"].Format != "uri" {
+		t.Error("url字段应该生成uri格式")
+	}
+	if tags := s.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" || *tags.MaxItems != 3 {
+		t.Errorf("tags的Schema错误: %+v", tags)
+	}
+	if labels := s.Properties["labels"]; labels.AdditionalProperties.Schema.Type != "integer" {
+		t.Errorf("labels的Schema错误: %+v", labels)
+	}
+	if backends := s.Properties["backends"]; backends.Items.Properties["addr"] == nil {
+		t.Errorf("backends的Schema错误: %+v", backends)
+	}
+}
+
+// TestSchemaValidate 测试按Schema校验配置文件,包括写入文件后重新读取的Schema
+func TestSchemaValidate(t *testing.T) {
+	generated, err := JSONSchema(&loadTestConfig{})
+	if err != nil {
+		t.Fatalf("生成Schema失败: %v", err)
+	}
+	b, err := json.MarshalIndent(generated, "", "  ")
+	if err != nil {
+		t.Fatalf("序列化Schema失败: %v", err)
+	}
+	loaded, err := LoadSchema(writeTestFile(t, "app.schema.json", string(b)))
+	if err != nil {
+		t.Fatalf("读取Schema失败: %v", err)
+	}
+
+	valid := writeTestFile(t, "valid.yaml", `
+server:
+  port: 9000
+  timeout: 1m30s
+database:
+  dsn: root@tcp(127.0.0.1:3306)/app
+callback: https://example.com/hook
+tags: [a, b]
+labels:
+  x: 1
+backends:
+  - addr: 10.0.0.1:80
+`)
+	invalid := writeTestFile(t, "invalid.yaml", `
+server:
+  port: 70000
+  timeout: 30
+  hots: localhost
+database:
+  driver: oracle
+callback: not-a-url
+tags: [a, b, c, d]
+labels:
+  x: one
+backends:
+  - weight: 2
+`)
+	want := map[string]string{
+		"server.port":      "maximum",
+		"server.timeout":   "type",
+		"server.hots":      "additionalProperties",
+		"database.dsn":     "required",
+		"database.driver":  "enum",
+		"callback":         "format",
+		"tags":             "maxItems",
+		"labels.x":         "type",
+		"backends[0].addr": "required",
+	}
+
+	for name, s := range map[string]*Schema{"generated": generated, "loaded": loaded} {
+		if err := s.ValidateFile(valid); err != nil {
+			t.Errorf("%s: 合法的配置未通过校验: %v", name, err)
+		}
+		err := s.ValidateFile(invalid)
+		var fe *errs.FieldErrors
+		if !errors.As(err, &fe) {
+			t.Fatalf("%s: 期望FieldErrors, 实际为 %v", name, err)
+		}
+		got := make(map[string]string)
+		for _, f := range fe.Fields() {
+			got[f.Field] = f.Rule
+		}
+		for field, rule := range want {
+			if got[field] != rule {
+				t.Errorf("%s: %s 期望规则 %s, 实际为 %q", name, field, rule, got[field])
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: 期望 %d 个错误, 实际为 %v", name, len(want), got)
+		}
+	}
+
+	// 多个文件合并后校验
+	override := writeTestFile(t, "override.yaml", "database:\n  dsn: override\n")
+	base := writeTestFile(t, "base.yaml", "server:\n  port: 9000\n")
+	if err := generated.ValidateFile(base, override); err != nil {
+		t.Errorf("合并后的配置未通过校验: %v", err)
+	}
+}
+
+// TestMinZeroValue 测试Validate和Schema对零值的min规则含义相同
+func TestMinZeroValue(t *testing.T) {
+	type rangeConfig struct {
+		Workers int    `mapstructure:"workers" validate:"min=1"`
+		Retries *int   `mapstructure:"retries" validate:"min=1"`
+		Name    string `mapstructure:"name" validate:"min=2"`
+	}
+	schema, err := JSONSchema(rangeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, check := range map[string]func() error{
+		"Validate": func() error { return Validate(&rangeConfig{}) },
+		"Schema":   func() error { return schema.Validate(map[string]any{"workers": 0, "name": ""}) },
+	} {
+		var fe *errs.FieldErrors
+		if err := check(); !errors.As(err, &fe) || fe.Len() != 2 {
+			t.Errorf("%s: 零值应该违反min规则, 未设置的指针不参与校验: %v", name, err)
+		}
+	}
+}
+
+// TestDurationLimit 测试时长字段的数字范围不会被当作字符串长度
+func TestDurationLimit(t *testing.T) {
+	type timeoutConfig struct {
+		Timeout time.Duration `mapstructure:"timeout" validate:"min=1000000000,max=1m"`
+	}
+	schema, err := JSONSchema(timeoutConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout := schema.Properties["timeout"]
+	if timeout.MinLength != nil || timeout.MaxLength != nil || timeout.Description != "(min 1s) (max 1m)" {
+		t.Errorf("timeout的Schema错误: %+v", timeout)
+	}
+	if err := schema.Validate(map[string]any{"timeout": "30s"}); err != nil {
+		t.Errorf("合法的时长未通过Schema校验: %v", err)
+	}
+	if err := Validate(&timeoutConfig{Timeout: 30 * time.Second}); err != nil {
+		t.Errorf("合法的时长未通过Validate: %v", err)
+	}
+}
+
+// TestSampleYAML 测试生成的示例配置可以被解析、符合Schema并包含默认值和说明
+func TestSampleYAML(t *testing.T) {
+	sample, err := SampleYAML(&ExampleConfig{})
+	if err != nil {
+		t.Fatalf("生成示例配置失败: %v", err)
+	}
+	var data map[string]any
+	if err := yaml.Unmarshal(sample, &data); err != nil {
+		t.Fatalf("示例配置不是合法的yaml: %v\n%s", err, sample)
+	}
+	for _, want := range []string{"# 监听端口 (必填; min=1; max=65535)", "  port: 8080", "# 日志级别 (可选值: debug | info | warn | error)"} {
+		if !strings.Contains(string(sample), want) {
+			t.Errorf("示例配置中缺少 %q:\n%s", want, sample)
+		}
+	}
+
+	s, _ := JSONSchema(&ExampleConfig{})
+	if err := s.Validate(data); err != nil {
+		t.Errorf("示例配置不符合Schema: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sample.yaml")
+	if err := os.WriteFile(path, sample, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SAMPLE_DATABASE_DSN", "dsn")
+	res, err := Load[ExampleConfig](WithFile(path), WithEnv("SAMPLE"))
+	if err != nil {
+		t.Fatalf("加载示例配置失败: %v", err)
+	}
+	if res.Config.Redis.Addr != "127.0.0.1:6379" || res.Config.Log.Format != "console" {
+		t.Errorf("示例配置的默认值错误: %+v", res.Config)
+	}
+
+	// 结构体列表生成一个示例元素
+	sample, _ = SampleYAML(&loadTestConfig{})
+	if !strings.Contains(string(sample), "backends:\n  # 必填\n  - addr: \"\"\n    weight: 1\n") {
+		t.Errorf("结构体列表的示例元素错误:\n%s", sample)
+	}
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/utils/encrypt"
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// secretMask 打印配置时替换敏感值的占位符
+const secretMask = "******"
+
+// secretRefRegexp 匹配${scheme:ref}形式的密钥引用,scheme未注册时不是密钥引用
+var secretRefRegexp = regexp.MustCompile(`\$\{([A-Za-z][A-Za-z0-9_-]*):([^}]*)\}`)
+
+// sensitiveKeys 名称中包含这些词的配置键在打印时会被隐藏
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "private_key", "dsn"}
+
+// SecretResolver 解析一种密钥引用,ref为"${scheme:ref}"中冒号之后的部分
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc 函数形式的SecretResolver
+type SecretResolverFunc func(ref string) (string, error)
+
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// EnvResolver 从环境变量读取,对应${env:NAME},变量不存在时返回错误
+var EnvResolver SecretResolver = SecretResolverFunc(func(ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errs.ErrArgs.WrapMsg("environment variable not set", "name", ref)
+	}
+	return v, nil
+})
+
+// FileResolver 从文件读取并去掉末尾的换行,对应${file:/run/secrets/db},适用于Docker/Kubernetes secrets
+var FileResolver SecretResolver = SecretResolverFunc(func(ref string) (string, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return "", errs.WrapMsg(err, "读取密钥文件失败", "file", ref)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+})
+
+// NewEncResolver 创建解密${enc:...}的SecretResolver,密文为encrypt.AesEncrypt结果的base64编码,
+// key长度为16、24或32字节。密文可以用EncryptSecret生成
+func NewEncResolver(key []byte) SecretResolver {
+	return SecretResolverFunc(func(ref string) (string, error) {
+		data, err := base64.StdEncoding.DecodeString(ref)
+		if err != nil {
+			return "", errs.WrapMsg(err, "密文不是有效的base64")
+		}
+		if len(data) == 0 || len(data)%16 != 0 {
+			return "", errs.ErrArgs.WrapMsg("invalid ciphertext length", "length", len(data))
+		}
+		plain, err := encrypt.AesDecrypt(data, key)
+		if err != nil {
+			return "", err
+		}
+		return string(plain), nil
+	})
+}
+
+// EncryptSecret 用key加密明文,返回可以直接写入配置文件的"${enc:...}"引用
+func EncryptSecret(plain string, key []byte) (string, error) {
+	data, err := encrypt.AesEncrypt([]byte(plain), key)
+	if err != nil {
+		return "", err
+	}
+	return "${enc:" + base64.StdEncoding.EncodeToString(data) + "}", nil
+}
+
+// Secrets 密钥引用解析器的集合,按scheme选择SecretResolver
+type Secrets struct {
+	mutex     sync.RWMutex
+	resolvers map[string]SecretResolver
+}
+
+// NewSecrets 创建已注册env和file的Secrets,需要解密时再注册enc:
+//
+//	s := config.NewSecrets()
+//	s.Register("enc", config.NewEncResolver(key))
+func NewSecrets() *Secrets {
+	s := &Secrets{resolvers: make(map[string]SecretResolver)}
+	s.Register("env", EnvResolver)
+	s.Register("file", FileResolver)
+	return s
+}
+
+// defaultSecrets 未指定Secrets时使用,只支持env和file
+var defaultSecrets = NewSecrets()
+
+// Register 注册或替换scheme对应的SecretResolver
+func (s *Secrets) Register(scheme string, r SecretResolver) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.resolvers[scheme] = r
+}
+
+// resolver 返回scheme对应的SecretResolver
+func (s *Secrets) resolver(scheme string) (SecretResolver, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	r, ok := s.resolvers[scheme]
+	return r, ok
+}
+
+// Resolve 替换value中所有的${scheme:ref}引用。scheme未注册的${NAME:-default}、${HOST:port}等
+// 不是密钥引用,原样保留
+func (s *Secrets) Resolve(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var firstErr error
+	out := secretRefRegexp.ReplaceAllStringFunc(value, func(m string) string {
+		sub := secretRefRegexp.FindStringSubmatch(m)
+		r, ok := s.resolver(sub[1])
+		if !ok {
+			return m
+		}
+		v, err := r.Resolve(sub[2])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errs.WrapMsg(err, "解析密钥引用失败", "scheme", sub[1])
+			}
+			return m
+		}
+		return v
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
+
+// resolveValue 解析配置值中的所有字符串,包括切片和map中的元素
+func (s *Secrets) resolveValue(raw any) (any, error) {
+	switch r := raw.(type) {
+	case string:
+		return s.Resolve(r)
+	case []any:
+		out := make([]any, len(r))
+		for i, item := range r {
+			v, err := s.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case map[string]any:
+		out := make(map[string]any, len(r))
+		for k, item := range r {
+			v, err := s.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+	return raw, nil
+}
+
+// decodeHook 在Viper解析到结构体时解析字符串中的密钥引用
+func (s *Secrets) decodeHook() mapstructure.DecodeHookFuncType {
+	return func(from, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+		return s.Resolve(data.(string))
+	}
+}
+
+// isRef 判断值中是否包含已注册scheme的密钥引用
+func (s *Secrets) isRef(value string) bool {
+	for _, sub := range secretRefRegexp.FindAllStringSubmatch(value, -1) {
+		if _, ok := s.resolver(sub[1]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSensitiveKey 判断配置键名是否表示敏感信息
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSettings 返回隐藏了敏感值的配置副本:包含密钥引用的值和敏感键名的值都替换为"******"
+func (s *Secrets) maskSettings(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		switch val := v.(type) {
+		case map[string]any:
+			out[k] = s.maskSettings(val)
+			continue
+		case string:
+			if s.isRef(val) {
+				out[k] = secretMask
+				continue
+			}
+		}
+		if isSensitiveKey(k) && v != nil && v != "" {
+			out[k] = secretMask
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
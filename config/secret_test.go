@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// TestSecretsResolve 测试各种密钥引用的解析
+func TestSecretsResolve(t *testing.T) {
+	t.Setenv("TEST_DB_PASS", "p@ss")
+	file := writeTestFile(t, "db", "from-file\n")
+	key := []byte("0123456789abcdef")
+	enc, err := EncryptSecret("from-enc", key)
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+
+	s := NewSecrets()
+	s.Register("enc", NewEncResolver(key))
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"${env:TEST_DB_PASS}", "p@ss"},
+		{"root:${env:TEST_DB_PASS}@tcp(db:3306)/app", "root:p@ss@tcp(db:3306)/app"},
+		{"${file:" + file + "}", "from-file"},
+		{enc, "from-enc"},
+		{"${HOME}", "${HOME}"},
+		{"${vault:db}", "${vault:db}"},
+		{"${FOO:-bar}:${HOST:8080}", "${FOO:-bar}:${HOST:8080}"},
+	}
+	for _, tt := range tests {
+		got, err := s.Resolve(tt.in)
+		if err != nil {
+			t.Errorf("解析 %q 失败: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("解析 %q 期望 %q, 实际为 %q", tt.in, tt.want, got)
+		}
+	}
+
+	for _, in := range []string{"${env:TEST_NOT_SET}", "${file:/not/exist}"} {
+		if _, err := s.Resolve(in); err == nil {
+			t.Errorf("解析 %q 应该返回错误", in)
+		}
+	}
+	if got, err := NewSecrets().Resolve(enc); err != nil || got != enc {
+		t.Errorf("未注册enc时应该原样保留: %q %v", got, err)
+	}
+}
+
+// TestLoadSecrets 测试类型化加载时解析密钥引用
+func TestLoadSecrets(t *testing.T) {
+	t.Setenv("TEST_DB_PASS", "p@ss")
+	file := writeTestFile(t, "app.yaml", `
+server:
+  port: 9000
+database:
+  dsn: root:${env:TEST_DB_PASS}@tcp(db:3306)/app
+tags: ["${env:TEST_DB_PASS}"]
+`)
+	res, err := Load[loadTestConfig](WithFile(file))
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if res.Config.Database.DSN != "root:p@ss@tcp(db:3306)/app" {
+		t.Errorf("dsn解析错误: %s", res.Config.Database.DSN)
+	}
+	if len(res.Config.Tags) != 1 || res.Config.Tags[0] != "p@ss" {
+		t.Errorf("切片中的引用解析错误: %v", res.Config.Tags)
+	}
+
+	file = writeTestFile(t, "bad.yaml", `
+database:
+  dsn: ${env:TEST_NOT_SET}
+`)
+	_, err = Load[loadTestConfig](WithFile(file))
+	var fe *errs.FieldErrors
+	if !errors.As(err, &fe) || fe.Fields()[0].Field != "database.dsn" || fe.Fields()[0].Rule != "secret" {
+		t.Errorf("期望database.dsn的secret错误, 实际为 %v", err)
+	}
+}
+
+// TestViperSecrets 测试Unmarshal解析密钥引用以及AllSettings隐藏敏感值
+func TestViperSecrets(t *testing.T) {
+	t.Setenv("TEST_DB_PASS", "p@ss")
+	key := []byte("0123456789abcdef")
+	enc, _ := EncryptSecret("redis-pass", key)
+	file := writeTestFile(t, "app.yaml", `
+database:
+  dsn: root:${env:TEST_DB_PASS}@tcp(db:3306)/app
+redis:
+  auth: "`+enc+`"
+  password: plain
+  addr: localhost:6379
+`)
+	s := NewSecrets()
+	s.Register("enc", NewEncResolver(key))
+	vc := NewViperConfig(WithSecretResolvers(s))
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+
+	var cfg struct {
+		Database struct {
+			DSN string `mapstructure:"dsn"`
+		} `mapstructure:"database"`
+		Redis struct {
+			Auth string `mapstructure:"auth"`
+			Addr string `mapstructure:"addr"`
+		} `mapstructure:"redis"`
+	}
+	if err := vc.Unmarshal(&cfg); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if cfg.Database.DSN != "root:p@ss@tcp(db:3306)/app" || cfg.Redis.Auth != "redis-pass" {
+		t.Errorf("密钥引用解析错误: %+v", cfg)
+	}
+
+	settings := vc.AllSettings()
+	redis := settings["redis"].(map[string]interface{})
+	if redis["auth"] != secretMask || redis["password"] != secretMask || redis["addr"] != "localhost:6379" {
+		t.Errorf("敏感值未隐藏: %v", redis)
+	}
+	if settings["database"].(map[string]interface{})["dsn"] != secretMask {
+		t.Errorf("dsn未隐藏: %v", settings["database"])
+	}
+	if vc.GetString("redis.password") != "plain" {
+		t.Error("AllSettings不应修改原配置")
+	}
+}
+
+// TestSecretsUnknownScheme 测试未注册scheme的${...}值原样加载
+func TestSecretsUnknownScheme(t *testing.T) {
+	file := writeTestFile(t, "app.yaml", `
+server:
+  host: ${FOO:-bar}
+database:
+  dsn: root@tcp(${DB_HOST:3306})/app
+`)
+	res, err := Load[loadTestConfig](WithFile(file))
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if res.Config.Server.Host != "${FOO:-bar}" || res.Config.Database.DSN != "root@tcp(${DB_HOST:3306})/app" {
+		t.Errorf("未注册scheme的值应该原样保留: %+v", res.Config)
+	}
+
+	vc := NewViperConfig()
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	var cfg loadTestConfig
+	if err := vc.Unmarshal(&cfg); err != nil || cfg.Server.Host != "${FOO:-bar}" {
+		t.Errorf("Unmarshal应该原样保留: %q %v", cfg.Server.Host, err)
+	}
+	if vc.AllSettings()["server"].(map[string]interface{})["host"] != "${FOO:-bar}" {
+		t.Error("未注册scheme的值不应该被隐藏")
+	}
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// Validate 按validate标签校验配置结构体,所有违规字段汇总到一个errs.FieldErrors中返回。
+// 支持的规则(以逗号分隔):
+//   - required 不能为零值
+//   - min=N、max=N 数字的取值范围,字符串、切片和map的长度范围,time.Duration可以写成"min=1s"
+//   - oneof=a b c 取值必须是以空格分隔的候选值之一
+//   - url 必须是包含scheme和host的URL
+//   - duration 字符串必须能被time.ParseDuration解析
+//
+// min、max与JSONSchema生成的minimum、minLength等含义相同,零值也会校验,可选字段使用指针类型(nil不参与校验);
+// 其余规则不校验零值字段
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return errs.ErrArgs.WrapMsg("config is nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errs.ErrArgs.WrapMsg("config must be a struct", "type", rv.Type().String())
+	}
+	fe := errs.NewFieldErrors()
+	validateStruct(rv, nil, fe)
+	return fe.ErrorOrNil()
+}
+
+func validateStruct(v reflect.Value, path []string, fe *errs.FieldErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, squash, ok := fieldKey(sf)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		if squash {
+			validateStruct(fv, path, fe)
+			continue
+		}
+		fieldPath := append(append([]string(nil), path...), name)
+		key := strings.Join(fieldPath, ".")
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			validateField(key, fv, tag, fe)
+		}
+		validateNested(fv, key, fe)
+	}
+}
+
+// validateNested 递归校验结构体、结构体指针以及切片和map中的结构体元素
+func validateNested(v reflect.Value, key string, fe *errs.FieldErrors) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			validateNested(v.Elem(), key, fe)
+		}
+	case reflect.Struct:
+		if isNestedStruct(v.Type()) {
+			validateStruct(v, strings.Split(key, "."), fe)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateNested(v.Index(i), fmt.Sprintf("%s[%d]", key, i), fe)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			validateNested(iter.Value(), fmt.Sprintf("%s[%v]", key, iter.Key().Interface()), fe)
+		}
+	}
+}
+
+func validateField(key string, v reflect.Value, tag string, fe *errs.FieldErrors) {
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "" {
+			continue
+		}
+		if name == "required" {
+			if v.IsZero() {
+				fe.Add(key, name, "is required")
+			}
+			continue
+		}
+		if v.IsZero() && (v.Kind() == reflect.Pointer || (name != "min" && name != "max")) {
+			continue
+		}
+		for v.Kind() == reflect.Pointer {
+			v = v.Elem()
+		}
+		if msg := checkRule(v, name, param); msg != "" {
+			fe.Add(key, name, msg)
+		}
+	}
+}
+
+// checkRule 检查单条规则,返回违规说明,通过时返回空字符串
+func checkRule(v reflect.Value, name, param string) string {
+	switch name {
+	case "min", "max":
+		return checkRange(v, name, param)
+	case "oneof":
+		options := strings.Fields(param)
+		s := fmt.Sprint(v.Interface())
+		for _, o := range options {
+			if s == o {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", strings.Join(options, " "))
+	case "url":
+		if v.Kind() != reflect.String {
+			return "url rule requires a string"
+		}
+		u, err := url.Parse(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid URL"
+		}
+		return ""
+	case "duration":
+		if v.Kind() != reflect.String {
+			return "duration rule requires a string"
+		}
+		if _, err := time.ParseDuration(v.String()); err != nil {
+			return "must be a valid duration"
+		}
+		return ""
+	}
+	return fmt.Sprintf("unknown validation rule %q", name)
+}
+
+func checkRange(v reflect.Value, name, param string) string {
+	var op = ">="
+	if name == "max" {
+		op = "<="
+	}
+	outOfRange := func(value, limit float64) bool {
+		if name == "min" {
+			return value < limit
+		}
+		return value > limit
+	}
+
+	if v.Type() == durationType {
+		limit, err := time.ParseDuration(param)
+		if err != nil {
+			n, nerr := strconv.ParseInt(param, 10, 64)
+			if nerr != nil {
+				return fmt.Sprintf("invalid %s parameter %q", name, param)
+			}
+			limit = time.Duration(n)
+		}
+		if outOfRange(float64(v.Int()), float64(limit)) {
+			return fmt.Sprintf("must be %s %s", op, limit)
+		}
+		return ""
+	}
+
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Sprintf("invalid %s parameter %q", name, param)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if outOfRange(float64(v.Int()), limit) {
+			return fmt.Sprintf("must be %s %s", op, param)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if outOfRange(float64(v.Uint()), limit) {
+			return fmt.Sprintf("must be %s %s", op, param)
+		}
+	case reflect.Float32, reflect.Float64:
+		if outOfRange(v.Float(), limit) {
+			return fmt.Sprintf("must be %s %s", op, param)
+		}
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if outOfRange(float64(v.Len()), limit) {
+			return fmt.Sprintf("length must be %s %s", op, param)
+		}
+	default:
+		return fmt.Sprintf("%s rule is not supported for %s", name, v.Type())
+	}
+	return ""
+}
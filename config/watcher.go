@@ -0,0 +1,334 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce 文件变化后等待的时间,合并编辑器保存时产生的多个事件
+const watchDebounce = 100 * time.Millisecond
+
+// Change 一次配置变更,Old和New是变更前后的完整快照,Changed为发生变化的配置键(已排序)
+type Change[T any] struct {
+	Old     *T
+	New     *T
+	Changed []string
+}
+
+// Watcher 类型化的配置热加载器。配置文件变化时重新解析并校验整个结构体,
+// 校验通过后原子地替换快照并通知订阅者;新配置非法时保留原快照并通知错误回调
+type Watcher[T any] struct {
+	load    func() (*Result[T], error)
+	current atomic.Pointer[Result[T]]
+
+	reloadMutex sync.Mutex // 保证同一时间只有一次重新加载
+
+	mutex      sync.Mutex
+	nextID     int
+	subs       map[int]func(Change[T])
+	errHandles []func(error)
+
+	fsw      *fsnotify.Watcher
+	debounce *time.Timer // 由mutex保护
+	done     chan struct{}
+	closed   sync.Once
+}
+
+// NewWatcher 使用Load[T]加载配置并监听WithFile指定的文件和WithProvider指定的远程来源,
+// 初始配置非法时返回错误
+func NewWatcher[T any](opts ...LoadOption) (*Watcher[T], error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	w, err := newWatcher(func() (*Result[T], error) { return Load[T](opts...) })
+	if err != nil {
+		return nil, err
+	}
+	if err := w.watchFiles(o.files); err != nil {
+		return nil, err
+	}
+	w.watchProviders(o.providers)
+	return w, nil
+}
+
+// NewViperWatcher 基于已加载的ViperConfig创建Watcher,配置文件变化时通过Unmarshal重新解析并用Validate校验。
+// 会调用vc.WatchConfig开始监听
+func NewViperWatcher[T any](vc *ViperConfig) (*Watcher[T], error) {
+	w, err := newWatcher(func() (*Result[T], error) {
+		cfg := new(T)
+		if err := vc.Unmarshal(cfg); err != nil {
+			return nil, err
+		}
+		if err := Validate(cfg); err != nil {
+			return nil, err
+		}
+		return &Result[T]{Config: cfg}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	vc.OnConfigChange(func() { _ = w.Reload() })
+	vc.WatchConfig()
+	return w, nil
+}
+
+func newWatcher[T any](load func() (*Result[T], error)) (*Watcher[T], error) {
+	res, err := load()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher[T]{
+		load: load,
+		subs: make(map[int]func(Change[T])),
+		done: make(chan struct{}),
+	}
+	w.current.Store(res)
+	return w, nil
+}
+
+// Get 返回当前配置快照,调用方不应修改返回的结构体
+func (w *Watcher[T]) Get() *T {
+	return w.current.Load().Config
+}
+
+// Snapshot 返回当前快照及每个配置键的来源
+func (w *Watcher[T]) Snapshot() *Result[T] {
+	return w.current.Load()
+}
+
+// Subscribe 订阅配置变更,返回取消订阅的函数。回调在重新加载的goroutine中按注册顺序同步调用
+func (w *Watcher[T]) Subscribe(fn func(Change[T])) (unsubscribe func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	id := w.nextID
+	w.nextID++
+	w.subs[id] = fn
+	return func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// OnError 注册重新加载失败时的回调,此时仍在使用原快照
+func (w *Watcher[T]) OnError(fn func(error)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.errHandles = append(w.errHandles, fn)
+}
+
+// Reload 立即重新加载配置。新配置非法时保留原快照并返回错误;
+// 没有任何配置键变化时不通知订阅者。Close之后返回错误且不再通知订阅者
+func (w *Watcher[T]) Reload() error {
+	w.reloadMutex.Lock()
+	defer w.reloadMutex.Unlock()
+
+	if w.isClosed() {
+		return errs.ErrArgs.WrapMsg("watcher is closed")
+	}
+	res, err := w.load()
+	if err != nil {
+		err = errs.WrapMsg(err, "重新加载配置失败,继续使用原配置")
+		w.mutex.Lock()
+		handlers := append([]func(error){}, w.errHandles...)
+		w.mutex.Unlock()
+		for _, h := range handlers {
+			h(err)
+		}
+		return err
+	}
+
+	old := w.current.Load()
+	changed := Diff(old.Config, res.Config)
+	if len(changed) == 0 || w.isClosed() {
+		return nil
+	}
+	w.current.Store(res)
+
+	w.mutex.Lock()
+	ids := make([]int, 0, len(w.subs))
+	for id := range w.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	subs := make([]func(Change[T]), 0, len(ids))
+	for _, id := range ids {
+		subs = append(subs, w.subs[id])
+	}
+	w.mutex.Unlock()
+
+	change := Change[T]{Old: old.Config, New: res.Config, Changed: changed}
+	for _, fn := range subs {
+		fn(change)
+	}
+	return nil
+}
+
+// watchFiles 监听配置文件所在目录,以便在编辑器通过重命名保存文件时也能收到通知
+func (w *Watcher[T]) watchFiles(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errs.WrapMsg(err, "创建文件监听失败")
+	}
+	names := make(map[string]struct{}, len(files))
+	dirs := make(map[string]struct{})
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			fsw.Close()
+			return errs.WrapMsg(err, "解析配置文件路径失败", "file", f)
+		}
+		names[abs] = struct{}{}
+		dirs[filepath.Dir(abs)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := fsw.Add(dir); err != nil {
+			fsw.Close()
+			return errs.WrapMsg(err, "监听配置目录失败", "dir", dir)
+		}
+	}
+	w.fsw = fsw
+
+	go func() {
+		for {
+			select {
+			case <-w.done:
+				return
+			case e, ok := <-fsw.Events:
+				if !ok {
+					return
+				}
+				if _, ok := names[filepath.Clean(e.Name)]; !ok || e.Op == fsnotify.Chmod {
+					continue
+				}
+				w.scheduleReload()
+			case _, ok := <-fsw.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// scheduleReload 在watchDebounce后重新加载,期间的文件变化会推迟重新加载
+func (w *Watcher[T]) scheduleReload() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+	if w.debounce == nil {
+		w.debounce = time.AfterFunc(watchDebounce, func() {
+			select {
+			case <-w.done:
+			default:
+				_ = w.Reload()
+			}
+		})
+	} else {
+		w.debounce.Reset(watchDebounce)
+	}
+}
+
+// watchProviders 监听远程配置来源,任一来源变化时重新加载
+func (w *Watcher[T]) watchProviders(providers []remoteSource) {
+	if len(providers) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-w.done
+		cancel()
+	}()
+	for _, rs := range providers {
+		go func(p Provider) {
+			_ = p.Watch(ctx, func([]byte) { _ = w.Reload() })
+		}(rs.provider)
+	}
+}
+
+func (w *Watcher[T]) isClosed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close 停止监听配置文件和远程配置来源,之后不再重新加载配置
+func (w *Watcher[T]) Close() error {
+	var err error
+	w.closed.Do(func() {
+		w.mutex.Lock()
+		close(w.done)
+		if w.debounce != nil {
+			w.debounce.Stop()
+		}
+		w.mutex.Unlock()
+		if w.fsw != nil {
+			err = w.fsw.Close()
+		}
+	})
+	return err
+}
+
+// Diff 比较两个相同类型的配置结构体,返回值不同的配置键(键名规则与Load相同,已排序)。
+// 切片和map作为整体比较,任一参数为nil时返回nil
+func Diff[T any](old, new *T) []string {
+	if old == nil || new == nil {
+		return nil
+	}
+	var changed []string
+	diffValue(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), nil, &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+func diffValue(a, b reflect.Value, path []string, changed *[]string) {
+	if a.Kind() == reflect.Pointer && isNestedStruct(a.Type()) {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return
+		case a.IsNil() || b.IsNil():
+			*changed = append(*changed, strings.Join(path, "."))
+			return
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() != reflect.Struct || !isNestedStruct(a.Type()) {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changed = append(*changed, strings.Join(path, "."))
+		}
+		return
+	}
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, squash, ok := fieldKey(t.Field(i))
+		if !ok {
+			continue
+		}
+		if squash {
+			diffValue(a.Field(i), b.Field(i), path, changed)
+			continue
+		}
+		diffValue(a.Field(i), b.Field(i), append(append([]string(nil), path...), name), changed)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// watchTestConfig 测试热加载的配置结构体
+type watchTestConfig struct {
+	Server struct {
+		Host string `mapstructure:"host"`
+		Port int    `mapstructure:"port" validate:"required,min=1,max=65535"`
+	} `mapstructure:"server"`
+	Tags []string `mapstructure:"tags"`
+}
+
+// TestWatcherReload 测试重新加载、变更通知和非法配置回滚
+func TestWatcherReload(t *testing.T) {
+	file := writeTestFile(t, "watch.yaml", "server:\n  host: a\n  port: 8080\n")
+	w, err := NewWatcher[watchTestConfig](WithFile(file))
+	if err != nil {
+		t.Fatalf("创建 Watcher 失败: %v", err)
+	}
+	defer w.Close()
+
+	changes := make(chan Change[watchTestConfig], 10)
+	unsubscribe := w.Subscribe(func(c Change[watchTestConfig]) { changes <- c })
+	errors := make(chan error, 10)
+	w.OnError(func(err error) { errors <- err })
+
+	// 合法变更
+	os.WriteFile(file, []byte("server:\n  host: a\n  port: 9090\ntags: [x]\n"), 0644)
+	if err := w.Reload(); err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+	c := <-changes
+	if c.Old.Server.Port != 8080 || c.New.Server.Port != 9090 {
+		t.Errorf("变更前后的值错误，实际: %d -> %d", c.Old.Server.Port, c.New.Server.Port)
+	}
+	if !reflect.DeepEqual(c.Changed, []string{"server.port", "tags"}) {
+		t.Errorf("变更的键错误，实际: %v", c.Changed)
+	}
+	if w.Get().Server.Port != 9090 {
+		t.Errorf("快照未更新，实际: %d", w.Get().Server.Port)
+	}
+
+	// 非法变更保留原快照
+	os.WriteFile(file, []byte("server:\n  host: b\n  port: 70000\n"), 0644)
+	if err := w.Reload(); err == nil {
+		t.Error("非法配置应该返回错误")
+	}
+	select {
+	case <-errors:
+	default:
+		t.Error("非法配置应该通知错误回调")
+	}
+	if w.Get().Server.Port != 9090 || w.Get().Server.Host != "a" {
+		t.Errorf("非法配置应该回滚，实际: %+v", w.Get().Server)
+	}
+
+	// 取消订阅后不再通知
+	unsubscribe()
+	os.WriteFile(file, []byte("server:\n  host: c\n  port: 9090\n"), 0644)
+	w.Reload()
+	select {
+	case c := <-changes:
+		t.Errorf("取消订阅后不应该收到通知: %v", c.Changed)
+	default:
+	}
+}
+
+// TestWatcherFileChange 测试文件变化自动触发重新加载
+func TestWatcherFileChange(t *testing.T) {
+	file := writeTestFile(t, "watch.yaml", "server:\n  port: 8080\n")
+	w, err := NewWatcher[watchTestConfig](WithFile(file))
+	if err != nil {
+		t.Fatalf("创建 Watcher 失败: %v", err)
+	}
+	defer w.Close()
+
+	changes := make(chan Change[watchTestConfig], 10)
+	w.Subscribe(func(c Change[watchTestConfig]) { changes <- c })
+
+	os.WriteFile(file, []byte("server:\n  port: 8081\n"), 0644)
+	select {
+	case c := <-changes:
+		if c.New.Server.Port != 8081 {
+			t.Errorf("新配置错误，实际: %d", c.New.Server.Port)
+		}
+	case <-time.After(2 * time.Second):
+		t.Skip("配置变更监听测试跳过（可能是文件系统不支持）")
+	}
+}
+
+// TestWatcherCloseStopsReload 测试Close后不再执行等待中的重新加载
+func TestWatcherCloseStopsReload(t *testing.T) {
+	file := writeTestFile(t, "close.yaml", "server:\n  port: 8080\n")
+	w, err := NewWatcher[watchTestConfig](WithFile(file))
+	if err != nil {
+		t.Fatalf("创建 Watcher 失败: %v", err)
+	}
+	changes := make(chan Change[watchTestConfig], 10)
+	w.Subscribe(func(c Change[watchTestConfig]) { changes <- c })
+
+	os.WriteFile(file, []byte("server:\n  port: 8081\n"), 0644)
+	w.scheduleReload()
+	w.Close()
+	w.scheduleReload()
+	select {
+	case c := <-changes:
+		t.Errorf("Close后不应该重新加载, 实际为 %d", c.New.Server.Port)
+	case <-time.After(3 * watchDebounce):
+	}
+}
+
+// TestViperWatcherClose 测试Close后配置文件变化不再通知订阅者
+func TestViperWatcherClose(t *testing.T) {
+	file := writeTestFile(t, "viper.yaml", "server:\n  port: 8080\n")
+	vc := NewViperConfig()
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	w, err := NewViperWatcher[watchTestConfig](vc)
+	if err != nil {
+		t.Fatalf("创建 Watcher 失败: %v", err)
+	}
+	changes := make(chan Change[watchTestConfig], 10)
+	w.Subscribe(func(c Change[watchTestConfig]) { changes <- c })
+	fileChanged := make(chan struct{}, 10)
+	vc.OnConfigChange(func() { fileChanged <- struct{}{} })
+	w.Close()
+
+	os.WriteFile(file, []byte("server:\n  port: 8081\n"), 0644)
+	select {
+	case <-fileChanged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到配置文件变化的通知")
+	}
+	if err := w.Reload(); err == nil {
+		t.Error("Close后Reload应该返回错误")
+	}
+	select {
+	case c := <-changes:
+		t.Errorf("Close后不应该通知订阅者, 实际为 %d", c.New.Server.Port)
+	default:
+	}
+	if w.Get().Server.Port != 8080 {
+		t.Errorf("Close后不应该更新快照, 实际为 %d", w.Get().Server.Port)
+	}
+}
@@ -0,0 +1,265 @@
+package env
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// DotenvOption LoadDotenv和ReadDotenv的配置选项
+type DotenvOption func(*dotenvOptions)
+
+type dotenvOptions struct {
+	noOverride  bool
+	skipMissing bool
+	overridable func(key string) bool
+}
+
+// keep 判断是否保留进程中已有的变量
+func (o *dotenvOptions) keep(key string) bool {
+	if !o.noOverride {
+		return false
+	}
+	if _, ok := os.LookupEnv(key); !ok {
+		return false
+	}
+	return o.overridable == nil || !o.overridable(key)
+}
+
+// NoOverride 不覆盖进程中已经设置的环境变量,${VAR}展开时也优先使用进程中的值
+func NoOverride() DotenvOption {
+	return func(o *dotenvOptions) {
+		o.noOverride = true
+	}
+}
+
+// Overridable 与NoOverride一起使用,fn返回true的已有变量仍然会被覆盖,${VAR}展开时也不使用进程中的值。
+// 用于重新加载时更新之前由.env设置的变量
+func Overridable(fn func(key string) bool) DotenvOption {
+	return func(o *dotenvOptions) {
+		o.overridable = fn
+	}
+}
+
+// SkipMissing 忽略不存在的文件,用于可选的.env.local等文件
+func SkipMissing() DotenvOption {
+	return func(o *dotenvOptions) {
+		o.skipMissing = true
+	}
+}
+
+// LoadDotenv 按顺序读取.env文件并设置为进程的环境变量,后面文件中的变量覆盖前面的,
+// 默认也覆盖进程中已有的变量,使用NoOverride时保留已有的变量。文件格式见ParseDotenv
+func LoadDotenv(files []string, opts ...DotenvOption) error {
+	var o dotenvOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	vars, err := readDotenv(files, &o)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if o.keep(k) {
+			continue
+		}
+		if err := os.Setenv(k, vars[k]); err != nil {
+			return errs.WrapMsg(err, "设置环境变量失败", "key", k)
+		}
+	}
+	return nil
+}
+
+// ReadDotenv 按顺序读取.env文件并返回合并后的变量,不修改进程的环境变量
+func ReadDotenv(files []string, opts ...DotenvOption) (map[string]string, error) {
+	var o dotenvOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return readDotenv(files, &o)
+}
+
+func readDotenv(files []string, o *dotenvOptions) (map[string]string, error) {
+	vars := make(map[string]string)
+	lookup := func(key string) (string, bool) {
+		if o.keep(key) {
+			return os.LookupEnv(key)
+		}
+		if v, ok := vars[key]; ok {
+			return v, true
+		}
+		return os.LookupEnv(key)
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			if o.skipMissing && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, errs.WrapMsg(err, "读取.env文件失败", "file", file)
+		}
+		err = parseDotenv(f, lookup, func(k, v string) { vars[k] = v })
+		f.Close()
+		if err != nil {
+			return nil, errs.WrapMsg(err, "解析.env文件失败", "file", file)
+		}
+	}
+	return vars, nil
+}
+
+// ParseDotenv 解析.env格式的内容,${VAR}引用前面定义的变量或进程的环境变量。支持的写法:
+//
+//	# 注释
+//	export NAME=value          # 可以带export前缀,行尾的" #"之后为注释
+//	URL=http://${HOST}:$PORT   # 未加引号和双引号中的$VAR、${VAR}会被展开
+//	LEVEL=${LOG_LEVEL:-info}   # 变量未设置或为空时使用默认值
+//	MSG="line1\nline2"         # 双引号支持\n、\t、\"、\\和\$转义,可以跨行
+//	RAW='no ${expansion}'      # 单引号中的内容原样保留,可以跨行
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	lookup := func(key string) (string, bool) {
+		if v, ok := vars[key]; ok {
+			return v, true
+		}
+		return os.LookupEnv(key)
+	}
+	if err := parseDotenv(r, lookup, func(k, v string) { vars[k] = v }); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseDotenv(r io.Reader, lookup func(string) (string, bool), set func(k, v string)) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		key, rest, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validKey(key) {
+			return errs.ErrArgs.WrapMsg("invalid dotenv line, expected KEY=VALUE", "line", lineNo)
+		}
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			quote := rest[0]
+			// 引号内的值可以跨行,找到未转义的闭合引号为止
+			raw := rest[1:]
+			end := closingQuote(raw, quote)
+			for end < 0 && i+1 < len(lines) {
+				i++
+				raw += "\n" + lines[i]
+				end = closingQuote(raw, quote)
+			}
+			if end < 0 {
+				return errs.ErrArgs.WrapMsg("unterminated quoted value", "line", lineNo, "key", key)
+			}
+			if tail := strings.TrimSpace(raw[end+1:]); tail != "" && !strings.HasPrefix(tail, "#") {
+				return errs.ErrArgs.WrapMsg("unexpected characters after quoted value", "line", lineNo, "key", key)
+			}
+			value = raw[:end]
+			if quote == '"' {
+				value = expand(unescape(value), lookup)
+			}
+		} else {
+			if idx := strings.Index(rest, " #"); idx >= 0 {
+				rest = rest[:idx]
+			} else if idx := strings.Index(rest, "\t#"); idx >= 0 {
+				rest = rest[:idx]
+			}
+			value = expand(strings.TrimSpace(rest), lookup)
+		}
+		set(key, value)
+	}
+	return nil
+}
+
+// validKey 变量名只能包含字母、数字、下划线和".",且不能以数字开头
+func validKey(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range key {
+		if !(c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// closingQuote 返回闭合引号的位置,双引号中的\"不算闭合
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape 处理双引号中的转义,\$转为"$$",展开时还原为"$"
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '$':
+			b.WriteString("$$")
+		case '"', '\\':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// expand 展开$VAR、${VAR}和${VAR:-default},"$$"展开为"$"
+func expand(s string, lookup func(string) (string, bool)) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		name, def, hasDef := strings.Cut(name, ":-")
+		if v, ok := lookup(name); ok && (v != "" || !hasDef) {
+			return v
+		}
+		return def
+	})
+}
@@ -0,0 +1,132 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseDotenv 测试.env文件的各种写法
+func TestParseDotenv(t *testing.T) {
+	t.Setenv("DOTENV_TEST_HOME", "/home/test")
+	content := `
+# 注释
+export APP_NAME=demo
+HOST = localhost
+PORT=8080 # 行尾注释
+URL=http://${HOST}:$PORT/path#frag
+HOME_DIR=${DOTENV_TEST_HOME}/app
+LEVEL=${UNSET_LEVEL:-info}
+EMPTY=
+QUOTED="hello \"world\"\n\t$APP_NAME \$HOME" # 注释
+SINGLE='raw ${HOST} \n'
+MULTI="line1
+line2"
+HASH="a # not comment"
+`
+	got, err := ParseDotenv(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := map[string]string{
+		"APP_NAME": "demo",
+		"HOST":     "localhost",
+		"PORT":     "8080",
+		"URL":      "http://localhost:8080/path#frag",
+		"HOME_DIR": "/home/test/app",
+		"LEVEL":    "info",
+		"EMPTY":    "",
+		"QUOTED":   "hello \"world\"\n\tdemo $HOME",
+		"SINGLE":   `raw ${HOST} \n`,
+		"MULTI":    "line1\nline2",
+		"HASH":     "a # not comment",
+	}
+	if !reflect.DeepEqual(got, want) {
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s 期望 %q, 实际为 %q", k, v, got[k])
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("期望 %d 个变量, 实际为 %v", len(want), got)
+		}
+	}
+}
+
+// TestParseDotenvErrors 测试格式错误
+func TestParseDotenvErrors(t *testing.T) {
+	for _, content := range []string{
+		"NOEQUALS",
+		"1KEY=value",
+		"BAD KEY=value",
+		`KEY="unterminated`,
+		`KEY="value" trailing`,
+	} {
+		if _, err := ParseDotenv(strings.NewReader(content)); err == nil {
+			t.Errorf("%q 应该返回错误", content)
+		}
+	}
+}
+
+// TestLoadDotenv 测试多个文件的覆盖顺序、NoOverride和Overridable
+func TestLoadDotenv(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	writeTestFile(t, base, "DOTENV_A=base\nDOTENV_B=base\nDOTENV_C=base\n")
+	writeTestFile(t, local, "DOTENV_B=local\nDOTENV_D=${DOTENV_C}-${DOTENV_B}\n")
+
+	t.Setenv("DOTENV_C", "process")
+	t.Setenv("DOTENV_A", "")
+	os.Unsetenv("DOTENV_A")
+	t.Setenv("DOTENV_B", "")
+	os.Unsetenv("DOTENV_B")
+	t.Setenv("DOTENV_D", "")
+	os.Unsetenv("DOTENV_D")
+
+	vars, err := ReadDotenv([]string{base, local})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["DOTENV_B"] != "local" || vars["DOTENV_D"] != "base-local" {
+		t.Errorf("后面的文件应该覆盖前面的: %v", vars)
+	}
+
+	if err := LoadDotenv([]string{base, local, filepath.Join(dir, "missing")}, NoOverride(), SkipMissing()); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"DOTENV_A": "base", "DOTENV_B": "local", "DOTENV_C": "process", "DOTENV_D": "process-local"} {
+		if got := os.Getenv(k); got != v {
+			t.Errorf("%s 期望 %q, 实际为 %q", k, v, got)
+		}
+	}
+
+	// Overridable返回true的已有变量仍然被覆盖
+	writeTestFile(t, base, "DOTENV_A=changed\nDOTENV_C=changed\n")
+	overridable := Overridable(func(key string) bool { return key == "DOTENV_A" })
+	if err := LoadDotenv([]string{base}, NoOverride(), overridable); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("DOTENV_A") != "changed" || os.Getenv("DOTENV_C") != "process" {
+		t.Errorf("Overridable未生效: %s %s", os.Getenv("DOTENV_A"), os.Getenv("DOTENV_C"))
+	}
+
+	if err := LoadDotenv([]string{base}); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("DOTENV_C") != "changed" {
+		t.Error("默认应该覆盖已有的环境变量")
+	}
+	if err := LoadDotenv([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("文件不存在时应该返回错误")
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
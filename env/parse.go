@@ -0,0 +1,138 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/internal/conv"
+)
+
+// Option Parse的配置选项
+type Option func(*parseOptions)
+
+type parseOptions struct {
+	prefix string
+	lookup func(string) (string, bool)
+}
+
+// WithPrefix 为所有环境变量名添加前缀,如前缀"APP_"时env:"PORT"读取APP_PORT
+func WithPrefix(prefix string) Option {
+	return func(o *parseOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithLookup 设置读取环境变量的函数,默认为os.LookupEnv
+func WithLookup(lookup func(string) (string, bool)) Option {
+	return func(o *parseOptions) {
+		o.lookup = lookup
+	}
+}
+
+// Parse 按env标签从环境变量填充结构体,v必须是结构体指针。支持的标签:
+//   - env:"NAME" 环境变量名,可以追加选项,如env:"NAME,required"或env:"NAME,notEmpty"。
+//     required要求变量已设置,notEmpty要求变量已设置且不为空
+//   - default:"value" 变量未设置时使用的默认值
+//   - envPrefix:"DB_" 用于嵌套结构体,为其中所有变量名添加前缀
+//   - envSeparator:";" 切片和map元素的分隔符,默认为","
+//   - layout:"2006-01-02" time.Time的格式,默认依次尝试RFC3339、"2006-01-02 15:04:05"和"2006-01-02"
+//
+// 支持字符串、布尔、整数、浮点数、time.Duration、time.Time、url.URL、实现了encoding.TextUnmarshaler的类型,
+// 以及它们的指针、切片和map(写法为"k1=v1,k2=v2")。没有env标签的结构体字段会被递归填充。
+// 所有缺失和无法解析的变量会汇总到一个errs.FieldErrors中返回,字段名为环境变量名
+func Parse(v any, opts ...Option) error {
+	o := parseOptions{lookup: os.LookupEnv}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errs.ErrArgs.WrapMsg("env.Parse requires a non-nil struct pointer", "type", fmt.Sprintf("%T", v))
+	}
+	fe := errs.NewFieldErrors()
+	parseStruct(rv.Elem(), o.prefix, &o, fe)
+	return fe.ErrorOrNil()
+}
+
+// MustParse 与Parse相同,出错时panic,用于程序启动阶段
+func MustParse(v any, opts ...Option) {
+	if err := Parse(v, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func parseStruct(v reflect.Value, prefix string, o *parseOptions, fe *errs.FieldErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		tag, hasTag := sf.Tag.Lookup("env")
+		if tag == "-" {
+			continue
+		}
+		if !hasTag {
+			if isNested(sf.Type) {
+				parseNested(fv, prefix+sf.Tag.Get("envPrefix"), o, fe)
+			}
+			continue
+		}
+
+		name, flags, _ := strings.Cut(tag, ",")
+		name = prefix + name
+		var required, notEmpty bool
+		for _, f := range strings.Split(flags, ",") {
+			switch strings.TrimSpace(f) {
+			case "required":
+				required = true
+			case "notEmpty":
+				required, notEmpty = true, true
+			}
+		}
+
+		value, ok := o.lookup(name)
+		if !ok {
+			if def, hasDef := sf.Tag.Lookup("default"); hasDef {
+				value, ok = def, true
+			} else if required {
+				fe.Add(name, "required", "is required")
+				continue
+			}
+		}
+		if !ok {
+			continue
+		}
+		if notEmpty && value == "" {
+			fe.Add(name, "notEmpty", "must not be empty")
+			continue
+		}
+		opts := conv.Options{Sep: sf.Tag.Get("envSeparator"), Layout: sf.Tag.Get("layout")}
+		if err := conv.SetValue(fv, value, &opts); err != nil {
+			fe.Add(name, "parse", err.Error())
+		}
+	}
+}
+
+// parseNested 填充嵌套结构体,nil的结构体指针会被分配
+func parseNested(v reflect.Value, prefix string, o *parseOptions, fe *errs.FieldErrors) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	parseStruct(v, prefix, o, fe)
+}
+
+// isNested 判断字段是否为需要递归填充的结构体,time.Time、url.URL和TextUnmarshaler作为单个值处理
+func isNested(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !conv.IsScalar(t)
+}
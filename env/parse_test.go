@@ -0,0 +1,165 @@
+package env
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "debug":
+		*l = 1
+	case "info":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+type dbConfig struct {
+	Host     string        `env:"HOST" default:"localhost"`
+	Port     int           `env:"PORT" default:"3306"`
+	Password string        `env:"PASSWORD,notEmpty"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"5s"`
+}
+
+type appConfig struct {
+	Name      string            `env:"NAME,required"`
+	Debug     bool              `env:"DEBUG"`
+	Rate      float64           `env:"RATE"`
+	Tags      []string          `env:"TAGS"`
+	Ports     []int             `env:"PORTS" envSeparator:";"`
+	Labels    map[string]string `env:"LABELS"`
+	Weights   map[string]int    `env:"WEIGHTS"`
+	Start     time.Time         `env:"START"`
+	Day       time.Time         `env:"DAY" layout:"2006-01-02"`
+	Endpoint  url.URL           `env:"ENDPOINT"`
+	Callback  *url.URL          `env:"CALLBACK"`
+	Level     level             `env:"LEVEL" default:"info"`
+	IP        net.IP            `env:"IP"`
+	MaxConns  *int              `env:"MAX_CONNS"`
+	DB        dbConfig          `envPrefix:"DB_"`
+	Cache     *dbConfig         `envPrefix:"CACHE_"`
+	Ignored   string            `env:"-"`
+	unexposed string
+}
+
+func mapLookup(m map[string]string) Option {
+	return WithLookup(func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	})
+}
+
+// TestParse 测试各种类型字段的填充
+func TestParse(t *testing.T) {
+	vars := map[string]string{
+		"APP_NAME":           "demo",
+		"APP_DEBUG":          "true",
+		"APP_RATE":           "0.5",
+		"APP_TAGS":           "a, b ,c",
+		"APP_PORTS":          "80;443",
+		"APP_LABELS":         "env=prod,team=infra",
+		"APP_WEIGHTS":        "a=1,b=2",
+		"APP_START":          "2025-01-02T03:04:05Z",
+		"APP_DAY":            "2025-06-01",
+		"APP_ENDPOINT":       "https://example.com/api",
+		"APP_CALLBACK":       "http://cb.local/hook",
+		"APP_IP":             "10.0.0.1",
+		"APP_MAX_CONNS":      "0x10",
+		"APP_DB_HOST":        "db.local",
+		"APP_DB_PASSWORD":    "secret",
+		"APP_CACHE_PASSWORD": "cache-secret",
+		"APP_CACHE_TIMEOUT":  "1m",
+	}
+	var cfg appConfig
+	if err := Parse(&cfg, WithPrefix("APP_"), mapLookup(vars)); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	if cfg.Name != "demo" || !cfg.Debug || cfg.Rate != 0.5 {
+		t.Errorf("基本类型解析错误: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b", "c"}) || !reflect.DeepEqual(cfg.Ports, []int{80, 443}) {
+		t.Errorf("切片解析错误: %v %v", cfg.Tags, cfg.Ports)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]string{"env": "prod", "team": "infra"}) || cfg.Weights["b"] != 2 {
+		t.Errorf("map解析错误: %v %v", cfg.Labels, cfg.Weights)
+	}
+	if !cfg.Start.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) || cfg.Day.Month() != time.June {
+		t.Errorf("时间解析错误: %v %v", cfg.Start, cfg.Day)
+	}
+	if cfg.Endpoint.Host != "example.com" || cfg.Callback == nil || cfg.Callback.Path != "/hook" {
+		t.Errorf("URL解析错误: %v %v", cfg.Endpoint, cfg.Callback)
+	}
+	if cfg.Level != 2 || cfg.IP.String() != "10.0.0.1" {
+		t.Errorf("TextUnmarshaler解析错误: %v %v", cfg.Level, cfg.IP)
+	}
+	if cfg.MaxConns == nil || *cfg.MaxConns != 16 {
+		t.Errorf("指针解析错误: %v", cfg.MaxConns)
+	}
+	if cfg.DB.Host != "db.local" || cfg.DB.Port != 3306 || cfg.DB.Password != "secret" || cfg.DB.Timeout != 5*time.Second {
+		t.Errorf("嵌套结构体解析错误: %+v", cfg.DB)
+	}
+	if cfg.Cache == nil || cfg.Cache.Host != "localhost" || cfg.Cache.Timeout != time.Minute {
+		t.Errorf("嵌套结构体指针解析错误: %+v", cfg.Cache)
+	}
+}
+
+// TestParseErrors 测试所有缺失和非法的变量一次性返回
+func TestParseErrors(t *testing.T) {
+	vars := map[string]string{
+		"DEBUG":       "maybe",
+		"PORTS":       "80;x",
+		"LABELS":      "novalue",
+		"LEVEL":       "trace",
+		"DB_PASSWORD": "",
+		"DB_TIMEOUT":  "5",
+	}
+	var cfg appConfig
+	err := Parse(&cfg, mapLookup(vars))
+	var fe *errs.FieldErrors
+	if !errors.As(err, &fe) {
+		t.Fatalf("期望返回FieldErrors, 实际为 %v", err)
+	}
+	want := map[string]string{
+		"NAME":           "required",
+		"DEBUG":          "parse",
+		"PORTS":          "parse",
+		"LABELS":         "parse",
+		"LEVEL":          "parse",
+		"DB_PASSWORD":    "notEmpty",
+		"DB_TIMEOUT":     "parse",
+		"CACHE_PASSWORD": "required",
+	}
+	got := make(map[string]string)
+	for _, f := range fe.Fields() {
+		got[f.Field] = f.Rule
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("期望错误 %v, 实际为 %v", want, got)
+	}
+	if !strings.Contains(err.Error(), "PORTS: element 1") {
+		t.Errorf("错误信息应该包含元素位置: %v", err)
+	}
+}
+
+// TestParseInvalidTarget 测试非结构体指针参数
+func TestParseInvalidTarget(t *testing.T) {
+	var cfg appConfig
+	for _, v := range []any{cfg, (*appConfig)(nil), new(int)} {
+		if err := Parse(v); err == nil {
+			t.Errorf("%T 应该返回错误", v)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package errs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// FieldError 单个字段的错误
+type FieldError struct {
+	Field string // 字段路径,如"server.port"
+	Rule  string // 违反的规则,如"required"、"min"
+	Msg   string // 错误说明
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Msg
+}
+
+// FieldErrors 多个字段错误的集合,用于一次性返回所有字段的问题(如配置校验)。
+// 实现了CodeError接口,错误码为ArgsError
+type FieldErrors struct {
+	fields []FieldError
+	detail string
+}
+
+// NewFieldErrors 创建空的字段错误集合
+func NewFieldErrors() *FieldErrors {
+	return &FieldErrors{}
+}
+
+// Add 添加一个字段错误
+func (e *FieldErrors) Add(field, rule, msg string) {
+	e.fields = append(e.fields, FieldError{Field: field, Rule: rule, Msg: msg})
+}
+
+// Merge 合并另一个集合中的字段错误
+func (e *FieldErrors) Merge(other *FieldErrors) {
+	if other != nil {
+		e.fields = append(e.fields, other.fields...)
+	}
+}
+
+// Fields 返回所有字段错误
+func (e *FieldErrors) Fields() []FieldError {
+	return e.fields
+}
+
+// Len 返回字段错误的数量
+func (e *FieldErrors) Len() int {
+	return len(e.fields)
+}
+
+// ErrorOrNil 没有字段错误时返回nil,否则返回带堆栈追踪的错误
+func (e *FieldErrors) ErrorOrNil() error {
+	if e == nil || len(e.fields) == 0 {
+		return nil
+	}
+	return e.Wrap()
+}
+
+func (e *FieldErrors) Code() int {
+	return ArgsError
+}
+
+func (e *FieldErrors) Msg() string {
+	return ErrArgs.Msg()
+}
+
+// Detail 返回所有字段错误的描述,以"; "分隔
+func (e *FieldErrors) Detail() string {
+	v := make([]string, 0, len(e.fields)+1)
+	for _, f := range e.fields {
+		v = append(v, f.String())
+	}
+	if e.detail != "" {
+		v = append(v, e.detail)
+	}
+	return strings.Join(v, "; ")
+}
+
+func (e *FieldErrors) WithDetail(detail string) CodeError {
+	d := detail
+	if e.detail != "" {
+		d = e.detail + ", " + detail
+	}
+	return &FieldErrors{fields: e.fields, detail: d}
+}
+
+// Is 与CodeError相同,按错误码及错误码关系判断
+func (e *FieldErrors) Is(err error) bool {
+	var codeErr CodeError
+	if !errors.As(Unwrap(err), &codeErr) {
+		return false
+	}
+	return DefaultCodeRelation.Is(e.Code(), codeErr.Code())
+}
+
+func (e *FieldErrors) Wrap() error {
+	return Wrap(e)
+}
+
+func (e *FieldErrors) WrapMsg(msg string, kv ...any) error {
+	return WrapMsg(e, msg, kv...)
+}
+
+// Error 返回错误的字符串表示,格式:[错误码] [消息] [字段1: 说明; 字段2: 说明]
+func (e *FieldErrors) Error() string {
+	return strings.Join([]string{strconv.Itoa(e.Code()), e.Msg(), e.Detail()}, " ")
+}
@@ -0,0 +1,56 @@
+package errs
+
+import (
+	"errors"
+	"testing"
+)
+
+// ==================== FieldErrors 测试 ====================
+
+func TestFieldErrors(t *testing.T) {
+	fe := NewFieldErrors()
+	if err := fe.ErrorOrNil(); err != nil {
+		t.Fatalf("ErrorOrNil() = %v, want nil", err)
+	}
+
+	fe.Add("server.port", "min", "must be >= 1")
+	fe.Add("database.dsn", "required", "is required")
+	other := NewFieldErrors()
+	other.Add("log.level", "oneof", "must be one of [debug info]")
+	fe.Merge(other)
+
+	if fe.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", fe.Len())
+	}
+	want := "1001 ArgsError server.port: must be >= 1; database.dsn: is required; log.level: must be one of [debug info]"
+	if got := fe.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	err := fe.ErrorOrNil()
+	if err == nil {
+		t.Fatal("ErrorOrNil() returned nil")
+	}
+	if !errors.Is(err, ErrArgs) {
+		t.Error("errors.Is(err, ErrArgs) should be true")
+	}
+	if errors.Is(err, ErrRecordNotFound) {
+		t.Error("errors.Is(err, ErrRecordNotFound) should be false")
+	}
+
+	var target *FieldErrors
+	if !errors.As(err, &target) {
+		t.Fatal("errors.As should find *FieldErrors")
+	}
+	if target.Fields()[1].Rule != "required" {
+		t.Errorf("Fields()[1].Rule = %q, want required", target.Fields()[1].Rule)
+	}
+
+	withDetail := fe.WithDetail("file=app.yaml")
+	if withDetail.Code() != ArgsError {
+		t.Errorf("WithDetail().Code() = %d, want %d", withDetail.Code(), ArgsError)
+	}
+	if got := withDetail.Detail(); got != fe.Detail()+"; file=app.yaml" {
+		t.Errorf("WithDetail().Detail() = %q", got)
+	}
+}
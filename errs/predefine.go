@@ -15,6 +15,8 @@ const (
 	TokenKickedError         = 1506 // Token被踢出
 	TokenNotExistError       = 1507 // Token不存在
 	OrgUserNoPermissionError = 1520 // 组织用户无权限
+	CircuitOpenError         = 1601 // 熔断器打开，请求被拒绝
+	BulkheadFullError        = 1602 // 并发数已满，请求被拒绝
 )
 
 // 预定义的CodeError实例，可直接使用或通过WrapMsg添加上下文
@@ -32,4 +34,6 @@ var (
 	ErrTokenKicked              = NewCodeError(TokenKickedError, "TokenKickedError")
 	ErrTokenNotExist            = NewCodeError(TokenNotExistError, "TokenNotExistError")
 	ErrOrgUserNoPermissionError = NewCodeError(OrgUserNoPermissionError, "OrgUserNoPermissionError")
+	ErrCircuitOpen              = NewCodeError(CircuitOpenError, "CircuitOpenError")
+	ErrBulkheadFull             = NewCodeError(BulkheadFullError, "BulkheadFullError")
 )
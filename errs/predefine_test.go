@@ -26,6 +26,8 @@ func TestErrorCodeConstants(t *testing.T) {
 		{"TokenKickedError", TokenKickedError, 1506},
 		{"TokenNotExistError", TokenNotExistError, 1507},
 		{"OrgUserNoPermissionError", OrgUserNoPermissionError, 1520},
+		{"CircuitOpenError", CircuitOpenError, 1601},
+		{"BulkheadFullError", BulkheadFullError, 1602},
 	}
 
 	for _, tt := range tests {
@@ -124,6 +126,18 @@ func TestPredefinedErrors(t *testing.T) {
 			wantCode: OrgUserNoPermissionError,
 			wantMsg:  "OrgUserNoPermissionError",
 		},
+		{
+			name:     "ErrCircuitOpen",
+			err:      ErrCircuitOpen,
+			wantCode: CircuitOpenError,
+			wantMsg:  "CircuitOpenError",
+		},
+		{
+			name:     "ErrBulkheadFull",
+			err:      ErrBulkheadFull,
+			wantCode: BulkheadFullError,
+			wantMsg:  "BulkheadFullError",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,249 @@
+// Package featureflag 提供基于配置的功能开关,支持按用户百分比灰度、按mcontext中的opUserID和platform定向,
+// 配置变化时热更新,每次判断都会记录评估日志用于审计。
+// 默认的评估日志使用debug级别,日志级别为info及以上时没有输出,需要审计时通过WithEvalLogger写入审计系统
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cospk/base-tools/config"
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/log"
+	"github.com/Cospk/base-tools/mcontext"
+)
+
+// bucketCount 灰度分桶数,百分比精确到0.01%
+const bucketCount = 10000
+
+// 评估结果的原因
+const (
+	ReasonNotFound = "not_found" // 开关未定义
+	ReasonDisabled = "disabled"  // 总开关关闭
+	ReasonRule     = "rule"      // 命中定向规则
+	ReasonRollout  = "rollout"   // 按默认灰度比例
+)
+
+// Flag 一个功能开关的配置,如:
+//
+//	featureflags:
+//	  new_checkout:
+//	    enabled: true
+//	    rollout: 20          # 20%的用户开启
+//	    rules:
+//	      - users: [u1, u2]  # 指定用户始终开启
+//	      - platforms: ["1"] # 该平台的用户50%开启
+//	        rollout: 50
+type Flag struct {
+	Description string  `mapstructure:"description"`
+	Enabled     bool    `mapstructure:"enabled"`                          // 总开关,关闭时对所有人关闭
+	Rollout     float64 `mapstructure:"rollout" validate:"min=0,max=100"` // 未命中任何规则时开启的用户百分比
+	Rules       []Rule  `mapstructure:"rules"`                            // 定向规则,按顺序匹配第一条
+}
+
+// Rule 定向规则,Users和Platforms都为空时匹配所有请求,都不为空时需要同时满足
+type Rule struct {
+	Users     []string `mapstructure:"users"`                            // 匹配的opUserID
+	Platforms []string `mapstructure:"platforms"`                        // 匹配的platform
+	Rollout   *float64 `mapstructure:"rollout" validate:"min=0,max=100"` // 命中后开启的用户百分比,未设置时为100
+}
+
+func (r *Rule) match(opUserID, platform string) bool {
+	if len(r.Users) > 0 && !slices.Contains(r.Users, opUserID) {
+		return false
+	}
+	if len(r.Platforms) > 0 && !slices.Contains(r.Platforms, platform) {
+		return false
+	}
+	return true
+}
+
+// Evaluation 一次开关判断的结果,用于评估日志
+type Evaluation struct {
+	Flag        string
+	Enabled     bool
+	Reason      string // ReasonNotFound、ReasonDisabled、ReasonRule或ReasonRollout
+	Rule        int    // 命中的规则下标,未命中时为-1
+	OperationID string
+	OpUserID    string
+	Platform    string
+	Time        time.Time
+}
+
+// EvalLogger 接收每次开关判断的结果
+type EvalLogger func(ctx context.Context, e Evaluation)
+
+// Option Flags的配置选项
+type Option func(*Flags)
+
+// WithEvalLogger 设置评估日志的处理函数,默认使用log.ZDebug输出,只在开启debug日志时可见。传入nil时不记录
+func WithEvalLogger(fn EvalLogger) Option {
+	return func(f *Flags) {
+		f.evalLog = fn
+	}
+}
+
+// Flags 一组功能开关,并发安全,Update会原子地替换全部开关
+type Flags struct {
+	flags   atomic.Pointer[map[string]Flag]
+	evalLog EvalLogger
+
+	mutex sync.Mutex // 保证Update按顺序生效
+}
+
+// New 创建功能开关,flags的键为开关名
+func New(flags map[string]Flag, opts ...Option) (*Flags, error) {
+	f := &Flags{evalLog: logEvaluation}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := f.Update(flags); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update 校验并替换全部开关,配置非法时保留原开关并返回错误
+func (f *Flags) Update(flags map[string]Flag) error {
+	if err := Validate(flags); err != nil {
+		return err
+	}
+	copied := make(map[string]Flag, len(flags))
+	for name, flag := range flags {
+		copied[name] = flag
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var changed []string
+	if old := f.flags.Load(); old != nil {
+		changed = diffFlags(*old, copied)
+		if len(changed) == 0 {
+			return nil
+		}
+	}
+	f.flags.Store(&copied)
+	if changed != nil {
+		log.ZInfo(context.Background(), "feature flags updated", "changed", changed)
+	}
+	return nil
+}
+
+// Validate 校验所有开关的灰度比例,违规项汇总到一个errs.FieldErrors中,字段名形如"new_checkout.rules[0].rollout"
+func Validate(flags map[string]Flag) error {
+	fe := errs.NewFieldErrors()
+	for _, name := range sortedNames(flags) {
+		flag := flags[name]
+		var ffe *errs.FieldErrors
+		if err := config.Validate(&flag); err != nil && errors.As(err, &ffe) {
+			for _, e := range ffe.Fields() {
+				fe.Add(name+"."+e.Field, e.Rule, e.Msg)
+			}
+		}
+	}
+	return fe.ErrorOrNil()
+}
+
+// Enabled 判断开关对当前请求是否开启,未定义的开关视为关闭
+func (f *Flags) Enabled(ctx context.Context, name string) bool {
+	return f.Evaluate(ctx, name).Enabled
+}
+
+// Evaluate 判断开关对当前请求是否开启并返回原因:总开关关闭时关闭;
+// 否则按顺序匹配定向规则,命中时使用规则的灰度比例,都未命中时使用开关的灰度比例。
+// 灰度按开关名和opUserID哈希分桶,同一用户的结果保持稳定
+func (f *Flags) Evaluate(ctx context.Context, name string) Evaluation {
+	e := Evaluation{
+		Flag:        name,
+		Rule:        -1,
+		OperationID: mcontext.GetOperationID(ctx),
+		OpUserID:    mcontext.GetOpUserID(ctx),
+		Platform:    mcontext.GetOpUserPlatform(ctx),
+		Time:        time.Now(),
+	}
+	flag, ok := (*f.flags.Load())[name]
+	switch {
+	case !ok:
+		e.Reason = ReasonNotFound
+	case !flag.Enabled:
+		e.Reason = ReasonDisabled
+	default:
+		e.Reason = ReasonRollout
+		rollout := flag.Rollout
+		for i := range flag.Rules {
+			if r := &flag.Rules[i]; r.match(e.OpUserID, e.Platform) {
+				e.Reason, e.Rule, rollout = ReasonRule, i, 100
+				if r.Rollout != nil {
+					rollout = *r.Rollout
+				}
+				break
+			}
+		}
+		e.Enabled = inRollout(name, e.OpUserID, rollout)
+	}
+	if f.evalLog != nil {
+		f.evalLog(ctx, e)
+	}
+	return e
+}
+
+// Get 返回开关的配置
+func (f *Flags) Get(name string) (Flag, bool) {
+	flag, ok := (*f.flags.Load())[name]
+	return flag, ok
+}
+
+// Names 返回所有开关名(已排序)
+func (f *Flags) Names() []string {
+	return sortedNames(*f.flags.Load())
+}
+
+// inRollout 判断用户是否落在灰度比例内,没有opUserID时只有100%才开启
+func inRollout(name, opUserID string, rollout float64) bool {
+	if rollout >= 100 {
+		return true
+	}
+	if rollout <= 0 || opUserID == "" {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + opUserID))
+	return float64(h.Sum32()%bucketCount) < rollout*bucketCount/100
+}
+
+// logEvaluation 默认的评估日志
+func logEvaluation(ctx context.Context, e Evaluation) {
+	log.ZDebug(ctx, "feature flag evaluated", "flag", e.Flag, "enabled", e.Enabled, "reason", e.Reason, "rule", e.Rule)
+}
+
+// diffFlags 返回新增、删除或配置变化的开关名(已排序)
+func diffFlags(old, new map[string]Flag) []string {
+	changed := []string{}
+	for name, flag := range new {
+		if o, ok := old[name]; !ok || config.Diff(&o, &flag) != nil {
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func sortedNames(flags map[string]Flag) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
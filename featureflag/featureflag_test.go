@@ -0,0 +1,211 @@
+package featureflag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/config"
+	"github.com/Cospk/base-tools/mcontext"
+)
+
+func userCtx(opUserID, platform string) context.Context {
+	ctx := mcontext.SetOperationID(context.Background(), "op-1")
+	ctx = mcontext.SetOpUserID(ctx, opUserID)
+	return mcontext.WithOpUserPlatformContext(ctx, platform)
+}
+
+func percent(v float64) *float64 { return &v }
+
+// TestEvaluate 测试总开关、定向规则和评估原因
+func TestEvaluate(t *testing.T) {
+	var mu sync.Mutex
+	var logs []Evaluation
+	f, err := New(map[string]Flag{
+		"off": {Enabled: false, Rollout: 100},
+		"on":  {Enabled: true, Rollout: 100},
+		"targeted": {Enabled: true, Rules: []Rule{
+			{Users: []string{"vip"}},
+			{Platforms: []string{"ios"}, Rollout: percent(0)},
+			{Platforms: []string{"android"}, Users: []string{"u1"}},
+		}},
+	}, WithEvalLogger(func(_ context.Context, e Evaluation) {
+		mu.Lock()
+		logs = append(logs, e)
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag     string
+		user     string
+		platform string
+		enabled  bool
+		reason   string
+		rule     int
+	}{
+		{"missing", "u1", "ios", false, ReasonNotFound, -1},
+		{"off", "u1", "ios", false, ReasonDisabled, -1},
+		{"on", "u1", "ios", true, ReasonRollout, -1},
+		{"targeted", "vip", "ios", true, ReasonRule, 0},
+		{"targeted", "u1", "ios", false, ReasonRule, 1},
+		{"targeted", "u1", "android", true, ReasonRule, 2},
+		{"targeted", "u2", "android", false, ReasonRollout, -1},
+	}
+	for _, tt := range tests {
+		e := f.Evaluate(userCtx(tt.user, tt.platform), tt.flag)
+		if e.Enabled != tt.enabled || e.Reason != tt.reason || e.Rule != tt.rule {
+			t.Errorf("%s(%s, %s) 期望 %v/%s/%d, 实际为 %v/%s/%d",
+				tt.flag, tt.user, tt.platform, tt.enabled, tt.reason, tt.rule, e.Enabled, e.Reason, e.Rule)
+		}
+	}
+	if len(logs) != len(tests) {
+		t.Fatalf("期望 %d 条评估日志, 实际为 %d", len(tests), len(logs))
+	}
+	if l := logs[3]; l.Flag != "targeted" || l.OperationID != "op-1" || l.OpUserID != "vip" || l.Platform != "ios" {
+		t.Errorf("评估日志内容错误: %+v", l)
+	}
+}
+
+// TestRollout 测试灰度比例的分布和稳定性
+func TestRollout(t *testing.T) {
+	f, err := New(map[string]Flag{"half": {Enabled: true, Rollout: 30}}, WithEvalLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		ctx := userCtx("user-"+strconv.Itoa(i), "")
+		if f.Enabled(ctx, "half") {
+			enabled++
+		}
+	}
+	if enabled < 2700 || enabled > 3300 {
+		t.Errorf("30%% 灰度期望约 3000 个用户开启, 实际为 %d", enabled)
+	}
+
+	ctx := userCtx("stable-user", "")
+	first := f.Enabled(ctx, "half")
+	for i := 0; i < 10; i++ {
+		if f.Enabled(ctx, "half") != first {
+			t.Fatal("同一用户的灰度结果应该保持稳定")
+		}
+	}
+	if f.Enabled(userCtx("", ""), "half") {
+		t.Error("没有opUserID时非100%的灰度应该关闭")
+	}
+}
+
+// TestValidate 测试非法的灰度比例
+func TestValidate(t *testing.T) {
+	_, err := New(map[string]Flag{
+		"a": {Rollout: 120},
+		"b": {Rules: []Rule{{Rollout: percent(-1)}}},
+	})
+	if err == nil {
+		t.Fatal("非法的灰度比例应该返回错误")
+	}
+	f, _ := New(map[string]Flag{"a": {Enabled: true, Rollout: 100}}, WithEvalLogger(nil))
+	if err := f.Update(map[string]Flag{"a": {Rollout: 200}}); err == nil {
+		t.Error("Update非法配置应该返回错误")
+	}
+	if !f.Enabled(context.Background(), "a") {
+		t.Error("Update失败时应该保留原开关")
+	}
+}
+
+// TestFromViper 测试从ViperConfig加载并热更新
+func TestFromViper(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.yaml")
+	writeFile(t, file, `
+featureflags:
+  new_checkout:
+    enabled: true
+    rules:
+      - platforms: ["1"]
+`)
+	vc := config.NewViperConfig()
+	if err := vc.LoadWithFile(file); err != nil {
+		t.Fatal(err)
+	}
+	f, err := FromViper(vc, "featureflags", WithEvalLogger(nil))
+	if err != nil {
+		t.Fatalf("加载功能开关失败: %v", err)
+	}
+	if !f.Enabled(userCtx("u1", "1"), "new_checkout") || f.Enabled(userCtx("u1", "2"), "new_checkout") {
+		t.Error("定向规则未生效")
+	}
+
+	updated := make(chan struct{}, 1)
+	vc.OnConfigChange(func() {
+		select {
+		case updated <- struct{}{}:
+		default:
+		}
+	})
+	vc.WatchConfig()
+	writeFile(t, file, `
+featureflags:
+  new_checkout:
+    enabled: false
+`)
+	select {
+	case <-updated:
+	case <-time.After(3 * time.Second):
+		t.Fatal("修改配置文件后应该触发回调")
+	}
+	if f.Enabled(userCtx("u1", "1"), "new_checkout") {
+		t.Error("关闭总开关后应该热更新")
+	}
+}
+
+// TestFromWatcher 测试从类型化配置的Watcher加载并热更新
+func TestFromWatcher(t *testing.T) {
+	type appConfig struct {
+		Flags map[string]Flag `mapstructure:"flags"`
+	}
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	writeFile(t, file, "flags:\n  beta:\n    enabled: true\n    rollout: 100\n")
+	w, err := config.NewWatcher[appConfig](config.WithFile(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	f, err := FromWatcher(w, func(c *appConfig) map[string]Flag { return c.Flags }, WithEvalLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Enabled(context.Background(), "beta") {
+		t.Error("beta 应该开启")
+	}
+
+	writeFile(t, file, "flags:\n  beta:\n    enabled: true\n    rollout: 0\n  gamma:\n    enabled: true\n    rollout: 100\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Enabled(context.Background(), "beta") || !f.Enabled(context.Background(), "gamma") {
+		t.Errorf("Watcher重新加载后应该更新开关: %v", f.Names())
+	}
+
+	writeFile(t, file, "flags:\n  beta:\n    rollout: 300\n")
+	if err := w.Reload(); err == nil {
+		t.Error("非法的灰度比例应该重新加载失败")
+	}
+	if !f.Enabled(context.Background(), "gamma") {
+		t.Error("重新加载失败时应该保留原开关")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
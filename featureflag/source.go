@@ -0,0 +1,54 @@
+package featureflag
+
+import (
+	"context"
+
+	"github.com/Cospk/base-tools/config"
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/log"
+)
+
+// FromViper 从ViperConfig的key(如"featureflags")加载开关,开关名会被Viper转为小写。
+// 配置文件或远程配置变化时(需要调用WatchConfig或WatchRemote)热更新,新配置非法时保留原开关并记录警告日志
+func FromViper(vc *config.ViperConfig, key string, opts ...Option) (*Flags, error) {
+	load := func() (map[string]Flag, error) {
+		flags := make(map[string]Flag)
+		if err := vc.UnmarshalKey(key, &flags); err != nil {
+			return nil, errs.WrapMsg(err, "解析功能开关失败", "key", key)
+		}
+		return flags, nil
+	}
+	flags, err := load()
+	if err != nil {
+		return nil, err
+	}
+	f, err := New(flags, opts...)
+	if err != nil {
+		return nil, err
+	}
+	vc.OnConfigChange(func() {
+		flags, err := load()
+		if err == nil {
+			err = f.Update(flags)
+		}
+		if err != nil {
+			log.ZWarn(context.Background(), "reload feature flags failed, keep previous flags", err, "key", key)
+		}
+	})
+	return f, nil
+}
+
+// FromWatcher 从类型化配置中取出开关,并订阅Watcher的配置变更热更新。
+// 开关的灰度比例已经在Watcher加载时按validate标签校验,订阅在Watcher关闭前一直有效
+func FromWatcher[T any](w *config.Watcher[T], get func(*T) map[string]Flag, opts ...Option) (*Flags, error) {
+	f, err := New(get(w.Get()), opts...)
+	if err != nil {
+		return nil, err
+	}
+	w.Subscribe(func(c config.Change[T]) {
+		if err := f.Update(get(c.New)); err != nil {
+			log.ZWarn(context.Background(), "reload feature flags failed, keep previous flags", err)
+		}
+	})
+	return f, nil
+}
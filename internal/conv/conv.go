@@ -0,0 +1,394 @@
+// Package conv 提供 config 和 env 共用的类型转换:将字符串或配置文件中解析出的值按反射类型设置到字段上
+package conv
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	DurationType        = reflect.TypeOf(time.Duration(0))
+	TimeType            = reflect.TypeOf(time.Time{})
+	URLType             = reflect.TypeOf(url.URL{})
+	TextUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// timeLayouts 未指定格式时依次尝试的时间格式
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// Options 转换选项
+type Options struct {
+	// Sep 字符串表示切片和map时元素的分隔符,默认为","
+	Sep string
+	// Layout time.Time的格式,为空时依次尝试RFC3339、"2006-01-02 15:04:05"和"2006-01-02"
+	Layout string
+	// DecodeStruct 将map解析到结构体,为nil时不支持结构体
+	DecodeStruct func(v reflect.Value, m map[string]any) error
+}
+
+// IsScalar 判断结构体类型是否作为单个值解析,如time.Time、url.URL和实现了encoding.TextUnmarshaler的类型
+func IsScalar(t reflect.Type) bool {
+	return t == TimeType || t == URLType || reflect.PointerTo(t).Implements(TextUnmarshalerType)
+}
+
+// SetValue 将raw转换为v的类型并设置,raw为nil时不修改v。
+// 字符串可以转换为数字、布尔值、时长、时间、URL以及实现了encoding.TextUnmarshaler的类型,
+// 切片可以用分隔符分隔的字符串表示,map可以用"k1=v1,k2=v2"表示,[]byte直接使用字符串的内容
+func SetValue(v reflect.Value, raw any, o *Options) error {
+	if raw == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return SetValue(v.Elem(), raw, o)
+	}
+
+	switch {
+	case v.Type() == DurationType:
+		d, err := ToDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Type() == TimeType:
+		t, err := ToTime(raw, o.Layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == URLType:
+		s, err := ToString(raw)
+		if err != nil {
+			return err
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid URL %q", s)
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	case v.CanAddr() && v.Addr().Type().Implements(TextUnmarshalerType):
+		s, err := ToString(raw)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, err := ToString(raw)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := ToBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := ToInt64(raw)
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := ToUint64(raw)
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := ToFloat64(raw)
+		if err != nil {
+			return err
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows %s", f, v.Type())
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		return setSlice(v, raw, o)
+	case reflect.Map:
+		return setMap(v, raw, o)
+	case reflect.Struct:
+		m, ok := raw.(map[string]any)
+		if !ok || o.DecodeStruct == nil {
+			return fmt.Errorf("cannot decode %T into %s", raw, v.Type())
+		}
+		return o.DecodeStruct(v, m)
+	case reflect.Interface:
+		// raw为nil时已经在开头返回
+		if !reflect.TypeOf(raw).AssignableTo(v.Type()) {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(raw))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func setSlice(v reflect.Value, raw any, o *Options) error {
+	var items []any
+	switch r := raw.(type) {
+	case string:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(r))
+			return nil
+		}
+		for _, s := range SplitList(r, o.Sep) {
+			items = append(items, s)
+		}
+	default:
+		rv := reflect.ValueOf(raw)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("cannot decode %T into %s", raw, v.Type())
+		}
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, rv.Index(i).Interface())
+		}
+	}
+	s := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := SetValue(s.Index(i), item, o); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	v.Set(s)
+	return nil
+}
+
+func setMap(v reflect.Value, raw any, o *Options) error {
+	entries := make(map[string]any)
+	switch r := raw.(type) {
+	case string:
+		for _, pair := range SplitList(r, o.Sep) {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid map entry %q, expected key=value", pair)
+			}
+			entries[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+	default:
+		rv := reflect.ValueOf(raw)
+		if rv.Kind() != reflect.Map {
+			return fmt.Errorf("cannot decode %T into %s", raw, v.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			entries[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+	}
+	m := reflect.MakeMapWithSize(v.Type(), len(entries))
+	for k, val := range entries {
+		kv := reflect.New(v.Type().Key()).Elem()
+		if err := SetValue(kv, k, o); err != nil {
+			return fmt.Errorf("key %q: %w", k, err)
+		}
+		ev := reflect.New(v.Type().Elem()).Elem()
+		if err := SetValue(ev, val, o); err != nil {
+			return fmt.Errorf("key %q: %w", k, err)
+		}
+		m.SetMapIndex(kv, ev)
+	}
+	v.Set(m)
+	return nil
+}
+
+// SplitList 按分隔符拆分字符串,去掉元素两端的空白并忽略空元素,sep为空时使用","
+func SplitList(value, sep string) []string {
+	if sep == "" {
+		sep = ","
+	}
+	var parts []string
+	for _, s := range strings.Split(value, sep) {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return parts
+}
+
+// ToString 将字符串、布尔值、数字和时间转换为字符串
+func ToString(raw any) (string, error) {
+	switch r := raw.(type) {
+	case string:
+		return r, nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return fmt.Sprint(r), nil
+	case time.Time:
+		return r.Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("cannot decode %T into string", raw)
+}
+
+// ToBool 将布尔值或strconv.ParseBool支持的字符串转换为布尔值
+func ToBool(raw any) (bool, error) {
+	switch r := raw.(type) {
+	case bool:
+		return r, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(r))
+		if err != nil {
+			return false, fmt.Errorf("invalid bool %q", r)
+		}
+		return b, nil
+	}
+	return false, fmt.Errorf("cannot decode %T into bool", raw)
+}
+
+// ToInt64 将整数、没有小数部分的浮点数和字符串转换为int64,
+// 字符串按十进制解析,也支持"0x"、"0o"、"0b"前缀
+func ToInt64(raw any) (int64, error) {
+	switch r := raw.(type) {
+	case int:
+		return int64(r), nil
+	case int8:
+		return int64(r), nil
+	case int16:
+		return int64(r), nil
+	case int32:
+		return int64(r), nil
+	case int64:
+		return r, nil
+	case uint:
+		return int64(r), nil
+	case uint8:
+		return int64(r), nil
+	case uint16:
+		return int64(r), nil
+	case uint32:
+		return int64(r), nil
+	case uint64:
+		if r > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", r)
+		}
+		return int64(r), nil
+	case float32, float64:
+		f, _ := ToFloat64(r)
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("invalid integer %v", f)
+		}
+		return int64(f), nil
+	case string:
+		s := strings.TrimSpace(r)
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			if n, err = strconv.ParseInt(s, 0, 64); err != nil {
+				return 0, fmt.Errorf("invalid integer %q", r)
+			}
+		}
+		return n, nil
+	case json.Number:
+		return ToInt64(string(r))
+	}
+	return 0, fmt.Errorf("cannot decode %T into integer", raw)
+}
+
+// ToUint64 与ToInt64相同,但支持完整的uint64范围,负数返回错误
+func ToUint64(raw any) (uint64, error) {
+	switch r := raw.(type) {
+	case uint64:
+		return r, nil
+	case string:
+		s := strings.TrimSpace(r)
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			if n, err = strconv.ParseUint(s, 0, 64); err != nil {
+				return 0, fmt.Errorf("invalid unsigned integer %q", r)
+			}
+		}
+		return n, nil
+	case json.Number:
+		return ToUint64(string(r))
+	}
+	n, err := ToInt64(raw)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid unsigned integer %d", n)
+	}
+	return uint64(n), nil
+}
+
+// ToFloat64 将数字和字符串转换为float64
+func ToFloat64(raw any) (float64, error) {
+	switch r := raw.(type) {
+	case float32:
+		return float64(r), nil
+	case float64:
+		return r, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", r)
+		}
+		return f, nil
+	case json.Number:
+		return ToFloat64(string(r))
+	}
+	if n, err := ToInt64(raw); err == nil {
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("cannot decode %T into float", raw)
+}
+
+// ToDuration 解析时长,字符串按time.ParseDuration解析,整数视为纳秒
+func ToDuration(raw any) (time.Duration, error) {
+	if s, ok := raw.(string); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return d, nil
+	}
+	n, err := ToInt64(raw)
+	if err != nil {
+		return 0, fmt.Errorf("cannot decode %T into duration", raw)
+	}
+	return time.Duration(n), nil
+}
+
+// ToTime 解析时间,layout为空时依次尝试RFC3339、"2006-01-02 15:04:05"和"2006-01-02"
+func ToTime(raw any, layout string) (time.Time, error) {
+	switch r := raw.(type) {
+	case time.Time:
+		return r, nil
+	case string:
+		if layout != "" {
+			t, err := time.Parse(layout, strings.TrimSpace(r))
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid time %q, expected layout %s", r, layout)
+			}
+			return t, nil
+		}
+		for _, l := range timeLayouts {
+			if t, err := time.Parse(l, strings.TrimSpace(r)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time %q", r)
+	}
+	return time.Time{}, fmt.Errorf("cannot decode %T into time", raw)
+}
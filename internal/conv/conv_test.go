@@ -0,0 +1,79 @@
+package conv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetValue(t *testing.T) {
+	var cfg struct {
+		Int    int
+		Int8   int8
+		Uint   uint64
+		Bytes  []byte
+		Ports  []int
+		Labels map[string]int
+		Day    time.Time
+		Wait   *time.Duration
+		Any    any
+		Text   fmt.Stringer
+	}
+	v := reflect.ValueOf(&cfg).Elem()
+	tests := []struct {
+		field string
+		raw   any
+		opts  Options
+	}{
+		{"Int", "010", Options{}},
+		{"Int8", 0x7f, Options{}},
+		{"Uint", "0xffffffffffffffff", Options{}},
+		{"Bytes", "raw", Options{}},
+		{"Ports", "80; 443", Options{Sep: ";"}},
+		{"Labels", map[string]any{"a": 1, "b": "2"}, Options{}},
+		{"Day", "01/06/2025", Options{Layout: "02/01/2006"}},
+		{"Wait", "1s", Options{}},
+		{"Any", []any{"a", 1}, Options{}},
+		{"Text", nil, Options{}},
+		{"Text", time.Second, Options{}},
+	}
+	for _, tt := range tests {
+		if err := SetValue(v.FieldByName(tt.field), tt.raw, &tt.opts); err != nil {
+			t.Errorf("%s 转换失败: %v", tt.field, err)
+		}
+	}
+	if cfg.Int != 10 || cfg.Int8 != 127 || cfg.Uint != 1<<64-1 || string(cfg.Bytes) != "raw" {
+		t.Errorf("基本类型转换错误: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Ports, []int{80, 443}) || cfg.Labels["b"] != 2 {
+		t.Errorf("切片或map转换错误: %v %v", cfg.Ports, cfg.Labels)
+	}
+	if cfg.Day.Month() != time.June || cfg.Wait == nil || *cfg.Wait != time.Second {
+		t.Errorf("时间转换错误: %v %v", cfg.Day, cfg.Wait)
+	}
+	if !reflect.DeepEqual(cfg.Any, []any{"a", 1}) || cfg.Text != time.Second {
+		t.Errorf("接口类型转换错误: %v %v", cfg.Any, cfg.Text)
+	}
+
+	errors := []struct {
+		field string
+		raw   any
+		want  string
+	}{
+		{"Int8", "300", "overflows int8"},
+		{"Int", 1.5, "invalid integer"},
+		{"Uint", "-1", "invalid unsigned integer"},
+		{"Ports", "80,x", "element 1"},
+		{"Labels", "novalue", "expected key=value"},
+		{"Day", true, "cannot decode bool into time"},
+		{"Text", "1s", "unsupported type fmt.Stringer"},
+	}
+	for _, tt := range errors {
+		err := SetValue(v.FieldByName(tt.field), tt.raw, &Options{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s 期望错误包含 %q, 实际为 %v", tt.field, tt.want, err)
+		}
+	}
+}
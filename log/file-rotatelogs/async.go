@@ -0,0 +1,231 @@
+package rotatelogs
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// OverflowPolicy 异步写入队列已满时的处理策略
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞写入方直到队列有空位
+	OverflowDropNewest                       // 丢弃本次写入的数据
+	OverflowDropOldest                       // 丢弃队列中最早的数据,为本次写入腾出空间
+)
+
+// 异步写入的默认参数
+const (
+	defaultQueueSize = 1024
+	defaultBatchSize = 128
+)
+
+// ErrAsyncWriterClosed 向已关闭的AsyncWriter写入时返回
+var ErrAsyncWriterClosed = errors.New("async writer is closed")
+
+// AsyncStats AsyncWriter的运行统计
+type AsyncStats struct {
+	Queued        int    // 当前排队等待写入的条数
+	DroppedWrites uint64 // 因队列已满被丢弃的写入次数
+	DroppedBytes  uint64 // 因队列已满被丢弃的字节数
+	WriteErrors   uint64 // 写入底层Writer失败的次数
+}
+
+// asyncEntry 队列中的一次写入,seq为写入序号,用于Sync判断之前的数据是否已落盘
+type asyncEntry struct {
+	seq  uint64
+	data []byte
+}
+
+// AsyncWriter 非阻塞的异步写入包装器。Write将数据复制到有界队列后立即返回,
+// 由后台goroutine批量写入底层Writer,慢速磁盘不会阻塞日志调用方。
+// AsyncWriter实现了zapcore.WriteSyncer,可以直接作为Zap的输出
+type AsyncWriter struct {
+	w         io.Writer
+	policy    OverflowPolicy
+	queueSize int
+	batchSize int
+
+	mutex    sync.Mutex
+	notEmpty *sync.Cond // 队列中有新数据或已关闭
+	notFull  *sync.Cond // 队列中有空位或已关闭
+	progress *sync.Cond // 有数据写入完成或被丢弃
+
+	queue         []asyncEntry
+	seq           uint64 // 最后一次入队的序号
+	inflightFirst uint64 // 正在写入的批次中最小的序号,0表示没有正在写入的批次
+	closed        bool
+	lastErr       error
+	stats         AsyncStats
+
+	exited chan struct{}
+}
+
+// NewAsyncWriter 创建一个包装w的AsyncWriter,可选参数为WithQueueSize、WithBatchSize和WithOverflowPolicy
+func NewAsyncWriter(w io.Writer, options ...Option) *AsyncWriter {
+	aw := &AsyncWriter{
+		w:         w,
+		policy:    OverflowBlock,
+		queueSize: defaultQueueSize,
+		batchSize: defaultBatchSize,
+		exited:    make(chan struct{}),
+	}
+	for _, o := range options {
+		switch o.Name() {
+		case optkeyQueueSize:
+			if n := o.Value().(int); n > 0 {
+				aw.queueSize = n
+			}
+		case optkeyBatchSize:
+			if n := o.Value().(int); n > 0 {
+				aw.batchSize = n
+			}
+		case optkeyOverflowPolicy:
+			aw.policy = o.Value().(OverflowPolicy)
+		}
+	}
+	aw.notEmpty = sync.NewCond(&aw.mutex)
+	aw.notFull = sync.NewCond(&aw.mutex)
+	aw.progress = sync.NewCond(&aw.mutex)
+	aw.queue = make([]asyncEntry, 0, aw.queueSize)
+
+	go aw.run()
+	return aw
+}
+
+// Write 实现io.Writer接口。数据被复制到队列后立即返回,
+// 队列已满时按OverflowPolicy处理,被丢弃的数据同样视为写入成功以免影响调用方
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.mutex.Lock()
+	defer aw.mutex.Unlock()
+
+	for !aw.closed && len(aw.queue) >= aw.queueSize {
+		switch aw.policy {
+		case OverflowDropNewest:
+			aw.stats.DroppedWrites++
+			aw.stats.DroppedBytes += uint64(len(p))
+			return len(p), nil
+		case OverflowDropOldest:
+			oldest := aw.queue[0]
+			aw.queue = append(aw.queue[:0], aw.queue[1:]...)
+			aw.stats.DroppedWrites++
+			aw.stats.DroppedBytes += uint64(len(oldest.data))
+			aw.progress.Broadcast()
+		default:
+			aw.notFull.Wait()
+		}
+	}
+	if aw.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+
+	aw.seq++
+	aw.queue = append(aw.queue, asyncEntry{seq: aw.seq, data: append([]byte(nil), p...)})
+	aw.notEmpty.Signal()
+	return len(p), nil
+}
+
+// run 后台写入循环,每次取出至多batchSize条数据合并为一次写入
+func (aw *AsyncWriter) run() {
+	defer close(aw.exited)
+
+	var buf []byte
+	for {
+		aw.mutex.Lock()
+		for len(aw.queue) == 0 && !aw.closed {
+			aw.notEmpty.Wait()
+		}
+		if len(aw.queue) == 0 {
+			aw.mutex.Unlock()
+			return
+		}
+		n := len(aw.queue)
+		if n > aw.batchSize {
+			n = aw.batchSize
+		}
+		buf = buf[:0]
+		for _, e := range aw.queue[:n] {
+			buf = append(buf, e.data...)
+		}
+		aw.inflightFirst = aw.queue[0].seq
+		aw.queue = append(aw.queue[:0], aw.queue[n:]...)
+		aw.notFull.Broadcast()
+		aw.mutex.Unlock()
+
+		_, err := aw.w.Write(buf)
+
+		aw.mutex.Lock()
+		if err != nil {
+			aw.stats.WriteErrors++
+			aw.lastErr = err
+		}
+		aw.inflightFirst = 0
+		aw.progress.Broadcast()
+		aw.mutex.Unlock()
+	}
+}
+
+// Sync 等待调用前写入的所有数据写入底层Writer,
+// 如果底层Writer实现了Sync则继续调用它。返回自上次Sync以来最近一次的写入错误
+func (aw *AsyncWriter) Sync() error {
+	aw.mutex.Lock()
+	target := aw.seq
+	for !aw.flushedNolock(target) {
+		aw.progress.Wait()
+	}
+	err := aw.lastErr
+	aw.lastErr = nil
+	aw.mutex.Unlock()
+
+	if s, ok := aw.w.(interface{ Sync() error }); ok {
+		if serr := s.Sync(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// flushedNolock 判断序号不大于target的数据是否都已写入或被丢弃
+func (aw *AsyncWriter) flushedNolock(target uint64) bool {
+	if len(aw.queue) > 0 && aw.queue[0].seq <= target {
+		return false
+	}
+	return aw.inflightFirst == 0 || aw.inflightFirst > target
+}
+
+// Stats 返回当前的运行统计
+func (aw *AsyncWriter) Stats() AsyncStats {
+	aw.mutex.Lock()
+	defer aw.mutex.Unlock()
+
+	stats := aw.stats
+	stats.Queued = len(aw.queue)
+	return stats
+}
+
+// Close 停止接收新数据,将队列中剩余的数据全部写入后关闭底层Writer(如果它实现了io.Closer)
+func (aw *AsyncWriter) Close() error {
+	aw.mutex.Lock()
+	if aw.closed {
+		aw.mutex.Unlock()
+		return nil
+	}
+	aw.closed = true
+	aw.notEmpty.Broadcast()
+	aw.notFull.Broadcast()
+	aw.mutex.Unlock()
+
+	<-aw.exited
+
+	aw.mutex.Lock()
+	err := aw.lastErr
+	aw.lastErr = nil
+	aw.mutex.Unlock()
+	if c, ok := aw.w.(io.Closer); ok {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
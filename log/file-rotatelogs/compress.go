@@ -0,0 +1,129 @@
+package rotatelogs
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// compressingSuffix 压缩过程中临时文件的后缀,清理时会被忽略
+const compressingSuffix = "_compressing"
+
+// Compressor 压缩器接口,用于压缩已轮转出去的日志文件
+type Compressor interface {
+	// Extension 返回压缩文件的扩展名,如".gz"
+	Extension() string
+	// NewWriter 返回一个写入压缩数据的io.WriteCloser
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// GzipCompressor 使用gzip格式的压缩器,Level为0时使用gzip.DefaultCompression
+type GzipCompressor struct {
+	Level int
+}
+
+// Gzip 默认压缩级别的gzip压缩器
+var Gzip Compressor = GzipCompressor{}
+
+func (c GzipCompressor) Extension() string {
+	return ".gz"
+}
+
+func (c GzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// compressFile 将filename压缩为filename+扩展名并删除原文件,保留原文件的修改时间以便按时间清理。
+// 如果文件不存在、已是压缩文件或者是符号链接当前指向的文件,则跳过并返回空字符串
+func (rl *RotateLogs) compressFile(filename string) (string, error) {
+	ext := rl.compressor.Extension()
+	if filepath.Ext(filename) == ext || rl.isLinked(filename) {
+		return "", nil
+	}
+	src, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	dest := filename + ext
+	tmp := dest + compressingSuffix
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+	if err := writeCompressed(rl.compressor, fh, src); err != nil {
+		fh.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := fh.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Chtimes(tmp, fi.ModTime(), fi.ModTime()); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return dest, nil
+}
+
+func writeCompressed(c Compressor, w io.Writer, r io.Reader) error {
+	zw, err := c.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// compressedExists 判断filename对应的压缩文件是否已存在,避免新文件与已压缩的旧文件重名
+func (rl *RotateLogs) compressedExists(filename string) bool {
+	if rl.compressor == nil {
+		return false
+	}
+	_, err := os.Stat(filename + rl.compressor.Extension())
+	return err == nil
+}
+
+// isLinked 判断filename是否为linkName当前指向的文件
+func (rl *RotateLogs) isLinked(filename string) bool {
+	if rl.linkName == "" {
+		return false
+	}
+	target, err := filepath.EvalSymlinks(rl.linkName)
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	target, err = filepath.Abs(target)
+	return err == nil && target == abs
+}
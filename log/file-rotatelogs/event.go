@@ -1,9 +1,31 @@
 package rotatelogs
 
+// ErrorOp 出错的操作
+type ErrorOp string
+
+const (
+	ErrorOpRotate   ErrorOp = "rotate"   // 创建符号链接或清理旧文件
+	ErrorOpCompress ErrorOp = "compress" // 压缩旧文件
+	ErrorOpDelete   ErrorOp = "delete"   // 删除旧文件
+	ErrorOpReopen   ErrorOp = "reopen"   // 重新打开当前文件
+	ErrorOpUpload   ErrorOp = "upload"   // 上传旧文件
+)
+
 func (h HandlerFunc) Handle(e Event) {
 	h(e)
 }
 
+// emitError 向Handler发送ErrorEvent
+func (rl *RotateLogs) emitError(op ErrorOp, file string, err error) {
+	if h := rl.eventHandler; h != nil {
+		go h.Handle(&ErrorEvent{
+			op:   op,
+			file: file,
+			err:  err,
+		})
+	}
+}
+
 func (e *FileRotatedEvent) Type() EventType {
 	return FileRotatedEventType
 }
@@ -15,3 +37,75 @@ func (e *FileRotatedEvent) PreviousFile() string {
 func (e *FileRotatedEvent) CurrentFile() string {
 	return e.current
 }
+
+func (e *FileCompressedEvent) Type() EventType {
+	return FileCompressedEventType
+}
+
+func (e *FileCompressedEvent) SourceFile() string {
+	return e.source
+}
+
+func (e *FileCompressedEvent) DestinationFile() string {
+	return e.destination
+}
+
+func (e *FileDeletedEvent) Type() EventType {
+	return FileDeletedEventType
+}
+
+func (e *FileDeletedEvent) File() string {
+	return e.file
+}
+
+func (e *FileDeletedEvent) Size() int64 {
+	return e.size
+}
+
+func (e *FileDeletedEvent) Reason() DeleteReason {
+	return e.reason
+}
+
+func (e *FileReopenedEvent) Type() EventType {
+	return FileReopenedEventType
+}
+
+func (e *FileReopenedEvent) File() string {
+	return e.file
+}
+
+func (e *FileReopenedEvent) Reason() ReopenReason {
+	return e.reason
+}
+
+func (e *FileUploadedEvent) Type() EventType {
+	return FileUploadedEventType
+}
+
+func (e *FileUploadedEvent) File() string {
+	return e.file
+}
+
+func (e *FileUploadedEvent) Location() string {
+	return e.location
+}
+
+func (e *FileUploadedEvent) Attempts() int {
+	return e.attempts
+}
+
+func (e *ErrorEvent) Type() EventType {
+	return ErrorEventType
+}
+
+func (e *ErrorEvent) Op() ErrorOp {
+	return e.op
+}
+
+func (e *ErrorEvent) File() string {
+	return e.file
+}
+
+func (e *ErrorEvent) Err() error {
+	return e.err
+}
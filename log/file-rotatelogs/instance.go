@@ -0,0 +1,69 @@
+package rotatelogs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstanceSuffix 多个进程写入同一文件名模式时,自动附加到文件名上的实例后缀类型
+type InstanceSuffix int
+
+const (
+	InstanceSuffixNone  InstanceSuffix = iota // 不附加后缀,所有进程写入同一文件
+	InstanceSuffixPID                         // 附加".pid<PID>"
+	InstanceSuffixIndex                       // 附加".inst<N>",N为当前空闲的最小实例序号,重启后可复用
+)
+
+// maxInstanceIndex 实例序号的上限
+const maxInstanceIndex = 1024
+
+// acquireInstance 根据实例后缀类型计算文件名后缀。
+// InstanceSuffixIndex模式下通过在每个序号的锁文件上加锁来占用序号,进程退出或Close时释放
+func (rl *RotateLogs) acquireInstance(mode InstanceSuffix, pattern string) error {
+	switch mode {
+	case InstanceSuffixNone:
+		return nil
+	case InstanceSuffixPID:
+		rl.instanceSuffix = fmt.Sprintf(".pid%d", os.Getpid())
+		return nil
+	case InstanceSuffixIndex:
+	default:
+		return fmt.Errorf("unknown instance suffix %d", mode)
+	}
+
+	dir := filepath.Dir(pattern)
+	if strings.Contains(dir, "%") {
+		return errors.New("instance index requires a pattern whose directory does not contain strftime verbs")
+	}
+	for n := 0; n < maxInstanceIndex; n++ {
+		lock, err := tryLock(filepath.Join(dir, fmt.Sprintf("%s.inst%d_lock", lockName(pattern), n)))
+		if errors.Is(err, errLockBusy) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to acquire instance index %w", err)
+		}
+		rl.instanceLock = lock
+		rl.instanceSuffix = fmt.Sprintf(".inst%d", n)
+		return nil
+	}
+	return fmt.Errorf("no free instance index below %d", maxInstanceIndex)
+}
+
+// InstanceSuffix 返回附加到文件名上的实例后缀,未启用时返回空字符串
+func (rl *RotateLogs) InstanceSuffix() string {
+	return rl.instanceSuffix
+}
+
+// globPatterns 返回当前实例负责清理的文件的glob模式。
+// 启用实例后缀时只匹配本实例的文件(包括代数名称),避免删除其他进程正在写入的文件
+func (rl *RotateLogs) globPatterns() []string {
+	if rl.instanceSuffix == "" {
+		return []string{rl.globPattern}
+	}
+	own := rl.globPattern + rl.instanceSuffix
+	return []string{own, own + ".*"}
+}
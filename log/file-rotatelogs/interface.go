@@ -1,6 +1,7 @@
 package rotatelogs
 
 import (
+	"context"
 	"os"
 	"sync"
 	"time"
@@ -25,8 +26,13 @@ type Event interface {
 type EventType int
 
 const (
-	InvalidEventType     EventType = iota // 无效事件类型
-	FileRotatedEventType                  // 文件轮转事件类型
+	InvalidEventType        EventType = iota // 无效事件类型
+	FileRotatedEventType                     // 文件轮转事件类型
+	FileCompressedEventType                  // 文件压缩事件类型
+	FileDeletedEventType                     // 文件清理事件类型
+	FileReopenedEventType                    // 文件重新打开事件类型
+	FileUploadedEventType                    // 文件上传事件类型
+	ErrorEventType                           // 后台处理出错事件类型
 )
 
 // FileRotatedEvent 文件轮转事件,包含轮转前后的文件名
@@ -35,23 +41,71 @@ type FileRotatedEvent struct {
 	current string // 当前新文件名
 }
 
+// FileCompressedEvent 文件压缩事件,包含被压缩的文件名和压缩后的文件名
+type FileCompressedEvent struct {
+	source      string // 被压缩的文件名
+	destination string // 压缩后的文件名
+}
+
+// FileDeletedEvent 文件清理事件,包含被删除的文件名、大小和删除原因
+type FileDeletedEvent struct {
+	file   string       // 被删除的文件名
+	size   int64        // 删除前的文件大小
+	reason DeleteReason // 删除原因
+}
+
+// FileReopenedEvent 文件重新打开事件,包含重新打开的文件名和原因
+type FileReopenedEvent struct {
+	file   string       // 重新打开的文件名
+	reason ReopenReason // 重新打开的原因
+}
+
+// FileUploadedEvent 文件上传事件,包含上传的文件名、归档位置和尝试次数
+type FileUploadedEvent struct {
+	file     string // 上传的文件名
+	location string // 归档后的位置
+	attempts int    // 上传尝试次数
+}
+
+// ErrorEvent 后台处理出错事件,包含出错的操作、相关文件和错误
+type ErrorEvent struct {
+	op   ErrorOp // 出错的操作
+	file string  // 相关的文件名
+	err  error   // 错误
+}
+
 // RotateLogs 表示一个自动轮转的日志文件,当向其写入时会自动进行轮转
 type RotateLogs struct {
-	clock         Clock              // 时钟接口,用于获取当前时间
-	curFn         string             // 当前文件名
-	curBaseFn     string             // 当前基础文件名
-	globPattern   string             // 用于匹配日志文件的glob模式
-	generation    int                // 代数编号
-	linkName      string             // 符号链接名称
-	maxAge        time.Duration      // 日志文件最大保留时间
-	mutex         sync.RWMutex       // 读写互斥锁
-	eventHandler  Handler            // 事件处理器
-	outFh         *os.File           // 输出文件句柄
-	pattern       *strftime.Strftime // strftime格式模式
-	rotationTime  time.Duration      // 轮转时间间隔
-	rotationSize  int64              // 轮转文件大小阈值
-	rotationCount uint               // 保留的日志文件数量
-	forceNewFile  bool               // 是否强制创建新文件
+	clock          Clock              // 时钟接口,用于获取当前时间
+	curFn          string             // 当前文件名
+	curBaseFn      string             // 当前基础文件名
+	globPattern    string             // 用于匹配日志文件的glob模式
+	generation     int                // 代数编号
+	linkName       string             // 符号链接名称
+	maxAge         time.Duration      // 日志文件最大保留时间
+	mutex          sync.RWMutex       // 读写互斥锁
+	eventHandler   Handler            // 事件处理器
+	outFh          *os.File           // 输出文件句柄
+	outFi          os.FileInfo        // 输出文件句柄打开时的文件信息
+	pattern        *strftime.Strftime // strftime格式模式
+	rotationTime   time.Duration      // 轮转时间间隔
+	rotationSize   int64              // 轮转文件大小阈值
+	rotationCount  uint               // 保留的日志文件数量
+	forceNewFile   bool               // 是否强制创建新文件
+	compressor     Compressor         // 轮转后压缩旧文件的压缩器
+	maxTotalSize   int64              // 日志文件总大小上限
+	instanceSuffix string             // 附加到文件名上的实例后缀
+	instanceLock   *fileLock          // 占用实例序号的锁
+	uploader       Uploader           // 轮转后上传旧文件的上传器
+	uploadRetry    uploadRetry        // 上传重试参数
+	uploadCtx      context.Context    // 上传使用的context,Close时取消
+	uploadCancel   context.CancelFunc // 取消uploadCtx
+	manifest       string             // 上传清单路径
+	manifestMutex  sync.Mutex         // 上传清单写入锁
+	pending        pendingSet         // 等待压缩或上传的文件
+	bg             sync.WaitGroup     // 后台压缩和上传任务
+	sigCh          chan os.Signal     // 触发重新打开的信号
+	sigDone        chan struct{}      // 停止信号监听
 }
 
 // Clock 时钟接口,用于RotateLogs对象确定当前时间
@@ -0,0 +1,56 @@
+package rotatelogs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errLockBusy 锁已被其他进程持有
+var errLockBusy = errors.New("rotation lock is held by another process")
+
+// lockName 根据文件名模式生成锁文件的基础名称,同一目录下相同模式的所有进程共享同一把锁
+func lockName(pattern string) string {
+	return "." + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, filepath.Base(pattern))
+}
+
+// rotationLockPath 返回保护轮转后处理(更新符号链接、清理旧文件)的锁文件路径。
+// 启用实例后缀时每个实例有自己的符号链接和文件,锁名称也带上实例后缀,避免实例之间互相跳过
+func (rl *RotateLogs) rotationLockPath(filename string) string {
+	return filepath.Join(filepath.Dir(filename), lockName(rl.pattern.Pattern())+`_lock`+rl.instanceSuffix)
+}
+
+// fileLock 跨进程的排他锁
+type fileLock struct {
+	path string
+	fh   *os.File
+}
+
+// tryLock 以非阻塞方式获取path上的排他锁,锁被其他进程持有时返回errLockBusy
+func tryLock(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	fh, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileLock{path: path, fh: fh}, nil
+}
+
+// Unlock 释放锁
+func (l *fileLock) Unlock() {
+	if l == nil || l.fh == nil {
+		return
+	}
+	unlockFile(l.path, l.fh)
+	l.fh = nil
+}
@@ -0,0 +1,45 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package rotatelogs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile 使用flock获取建议锁。进程崩溃时内核会自动释放锁,因此遗留的锁文件不会阻塞后续的加锁。
+// 持有者在释放前会删除锁文件,所以加锁成功后需要确认路径仍指向同一个inode,否则重试
+func lockFile(path string) (*os.File, error) {
+	for {
+		fh, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(fh.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			fh.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errLockBusy
+			}
+			return nil, err
+		}
+
+		locked, err := fh.Stat()
+		if err != nil {
+			fh.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(locked, current) {
+			return fh, nil
+		}
+		// 锁文件已被上一个持有者删除,在新文件上重新加锁
+		fh.Close()
+	}
+}
+
+// unlockFile 先删除锁文件再释放flock,保证之后按路径打开的进程拿到的是新的锁文件
+func unlockFile(path string, fh *os.File) {
+	os.Remove(path)
+	syscall.Flock(int(fh.Fd()), syscall.LOCK_UN)
+	fh.Close()
+}
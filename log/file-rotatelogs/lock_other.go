@@ -0,0 +1,39 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package rotatelogs
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// staleLockTimeout 超过该时长的锁文件被视为崩溃遗留的陈旧锁
+const staleLockTimeout = time.Minute
+
+// lockFile 在不支持flock的平台上使用O_EXCL创建锁文件。
+// 锁文件超过staleLockTimeout未被释放时视为持有者已崩溃,删除后重新获取
+func lockFile(path string) (*os.File, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		fh, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fh.WriteString(strconv.Itoa(os.Getpid()))
+			return fh, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		fi, statErr := os.Stat(path)
+		if statErr != nil || time.Since(fi.ModTime()) < staleLockTimeout {
+			return nil, errLockBusy
+		}
+		os.Remove(path)
+	}
+	return nil, errLockBusy
+}
+
+// unlockFile 关闭并删除锁文件
+func unlockFile(path string, fh *os.File) {
+	fh.Close()
+	os.Remove(path)
+}
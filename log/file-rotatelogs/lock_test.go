@@ -0,0 +1,34 @@
+package rotatelogs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotationLockPerInstance(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app.log.%Y%m%d")
+
+	// 未启用实例后缀的进程持有共享的轮转锁
+	shared, err := tryLock(filepath.Join(dir, lockName(pattern)+`_lock`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Unlock()
+
+	rl, err := New(pattern,
+		WithLinkName(filepath.Join(dir, "app.log")),
+		WithInstanceSuffix(InstanceSuffixIndex),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+	if _, err := rl.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "app.log"+rl.InstanceSuffix())); err != nil {
+		t.Errorf("实例应该使用自己的轮转锁并更新符号链接: %v", err)
+	}
+}
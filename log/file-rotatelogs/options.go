@@ -2,19 +2,30 @@ package rotatelogs
 
 import (
 	"github.com/Cospk/base-tools/log/file-rotatelogs/internal/option"
+	"os"
 	"time"
 )
 
 // 选项键常量定义
 const (
-	optkeyClock         = "clock"
-	optkeyHandler       = "handler"
-	optkeyLinkName      = "link-name"
-	optkeyMaxAge        = "max-age"
-	optkeyRotationTime  = "rotation-time"
-	optkeyRotationSize  = "rotation-size"
-	optkeyRotationCount = "rotation-count"
-	optkeyForceNewFile  = "force-new-file"
+	optkeyClock          = "clock"
+	optkeyHandler        = "handler"
+	optkeyLinkName       = "link-name"
+	optkeyMaxAge         = "max-age"
+	optkeyRotationTime   = "rotation-time"
+	optkeyRotationSize   = "rotation-size"
+	optkeyRotationCount  = "rotation-count"
+	optkeyForceNewFile   = "force-new-file"
+	optkeyCompressor     = "compressor"
+	optkeyMaxTotalSize   = "max-total-size"
+	optkeyInstanceSuffix = "instance-suffix"
+	optkeyQueueSize      = "queue-size"
+	optkeyBatchSize      = "batch-size"
+	optkeyOverflowPolicy = "overflow-policy"
+	optkeyReopenSignals  = "reopen-signals"
+	optkeyUploader       = "uploader"
+	optkeyUploadRetry    = "upload-retry"
+	optkeyUploadManifest = "upload-manifest"
 )
 
 // WithClock 创建一个新的Option,设置RotateLogs对象用于确定当前时间的时钟接口
@@ -59,8 +70,15 @@ func WithRotationCount(n uint) Option {
 	return option.New(optkeyRotationCount, n)
 }
 
+// WithMaxTotalSize 创建一个新的Option,设置所有匹配日志文件的总大小上限(字节)。
+// 超出时从最旧的文件开始删除,直到总大小不超过上限,当前正在写入的文件不会被删除。
+// 可以与WithMaxAge或WithRotationCount同时使用
+func WithMaxTotalSize(n int64) Option {
+	return option.New(optkeyMaxTotalSize, n)
+}
+
 // WithHandler 创建一个新的Option,指定在事件发生时被调用的Handler对象
-// 目前支持`FileRotated`事件
+// 目前支持`FileRotated`、`FileCompressed`、`FileDeleted`、`FileReopened`、`FileUploaded`和`Error`事件
 func WithHandler(h Handler) Option {
 	return option.New(optkeyHandler, h)
 }
@@ -70,3 +88,56 @@ func WithHandler(h Handler) Option {
 func ForceNewFile() Option {
 	return option.New(optkeyForceNewFile, true)
 }
+
+// WithCompression 创建一个新的Option,在轮转后于后台压缩被轮转出去的文件,
+// 如rotatelogs.Gzip。符号链接当前指向的文件永远不会被压缩
+func WithCompression(c Compressor) Option {
+	return option.New(optkeyCompressor, c)
+}
+
+// WithInstanceSuffix 创建一个新的Option,在多个进程写入同一文件名模式时,
+// 自动为文件名和符号链接名附加本进程的实例后缀,使每个进程写入各自的文件。
+// 启用后每个实例只清理自己的文件
+func WithInstanceSuffix(s InstanceSuffix) Option {
+	return option.New(optkeyInstanceSuffix, s)
+}
+
+// WithReopenSignal 创建一个新的Option,收到指定信号时重新打开当前文件(见Reopen),
+// 通常传入rotatelogs.DefaultReopenSignals(SIGHUP和SIGUSR1)。Close时停止监听
+func WithReopenSignal(sigs ...os.Signal) Option {
+	return option.New(optkeyReopenSignals, sigs)
+}
+
+// WithUploader 创建一个新的Option,在轮转后于后台上传被轮转出去的文件,
+// 如rotatelogs.DirUploader或rotatelogs.S3Uploader。同时启用压缩时上传压缩后的文件。
+// 上传完成前文件不会被清理
+func WithUploader(u Uploader) Option {
+	return option.New(optkeyUploader, u)
+}
+
+// WithUploadRetry 创建一个新的Option,设置上传的最大尝试次数和首次重试前的等待时间,
+// 之后每次重试的等待时间翻倍。默认尝试3次,首次等待1秒
+func WithUploadRetry(attempts int, backoff time.Duration) Option {
+	return option.New(optkeyUploadRetry, uploadRetry{attempts: attempts, backoff: backoff})
+}
+
+// WithUploadManifest 创建一个新的Option,设置上传清单文件路径。
+// 每上传成功一个文件,就以JSON Lines格式追加一条ManifestEntry,可以用ReadManifest读取
+func WithUploadManifest(path string) Option {
+	return option.New(optkeyUploadManifest, path)
+}
+
+// WithQueueSize 创建一个新的Option,设置AsyncWriter队列最多容纳的写入条数,默认1024
+func WithQueueSize(n int) Option {
+	return option.New(optkeyQueueSize, n)
+}
+
+// WithBatchSize 创建一个新的Option,设置AsyncWriter每次合并写入底层Writer的最大条数,默认128
+func WithBatchSize(n int) Option {
+	return option.New(optkeyBatchSize, n)
+}
+
+// WithOverflowPolicy 创建一个新的Option,设置AsyncWriter队列已满时的处理策略,默认OverflowBlock
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return option.New(optkeyOverflowPolicy, p)
+}
@@ -0,0 +1,105 @@
+package rotatelogs
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/Cospk/base-tools/log/file-rotatelogs/internal/fileutil"
+)
+
+// ReopenReason 重新打开当前日志文件的原因
+type ReopenReason string
+
+const (
+	ReopenReasonRequested ReopenReason = "requested" // 调用了Reopen
+	ReopenReasonSignal    ReopenReason = "signal"    // 收到了WithReopenSignal指定的信号
+	ReopenReasonMoved     ReopenReason = "moved"     // 当前文件被外部删除或重命名
+)
+
+// Reopen 关闭当前文件句柄并按当前文件名重新打开。
+// 用于logrotate等外部工具移走文件之后,让后续写入落到新创建的文件中,不会触发轮转
+func (rl *RotateLogs) Reopen() error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	return rl.reopenNolock(ReopenReasonRequested)
+}
+
+// 此操作期间必须加锁
+func (rl *RotateLogs) reopenNolock(reason ReopenReason) error {
+	// 尚未打开过文件,下一次写入时会自动创建
+	if rl.outFh == nil {
+		return nil
+	}
+
+	fh, err := fileutil.CreateFile(rl.curFn)
+	if err != nil {
+		return fmt.Errorf("failed to reopen file %s %w", rl.curFn, err)
+	}
+	rl.outFh.Close()
+	rl.setOutputNolock(fh)
+
+	if h := rl.eventHandler; h != nil {
+		go h.Handle(&FileReopenedEvent{
+			file:   rl.curFn,
+			reason: reason,
+		})
+	}
+	return nil
+}
+
+// setOutputNolock 设置当前文件句柄,并记录其文件信息用于检测文件是否被外部移走
+func (rl *RotateLogs) setOutputNolock(fh *os.File) {
+	rl.outFh = fh
+	rl.outFi, _ = fh.Stat()
+}
+
+// movedNolock 根据对当前文件名的Stat结果判断当前文件句柄是否已不再对应该文件名,
+// 即文件被删除,或被重命名后在原位置创建了其他文件
+func (rl *RotateLogs) movedNolock(fi os.FileInfo, statErr error) bool {
+	if rl.outFh == nil || rl.outFi == nil {
+		return false
+	}
+	if statErr != nil {
+		return os.IsNotExist(statErr)
+	}
+	return !os.SameFile(fi, rl.outFi)
+}
+
+// watchSignals 收到指定信号时调用Reopen,Close时停止
+func (rl *RotateLogs) watchSignals(sigs []os.Signal) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	rl.sigCh = ch
+	rl.sigDone = done
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				rl.mutex.Lock()
+				err := rl.reopenNolock(ReopenReasonSignal)
+				rl.mutex.Unlock()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+					rl.emitError(ErrorOpReopen, rl.CurrentFileName(), err)
+				}
+			}
+		}
+	}()
+}
+
+// stopSignalsNolock 停止信号监听,可以重复调用
+func (rl *RotateLogs) stopSignalsNolock() {
+	if rl.sigDone == nil {
+		return
+	}
+	signal.Stop(rl.sigCh)
+	close(rl.sigDone)
+	rl.sigCh = nil
+	rl.sigDone = nil
+}
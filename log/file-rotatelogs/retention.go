@@ -0,0 +1,135 @@
+package rotatelogs
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeleteReason 日志文件被清理的原因
+type DeleteReason string
+
+const (
+	DeleteReasonMaxAge        DeleteReason = "max-age"        // 超过WithMaxAge设置的保留时间
+	DeleteReasonRotationCount DeleteReason = "rotation-count" // 超过WithRotationCount设置的文件数量
+	DeleteReasonMaxTotalSize  DeleteReason = "max-total-size" // 超过WithMaxTotalSize设置的总大小
+)
+
+// logFile 参与清理策略计算的日志文件
+type logFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// purgeNolock 按保留策略清理旧日志文件,current为当前正在写入的文件,永远不会被删除。
+// 依次应用MaxAge、RotationCount和MaxTotalSize策略,每删除一个文件发送一个FileDeletedEvent
+func (rl *RotateLogs) purgeNolock(current string) error {
+	matches, err := rl.matchFiles()
+	if err != nil {
+		return err
+	}
+
+	files := make([]logFile, 0, len(matches))
+	for _, path := range matches {
+		// 忽略锁文件、临时文件和符号链接
+		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") || strings.HasSuffix(path, compressingSuffix) {
+			continue
+		}
+		// 等待压缩或上传的文件暂不参与清理
+		if rl.pending.has(path) {
+			continue
+		}
+		fl, err := os.Lstat(path)
+		if err != nil || fl.Mode()&os.ModeSymlink == os.ModeSymlink {
+			continue
+		}
+		files = append(files, logFile{path: path, size: fl.Size(), modTime: fl.ModTime()})
+	}
+
+	reasons := make(map[string]DeleteReason)
+	remaining := func() []logFile {
+		kept := make([]logFile, 0, len(files))
+		for _, f := range files {
+			if _, ok := reasons[f.path]; !ok && f.path != current {
+				kept = append(kept, f)
+			}
+		}
+		return kept
+	}
+
+	if rl.maxAge > 0 {
+		cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
+		for _, f := range remaining() {
+			if !f.modTime.After(cutoff) {
+				reasons[f.path] = DeleteReasonMaxAge
+			}
+		}
+	}
+
+	if rl.rotationCount > 0 {
+		// 当前文件也计入保留数量
+		kept := remaining()
+		keep := int(rl.rotationCount)
+		if hasFile(files, current) {
+			keep--
+		}
+		if len(kept) > keep {
+			for _, f := range kept[:len(kept)-keep] {
+				reasons[f.path] = DeleteReasonRotationCount
+			}
+		}
+	}
+
+	if rl.maxTotalSize > 0 {
+		kept := remaining()
+		var total int64
+		for _, f := range files {
+			if _, ok := reasons[f.path]; !ok {
+				total += f.size
+			}
+		}
+		// 从最旧的文件开始删除,直到总大小不超过预算
+		sort.SliceStable(kept, func(i, j int) bool {
+			return kept[i].modTime.Before(kept[j].modTime)
+		})
+		for _, f := range kept {
+			if total <= rl.maxTotalSize {
+				break
+			}
+			reasons[f.path] = DeleteReasonMaxTotalSize
+			total -= f.size
+		}
+	}
+
+	for _, f := range files {
+		reason, ok := reasons[f.path]
+		if !ok {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			if !os.IsNotExist(err) {
+				rl.emitError(ErrorOpDelete, f.path, err)
+			}
+			continue
+		}
+		if h := rl.eventHandler; h != nil {
+			go h.Handle(&FileDeletedEvent{
+				file:   f.path,
+				size:   f.size,
+				reason: reason,
+			})
+		}
+	}
+	return nil
+}
+
+func hasFile(files []logFile, path string) bool {
+	for _, f := range files {
+		if f.path == path {
+			return true
+		}
+	}
+	return false
+}
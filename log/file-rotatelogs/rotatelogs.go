@@ -4,6 +4,7 @@
 package rotatelogs
 
 import (
+	"context"
 	"fmt"
 	"github.com/Cospk/base-tools/log/file-rotatelogs/internal/fileutil"
 	"github.com/lestrrat-go/strftime"
@@ -11,8 +12,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"errors"
@@ -43,6 +44,13 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 	var maxAge time.Duration
 	var handler Handler
 	var forceNewFile bool
+	var compressor Compressor
+	var maxTotalSize int64
+	var instanceSuffix InstanceSuffix
+	var reopenSignals []os.Signal
+	var uploader Uploader
+	var manifest string
+	retry := uploadRetry{attempts: defaultUploadAttempts, backoff: defaultUploadBackoff}
 
 	for _, o := range options {
 		switch o.Name() {
@@ -71,6 +79,29 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 			handler = o.Value().(Handler)
 		case optkeyForceNewFile:
 			forceNewFile = true
+		case optkeyCompressor:
+			compressor = o.Value().(Compressor)
+		case optkeyInstanceSuffix:
+			instanceSuffix = o.Value().(InstanceSuffix)
+		case optkeyReopenSignals:
+			reopenSignals = o.Value().([]os.Signal)
+		case optkeyUploader:
+			uploader = o.Value().(Uploader)
+		case optkeyUploadRetry:
+			retry = o.Value().(uploadRetry)
+			if retry.attempts < 1 {
+				retry.attempts = 1
+			}
+			if retry.backoff < 0 {
+				retry.backoff = 0
+			}
+		case optkeyUploadManifest:
+			manifest = o.Value().(string)
+		case optkeyMaxTotalSize:
+			maxTotalSize = o.Value().(int64)
+			if maxTotalSize < 0 {
+				maxTotalSize = 0
+			}
 		}
 	}
 
@@ -78,12 +109,12 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 		return nil, errors.New("options MaxAge and RotationCount cannot be both set")
 	}
 
-	if maxAge == 0 && rotationCount == 0 {
-		// 如果两者都为0,给maxAge一个合理的默认值
+	if maxAge == 0 && rotationCount == 0 && maxTotalSize == 0 {
+		// 如果都为0,给maxAge一个合理的默认值
 		maxAge = 7 * 24 * time.Hour
 	}
 
-	return &RotateLogs{
+	rl := &RotateLogs{
 		clock:         clock,
 		eventHandler:  handler,
 		globPattern:   globPattern,
@@ -94,7 +125,24 @@ func New(p string, options ...Option) (*RotateLogs, error) {
 		rotationSize:  rotationSize,
 		rotationCount: rotationCount,
 		forceNewFile:  forceNewFile,
-	}, nil
+		compressor:    compressor,
+		maxTotalSize:  maxTotalSize,
+		uploader:      uploader,
+		uploadRetry:   retry,
+		manifest:      manifest,
+	}
+	rl.uploadCtx, rl.uploadCancel = context.WithCancel(context.Background())
+	if err := rl.acquireInstance(instanceSuffix, p); err != nil {
+		return nil, err
+	}
+	if rl.linkName != "" {
+		rl.linkName += rl.instanceSuffix
+	}
+	if len(reopenSignals) > 0 {
+		rl.watchSignals(reopenSignals)
+	}
+
+	return rl, nil
 }
 
 // Write 实现io.Writer接口。它写入到当前正在使用的相应文件句柄。
@@ -119,7 +167,7 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 
 	// 此文件名包含要记录到的"新"文件名,
 	// 可能比rl.currentFilename更新
-	baseFn := fileutil.GenerateFn(rl.pattern, rl.clock, rl.rotationTime)
+	baseFn := fileutil.GenerateFn(rl.pattern, rl.clock, rl.rotationTime) + rl.instanceSuffix
 	filename := baseFn
 	var forceNewFile bool
 
@@ -139,7 +187,12 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 		}
 	} else {
 		if !useGenerationalNames && !sizeRotation {
-			// 无需操作
+			// 当前文件被外部删除或重命名时,按原文件名重新打开,否则无需操作
+			if rl.movedNolock(fi, err) {
+				if err := rl.reopenNolock(ReopenReasonMoved); err != nil {
+					return nil, err
+				}
+			}
 			return rl.outFh, nil
 		}
 		forceNewFile = true
@@ -155,7 +208,7 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 			} else {
 				name = fmt.Sprintf("%s.%d", filename, generation)
 			}
-			if _, err := os.Stat(name); err != nil {
+			if _, err := os.Stat(name); err != nil && !rl.compressedExists(name) {
 				filename = name
 
 				break
@@ -169,7 +222,9 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 		return nil, fmt.Errorf("failed to create a new file %s %w", filename, err)
 	}
 
-	if err := rl.rotateNolock(filename); err != nil && errors.Is(err, errors.New("the file exists")) {
+	// 其他进程正持有轮转锁时,由它负责更新符号链接和清理旧文件。
+	// 共享同一把锁的进程写入同一组文件,所以持有者做的工作相同
+	if err := rl.rotateNolock(filename); err != nil && !errors.Is(err, errLockBusy) {
 		err = fmt.Errorf("failed to rotate %w", err)
 		if bailOnRotateFail {
 			// 轮转失败是一个问题,但仅仅因为无法重命名日志
@@ -184,10 +239,11 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 			return nil, err
 		}
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		rl.emitError(ErrorOpRotate, filename, err)
 	}
 
 	rl.outFh.Close()
-	rl.outFh = fh
+	rl.setOutputNolock(fh)
 	rl.curBaseFn = baseFn
 	rl.curFn = filename
 	rl.generation = generation
@@ -199,6 +255,10 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 		})
 	}
 
+	if previousFn != "" && previousFn != filename {
+		rl.archiveAsync(previousFn)
+	}
+
 	return fh, nil
 }
 
@@ -215,22 +275,6 @@ var patternConversionRegexps = []*regexp.Regexp{
 	regexp.MustCompile(`\*+`),
 }
 
-type cleanupGuard struct {
-	enable bool
-	fn     func()
-	mutex  sync.Mutex
-}
-
-func (g *cleanupGuard) Enable() {
-	g.mutex.Lock()
-	defer g.mutex.Unlock()
-	g.enable = true
-}
-
-func (g *cleanupGuard) Run() {
-	g.fn()
-}
-
 // Rotate 强制轮转日志文件。如果生成的文件名因文件已存在而冲突,
 // 则在日志文件末尾附加数字后缀,形式为".1"、".2"、".3"等。
 //
@@ -244,19 +288,12 @@ func (rl *RotateLogs) Rotate() error {
 }
 
 func (rl *RotateLogs) rotateNolock(filename string) error {
-	lockfn := filename + `_lock`
-	fh, err := os.OpenFile(lockfn, os.O_CREATE|os.O_EXCL, 0644)
+	// 更新符号链接和清理旧文件需要在所有写入同一模式的进程之间互斥
+	lock, err := tryLock(rl.rotationLockPath(filename))
 	if err != nil {
-		// 无法加锁,直接返回
 		return err
 	}
-
-	var guard cleanupGuard
-	guard.fn = func() {
-		fh.Close()
-		os.Remove(lockfn)
-	}
-	defer guard.Run()
+	defer lock.Unlock()
 
 	if rl.linkName != "" {
 		tmpLinkName := filename + `_symlink`
@@ -294,81 +331,68 @@ func (rl *RotateLogs) rotateNolock(filename string) error {
 		}
 	}
 
-	if rl.maxAge <= 0 && rl.rotationCount <= 0 {
-		return errors.New("panic: maxAge and rotationCount are both set")
-	}
-
-	matches, err := filepath.Glob(rl.globPattern)
-	if err != nil {
-		return err
+	if rl.maxAge <= 0 && rl.rotationCount <= 0 && rl.maxTotalSize <= 0 {
+		return errors.New("panic: maxAge, rotationCount and maxTotalSize are all unset")
 	}
 
-	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
-
-	// linter告诉我预先分配这个...
-	toUnlink := make([]string, 0, len(matches))
-	for _, path := range matches {
-		// 忽略锁文件
-		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
-			continue
-		}
+	return rl.purgeNolock(filename)
+}
 
-		fi, err := os.Stat(path)
-		if err != nil {
-			continue
+// matchFiles 返回匹配glob模式的文件,启用压缩时还包括压缩后的文件
+func (rl *RotateLogs) matchFiles() ([]string, error) {
+	patterns := rl.globPatterns()
+	if rl.compressor != nil {
+		for _, pattern := range patterns {
+			patterns = append(patterns, pattern+rl.compressor.Extension())
 		}
+	}
 
-		fl, err := os.Lstat(path)
+	var matches []string
+	seen := make(map[string]struct{})
+	for _, pattern := range patterns {
+		found, err := filepath.Glob(pattern)
 		if err != nil {
-			continue
-		}
-
-		if rl.maxAge > 0 && fi.ModTime().After(cutoff) {
-			continue
+			return nil, err
 		}
-
-		if rl.rotationCount > 0 && fl.Mode()&os.ModeSymlink == os.ModeSymlink {
-			continue
+		for _, path := range found {
+			if _, ok := seen[path]; !ok {
+				seen[path] = struct{}{}
+				matches = append(matches, path)
+			}
 		}
-		toUnlink = append(toUnlink, path)
 	}
-
-	if rl.rotationCount > 0 {
-		// 仅当文件数量超过rotationCount时才删除
-		if rl.rotationCount >= uint(len(toUnlink)) {
-			return nil
-		}
-
-		toUnlink = toUnlink[:len(toUnlink)-int(rl.rotationCount)]
+	if len(patterns) == 1 {
+		return matches, nil
 	}
-
-	if len(toUnlink) <= 0 {
-		return nil
+	// 按去掉压缩扩展名后的文件名排序,使压缩文件与未压缩文件保持原有的先后顺序
+	var ext string
+	if rl.compressor != nil {
+		ext = rl.compressor.Extension()
 	}
-
-	guard.Enable()
-	go func() {
-		// 在单独的goroutine中删除文件
-		for _, path := range toUnlink {
-			os.Remove(path)
-		}
-	}()
-
-	return nil
+	sort.SliceStable(matches, func(i, j int) bool {
+		return strings.TrimSuffix(matches[i], ext) < strings.TrimSuffix(matches[j], ext)
+	})
+	return matches, nil
 }
 
 // Close 实现io.Closer接口。如果你对该对象执行了任何写入操作,
-// 必须调用此方法。
+// 必须调用此方法。Close会取消正在进行的上传,等待后台压缩和上传任务结束,释放占用的实例序号并停止信号监听
 func (rl *RotateLogs) Close() error {
+	defer rl.bg.Wait()
+
 	rl.mutex.Lock()
 	defer rl.mutex.Unlock()
 
+	rl.stopSignalsNolock()
+	rl.uploadCancel()
+	rl.instanceLock.Unlock()
 	if rl.outFh == nil {
 		return nil
 	}
 
 	rl.outFh.Close()
 	rl.outFh = nil
+	rl.outFi = nil
 
 	return nil
 }
@@ -1,13 +1,22 @@
 package rotatelogs_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -595,3 +604,667 @@ func TestForceNewFile(t *testing.T) {
 		}
 	})
 }
+
+func TestCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-compression")
+	if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("Rotated files are compressed", func(t *testing.T) {
+		events := make(chan *rotatelogs.FileCompressedEvent, 10)
+		linkName := filepath.Join(dir, "current")
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "compress.log"),
+			rotatelogs.WithLinkName(linkName),
+			rotatelogs.WithCompression(rotatelogs.Gzip),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				if e.Type() == rotatelogs.FileCompressedEventType {
+					events <- e.(*rotatelogs.FileCompressedEvent)
+				}
+			})),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		rl.Write([]byte("first file"))
+		first := rl.CurrentFileName()
+		if !assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed") {
+			return
+		}
+		rl.Write([]byte("second file"))
+		current := rl.CurrentFileName()
+		if !assert.NoError(t, rl.Close(), "rl.Close should succeed") {
+			return
+		}
+
+		select {
+		case e := <-events:
+			assert.Equal(t, first, e.SourceFile())
+			assert.Equal(t, first+".gz", e.DestinationFile())
+		case <-time.After(time.Second):
+			t.Fatal("FileCompressedEvent was not emitted")
+		}
+		assert.NoFileExists(t, first)
+		assert.FileExists(t, current, "the linked file must not be compressed")
+
+		fh, err := os.Open(first + ".gz")
+		if !assert.NoError(t, err, "compressed file should exist") {
+			return
+		}
+		defer fh.Close()
+		zr, err := gzip.NewReader(fh)
+		if !assert.NoError(t, err, "gzip.NewReader should succeed") {
+			return
+		}
+		content, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, "first file", string(content))
+	})
+
+	t.Run("Compressed files are counted by retention", func(t *testing.T) {
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "count.log.%Y%m%d"),
+			rotatelogs.WithMaxAge(-1),
+			rotatelogs.WithRotationCount(2),
+			rotatelogs.WithCompression(rotatelogs.Gzip),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		defer rl.Close()
+		for i := 0; i < 5; i++ {
+			rl.Write([]byte("Hello, World!"))
+			if !assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed") {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		time.Sleep(time.Second)
+		files, _ := filepath.Glob(filepath.Join(dir, "count.log*"))
+		assert.Len(t, files, 2, "old compressed files should be purged: %v", files)
+	})
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-max-total-size")
+	if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	dummyTime := time.Now().Add(-7 * 24 * time.Hour)
+	dummyTime = dummyTime.Add(time.Duration(-1 * dummyTime.Nanosecond()))
+	clock := clockwork.NewFakeClockAt(dummyTime.Add(10 * time.Hour))
+
+	collect := func(events chan *rotatelogs.FileDeletedEvent) rotatelogs.Option {
+		return rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+			if e.Type() == rotatelogs.FileDeletedEventType {
+				events <- e.(*rotatelogs.FileDeletedEvent)
+			}
+		}))
+	}
+	wait := func(events chan *rotatelogs.FileDeletedEvent, n int) map[string]rotatelogs.DeleteReason {
+		got := make(map[string]rotatelogs.DeleteReason)
+		for i := 0; i < n; i++ {
+			select {
+			case e := <-events:
+				got[filepath.Base(e.File())] = e.Reason()
+				assert.Equal(t, int64(len("rotation test file\n")), e.Size())
+			case <-time.After(time.Second):
+				t.Fatalf("expected %d FileDeletedEvent, got %d", n, i)
+			}
+		}
+		return got
+	}
+
+	t.Run("Oldest files are purged until the budget fits", func(t *testing.T) {
+		sub := filepath.Join(dir, "size")
+		os.MkdirAll(sub, 0755)
+		CreateRotationTestFile(sub, dummyTime, time.Hour, 5)
+		events := make(chan *rotatelogs.FileDeletedEvent, 10)
+		rl, err := rotatelogs.New(
+			filepath.Join(sub, "log%Y%m%d%H%M%S"),
+			rotatelogs.WithClock(clock),
+			rotatelogs.WithMaxTotalSize(40),
+			collect(events),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		defer rl.Close()
+		if _, err := rl.Write([]byte("dummy")); err != nil {
+			t.Errorf("rl.Write failed: %v", err)
+			return
+		}
+
+		got := wait(events, 3)
+		for i := 0; i < 3; i++ {
+			name := "log" + dummyTime.Add(time.Duration(i)*time.Hour).Format("20060102150405")
+			assert.Equal(t, rotatelogs.DeleteReasonMaxTotalSize, got[name], "%s should be purged by size", name)
+		}
+		files, _ := filepath.Glob(filepath.Join(sub, "log*"))
+		assert.Len(t, files, 3, "two old files and the current file are kept")
+	})
+
+	t.Run("Combined with MaxAge", func(t *testing.T) {
+		sub := filepath.Join(dir, "age")
+		os.MkdirAll(sub, 0755)
+		CreateRotationTestFile(sub, dummyTime, time.Hour, 5)
+		events := make(chan *rotatelogs.FileDeletedEvent, 10)
+		rl, err := rotatelogs.New(
+			filepath.Join(sub, "log%Y%m%d%H%M%S"),
+			rotatelogs.WithClock(clock),
+			rotatelogs.WithMaxAge(9*time.Hour+30*time.Minute),
+			rotatelogs.WithMaxTotalSize(20),
+			collect(events),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		defer rl.Close()
+		if _, err := rl.Write([]byte("dummy")); err != nil {
+			t.Errorf("rl.Write failed: %v", err)
+			return
+		}
+
+		got := wait(events, 4)
+		name := func(i int) string {
+			return "log" + dummyTime.Add(time.Duration(i)*time.Hour).Format("20060102150405")
+		}
+		assert.Equal(t, rotatelogs.DeleteReasonMaxAge, got[name(0)])
+		for i := 1; i < 4; i++ {
+			assert.Equal(t, rotatelogs.DeleteReasonMaxTotalSize, got[name(i)])
+		}
+		assert.FileExists(t, filepath.Join(sub, name(4)))
+	})
+}
+
+func TestStaleRotationLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-stale-lock")
+	if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	dummyTime := time.Now().Add(-7 * 24 * time.Hour)
+	dummyTime = dummyTime.Add(time.Duration(-1 * dummyTime.Nanosecond()))
+	CreateRotationTestFile(dir, dummyTime, time.Hour, 5)
+
+	// 模拟崩溃的进程遗留下的锁文件
+	lockfn := filepath.Join(dir, ".log_Y_m_d_H_M_S_lock")
+	if !assert.NoError(t, os.WriteFile(lockfn, []byte("12345"), 0644), "creating a stale lock should succeed") {
+		return
+	}
+	stale := time.Now().Add(-time.Hour)
+	os.Chtimes(lockfn, stale, stale)
+
+	rl, err := rotatelogs.New(
+		filepath.Join(dir, "log%Y%m%d%H%M%S"),
+		rotatelogs.WithClock(clockwork.NewFakeClockAt(dummyTime.Add(10*time.Hour))),
+		rotatelogs.WithMaxAge(-1),
+		rotatelogs.WithRotationCount(2),
+	)
+	if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+		return
+	}
+	defer rl.Close()
+	if _, err := rl.Write([]byte("dummy")); err != nil {
+		t.Errorf("rl.Write failed: %v", err)
+		return
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "log*"))
+	assert.Len(t, files, 2, "old files should be purged despite the stale lock")
+	assert.NoFileExists(t, lockfn, "the lock file should be released")
+}
+
+func TestInstanceSuffix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-instance")
+	if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("PID suffix", func(t *testing.T) {
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "pid.log"),
+			rotatelogs.WithInstanceSuffix(rotatelogs.InstanceSuffixPID),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		defer rl.Close()
+		rl.Write([]byte("Hello, World!"))
+		assert.Equal(t, filepath.Join(dir, fmt.Sprintf("pid.log.pid%d", os.Getpid())), rl.CurrentFileName())
+	})
+
+	t.Run("Instance index", func(t *testing.T) {
+		pattern := filepath.Join(dir, "inst.log.%Y%m%d")
+		newInstance := func() *rotatelogs.RotateLogs {
+			rl, err := rotatelogs.New(
+				pattern,
+				rotatelogs.WithLinkName(filepath.Join(dir, "inst.log")),
+				rotatelogs.WithInstanceSuffix(rotatelogs.InstanceSuffixIndex),
+				rotatelogs.WithMaxAge(-1),
+				rotatelogs.WithRotationCount(1),
+			)
+			if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+				t.FailNow()
+			}
+			return rl
+		}
+
+		rl0 := newInstance()
+		rl1 := newInstance()
+		defer rl1.Close()
+		assert.Equal(t, ".inst0", rl0.InstanceSuffix())
+		assert.Equal(t, ".inst1", rl1.InstanceSuffix())
+
+		rl0.Write([]byte("first"))
+		rl1.Write([]byte("second"))
+		assert.True(t, strings.HasSuffix(rl0.CurrentFileName(), ".inst0"), "file name should end with the instance suffix")
+		assert.FileExists(t, filepath.Join(dir, "inst.log.inst0"), "link name should carry the instance suffix")
+		assert.FileExists(t, filepath.Join(dir, "inst.log.inst1"), "link name should carry the instance suffix")
+
+		// 实例只清理自己的文件
+		if !assert.NoError(t, rl0.Rotate(), "rl.Rotate should succeed") {
+			return
+		}
+		assert.FileExists(t, rl1.CurrentFileName(), "files of other instances must not be purged")
+
+		// 释放后序号可以被新实例复用
+		rl0.Close()
+		rl2 := newInstance()
+		defer rl2.Close()
+		assert.Equal(t, ".inst0", rl2.InstanceSuffix())
+	})
+}
+
+// blockingWriter 在gate关闭前阻塞每次写入,用于模拟慢速磁盘
+type blockingWriter struct {
+	started chan struct{}
+	gate    chan struct{}
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+	closed  bool
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{started: make(chan struct{}, 100), gate: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-w.gate
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *blockingWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	policies := []struct {
+		name   string
+		policy rotatelogs.OverflowPolicy
+		expect string
+	}{
+		{"Drop newest", rotatelogs.OverflowDropNewest, "abc"},
+		{"Drop oldest", rotatelogs.OverflowDropOldest, "acd"},
+	}
+	for _, tc := range policies {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newBlockingWriter()
+			aw := rotatelogs.NewAsyncWriter(w,
+				rotatelogs.WithQueueSize(2),
+				rotatelogs.WithBatchSize(1),
+				rotatelogs.WithOverflowPolicy(tc.policy),
+			)
+			aw.Write([]byte("a"))
+			<-w.started // "a"正在写入,队列为空
+
+			for _, s := range []string{"b", "c", "d"} {
+				n, err := aw.Write([]byte(s))
+				assert.NoError(t, err, "writes should not fail when the queue is full")
+				assert.Equal(t, 1, n)
+			}
+			stats := aw.Stats()
+			assert.Equal(t, 2, stats.Queued)
+			assert.Equal(t, uint64(1), stats.DroppedWrites)
+			assert.Equal(t, uint64(1), stats.DroppedBytes)
+
+			close(w.gate)
+			assert.NoError(t, aw.Close(), "aw.Close should succeed")
+			assert.Equal(t, tc.expect, w.String())
+			assert.True(t, w.closed, "the underlying writer should be closed")
+
+			_, err := aw.Write([]byte("e"))
+			assert.ErrorIs(t, err, rotatelogs.ErrAsyncWriterClosed)
+		})
+	}
+
+	t.Run("Block", func(t *testing.T) {
+		w := newBlockingWriter()
+		aw := rotatelogs.NewAsyncWriter(w, rotatelogs.WithQueueSize(1), rotatelogs.WithBatchSize(1))
+		aw.Write([]byte("a"))
+		<-w.started
+		aw.Write([]byte("b"))
+
+		done := make(chan struct{})
+		go func() {
+			aw.Write([]byte("c"))
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatal("write should block while the queue is full")
+		case <-time.After(100 * time.Millisecond):
+		}
+		close(w.gate)
+		<-done
+
+		assert.NoError(t, aw.Sync(), "aw.Sync should succeed")
+		assert.Equal(t, "abc", w.String(), "Sync should wait for all queued writes")
+		assert.Equal(t, uint64(0), aw.Stats().DroppedWrites)
+		aw.Close()
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "file-rotatelogs-async")
+		if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		rl, err := rotatelogs.New(filepath.Join(dir, "async.log"))
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		aw := rotatelogs.NewAsyncWriter(rl)
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(aw, "line %d\n", i)
+		}
+		assert.NoError(t, aw.Close(), "aw.Close should flush pending writes")
+
+		content, err := os.ReadFile(filepath.Join(dir, "async.log"))
+		if !assert.NoError(t, err, "reading the log file should succeed") {
+			return
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		assert.Len(t, lines, 1000)
+		assert.Equal(t, "line 999", lines[999], "writes should keep their order")
+	})
+}
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-reopen")
+	if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	events := make(chan *rotatelogs.FileReopenedEvent, 10)
+	rl, err := rotatelogs.New(
+		filepath.Join(dir, "reopen.log"),
+		rotatelogs.WithReopenSignal(rotatelogs.DefaultReopenSignals...),
+		rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+			if e.Type() == rotatelogs.FileReopenedEventType {
+				events <- e.(*rotatelogs.FileReopenedEvent)
+			}
+		})),
+	)
+	if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+		return
+	}
+	defer rl.Close()
+
+	waitEvent := func(reason rotatelogs.ReopenReason) {
+		select {
+		case e := <-events:
+			assert.Equal(t, reason, e.Reason())
+			assert.Equal(t, rl.CurrentFileName(), e.File())
+		case <-time.After(5 * time.Second):
+			t.Errorf("timeout waiting for %s event", reason)
+		}
+	}
+	readFile := func(name string) string {
+		content, err := os.ReadFile(name)
+		assert.NoError(t, err, "reading %s should succeed", name)
+		return string(content)
+	}
+
+	fn := filepath.Join(dir, "reopen.log")
+	rl.Write([]byte("first\n"))
+
+	t.Run("Moved", func(t *testing.T) {
+		// 模拟logrotate的create模式:重命名当前文件
+		if !assert.NoError(t, os.Rename(fn, fn+".1"), "renaming the log file should succeed") {
+			return
+		}
+		rl.Write([]byte("second\n"))
+		waitEvent(rotatelogs.ReopenReasonMoved)
+		assert.Equal(t, "first\n", readFile(fn+".1"))
+		assert.Equal(t, "second\n", readFile(fn), "writes should go to the recreated file")
+
+		// 文件被删除时同样重新创建
+		os.Remove(fn)
+		rl.Write([]byte("third\n"))
+		waitEvent(rotatelogs.ReopenReasonMoved)
+		assert.Equal(t, "third\n", readFile(fn))
+	})
+
+	t.Run("Requested", func(t *testing.T) {
+		if !assert.NoError(t, rl.Reopen(), "rl.Reopen should succeed") {
+			return
+		}
+		waitEvent(rotatelogs.ReopenReasonRequested)
+		rl.Write([]byte("fourth\n"))
+		assert.Equal(t, "third\nfourth\n", readFile(fn), "reopening should append to the existing file")
+	})
+
+	t.Run("Signal", func(t *testing.T) {
+		if len(rotatelogs.DefaultReopenSignals) == 0 {
+			t.Skip("reopen signals are not supported on this platform")
+		}
+		p, err := os.FindProcess(os.Getpid())
+		if !assert.NoError(t, err, "finding the current process should succeed") {
+			return
+		}
+		if !assert.NoError(t, p.Signal(rotatelogs.DefaultReopenSignals[0]), "sending the signal should succeed") {
+			return
+		}
+		waitEvent(rotatelogs.ReopenReasonSignal)
+	})
+}
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-upload")
+	if !assert.NoError(t, err, `creating temporary directory should succeed`) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("Directory archive", func(t *testing.T) {
+		archive := filepath.Join(dir, "archive")
+		manifest := filepath.Join(dir, "manifest.jsonl")
+		uploaded := make(chan *rotatelogs.FileUploadedEvent, 10)
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "dir.log"),
+			rotatelogs.WithCompression(rotatelogs.Gzip),
+			rotatelogs.WithUploader(&rotatelogs.DirUploader{Dir: archive}),
+			rotatelogs.WithUploadManifest(manifest),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				if e.Type() == rotatelogs.FileUploadedEventType {
+					uploaded <- e.(*rotatelogs.FileUploadedEvent)
+				}
+			})),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		rl.Write([]byte("Hello, World!"))
+		if !assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed") {
+			return
+		}
+		rl.Write([]byte("Hello, World!"))
+		rl.Close()
+
+		select {
+		case e := <-uploaded:
+			assert.Equal(t, filepath.Join(dir, "dir.log.gz"), e.File(), "the compressed file should be uploaded")
+			assert.Equal(t, filepath.Join(archive, "dir.log.gz"), e.Location())
+			assert.Equal(t, 1, e.Attempts())
+		default:
+			t.Fatal("Close should wait for pending uploads")
+		}
+		assert.FileExists(t, filepath.Join(archive, "dir.log.gz"))
+
+		entries, err := rotatelogs.ReadManifest(manifest)
+		if !assert.NoError(t, err, "reading the manifest should succeed") || !assert.Len(t, entries, 1) {
+			return
+		}
+		archived, _ := os.ReadFile(filepath.Join(archive, "dir.log.gz"))
+		sum := sha256.Sum256(archived)
+		assert.Equal(t, hex.EncodeToString(sum[:]), entries[0].SHA256)
+		assert.Equal(t, int64(len(archived)), entries[0].Size)
+	})
+
+	t.Run("S3 with retries", func(t *testing.T) {
+		var (
+			mutex    sync.Mutex
+			requests int
+			objects  = make(map[string]string)
+		)
+		// 本地S3替身:第一次请求返回503,之后保存对象
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			requests++
+			if r.Method != http.MethodPut || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if requests == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		}))
+		defer server.Close()
+
+		failures := make(chan *rotatelogs.ErrorEvent, 10)
+		uploaded := make(chan struct{}, 1)
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "s3.log"),
+			rotatelogs.WithUploader(&rotatelogs.S3Uploader{
+				Endpoint:  server.URL,
+				Bucket:    "logs",
+				Prefix:    "app/",
+				AccessKey: "AK",
+				SecretKey: "SK",
+			}),
+			rotatelogs.WithUploadRetry(3, 10*time.Millisecond),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				switch e.Type() {
+				case rotatelogs.ErrorEventType:
+					failures <- e.(*rotatelogs.ErrorEvent)
+				case rotatelogs.FileUploadedEventType:
+					uploaded <- struct{}{}
+				}
+			})),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		rl.Write([]byte("Hello, S3!"))
+		rl.Rotate()
+		// Close会取消正在进行的上传,等待上传完成后再关闭
+		select {
+		case <-uploaded:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the upload should succeed after a retry")
+		}
+		rl.Close()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Equal(t, 2, requests, "the failed upload should be retried")
+		assert.Equal(t, "Hello, S3!", objects["/logs/app/s3.log"])
+		assert.Len(t, failures, 0)
+	})
+
+	t.Run("Compression failure", func(t *testing.T) {
+		archive := filepath.Join(dir, "fallback")
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "fallback.log"),
+			rotatelogs.WithCompression(failingCompressor{}),
+			rotatelogs.WithUploader(&rotatelogs.DirUploader{Dir: archive}),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		rl.Write([]byte("Hello, World!"))
+		rl.Rotate()
+		rl.Close()
+		assert.FileExists(t, filepath.Join(archive, "fallback.log"), "the uncompressed file should be uploaded")
+	})
+
+	t.Run("Close cancels retries", func(t *testing.T) {
+		canceled := make(chan error, 1)
+		rl, err := rotatelogs.New(
+			filepath.Join(dir, "cancel.log"),
+			rotatelogs.WithUploader(uploaderFunc(func(ctx context.Context) error {
+				return errors.New("unavailable")
+			})),
+			rotatelogs.WithUploadRetry(3, time.Hour),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				if e.Type() == rotatelogs.ErrorEventType {
+					canceled <- e.(*rotatelogs.ErrorEvent).Err()
+				}
+			})),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			return
+		}
+		rl.Write([]byte("Hello, World!"))
+		rl.Rotate()
+		start := time.Now()
+		rl.Close()
+		assert.Less(t, time.Since(start), time.Minute, "Close should not wait for the retry backoff")
+		select {
+		case <-canceled:
+		case <-time.After(5 * time.Second):
+			t.Error("the failed upload should be reported")
+		}
+	})
+}
+
+// failingCompressor 总是返回错误的压缩器
+type failingCompressor struct{}
+
+func (failingCompressor) Extension() string { return ".fail" }
+
+func (failingCompressor) NewWriter(io.Writer) (io.WriteCloser, error) {
+	return nil, errors.New("compression failed")
+}
+
+// uploaderFunc 将函数适配为Uploader
+type uploaderFunc func(ctx context.Context) error
+
+func (f uploaderFunc) Upload(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	return "", f(ctx)
+}
@@ -0,0 +1,176 @@
+package rotatelogs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3签名相关常量
+const (
+	s3DefaultRegion   = "us-east-1"
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+// S3Uploader 使用S3 PutObject接口(AWS Signature V4签名)上传文件的上传器,
+// 兼容AWS S3以及MinIO等S3兼容存储。对象地址使用path-style,即Endpoint/Bucket/Prefix+文件名
+type S3Uploader struct {
+	Endpoint  string       // 服务地址,如"https://s3.us-east-1.amazonaws.com"
+	Bucket    string       // 存储桶
+	Region    string       // 区域,为空时使用us-east-1
+	AccessKey string       // 访问密钥ID
+	SecretKey string       // 访问密钥
+	Prefix    string       // 对象键前缀,如"logs/app/"
+	Client    *http.Client // 为空时使用http.DefaultClient
+}
+
+func (u *S3Uploader) Upload(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	key := u.Prefix + name
+	endpoint, err := url.Parse(strings.TrimRight(u.Endpoint, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %s %w", u.Endpoint, err)
+	}
+	endpoint.Path += "/" + u.Bucket + "/" + key
+	endpoint.RawPath = s3EscapePath(endpoint.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint.String(), r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	region := u.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	s3Sign(req, u.AccessKey, u.SecretKey, region, s3UnsignedPayload, time.Now())
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return "s3://" + u.Bucket + "/" + key, nil
+}
+
+// s3Sign 按AWS Signature V4为请求添加X-Amz-Date和Authorization头,
+// 签名覆盖Host以及请求中已设置的所有头
+func s3Sign(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(s3TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = strings.Join(strings.Fields(v[i]), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := now.Format(s3DateFormat) + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, s3Hash(canonicalRequest)}, "\n")
+
+	key := s3HMAC([]byte("AWS4"+secretKey), now.Format(s3DateFormat))
+	key = s3HMAC(key, region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+func s3Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3CanonicalQuery 按键排序并编码查询参数
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3EscapePath 按S3规则编码路径,保留"/"
+func s3EscapePath(path string) string {
+	return s3Escape(path, false)
+}
+
+// s3Escape 按RFC 3986编码,只保留非保留字符A-Z、a-z、0-9、"-"、"_"、"."、"~"
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package rotatelogs
+
+import "os"
+
+// DefaultReopenSignals 当前平台不支持SIGHUP/SIGUSR1,为空
+var DefaultReopenSignals []os.Signal
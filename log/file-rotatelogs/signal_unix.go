@@ -0,0 +1,11 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package rotatelogs
+
+import (
+	"os"
+	"syscall"
+)
+
+// DefaultReopenSignals logrotate等工具通常用来通知进程重新打开日志文件的信号
+var DefaultReopenSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR1}
@@ -0,0 +1,272 @@
+package rotatelogs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 上传重试的默认参数
+const (
+	defaultUploadAttempts = 3
+	defaultUploadBackoff  = time.Second
+)
+
+// Uploader 上传器接口,用于将轮转出去(以及压缩后)的日志文件归档到其他位置
+type Uploader interface {
+	// Upload 上传名为name的文件内容,size为内容长度,返回归档后的位置
+	Upload(ctx context.Context, name string, r io.Reader, size int64) (location string, err error)
+}
+
+// ManifestEntry 上传清单中的一条记录,对应一个已上传的文件
+type ManifestEntry struct {
+	File       string    `json:"file"`       // 本地文件名
+	Location   string    `json:"location"`   // 归档后的位置
+	Size       int64     `json:"size"`       // 文件大小
+	SHA256     string    `json:"sha256"`     // 文件内容的SHA256
+	Attempts   int       `json:"attempts"`   // 上传尝试次数
+	UploadedAt time.Time `json:"uploadedAt"` // 上传完成时间
+}
+
+// uploadRetry WithUploadRetry的参数
+type uploadRetry struct {
+	attempts int
+	backoff  time.Duration
+}
+
+// pendingSet 等待压缩或上传的文件,清理时会跳过这些文件
+type pendingSet struct {
+	mutex sync.Mutex
+	files map[string]int
+}
+
+func (s *pendingSet) add(files ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.files == nil {
+		s.files = make(map[string]int)
+	}
+	for _, f := range files {
+		s.files[f]++
+	}
+}
+
+func (s *pendingSet) remove(files ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, f := range files {
+		if s.files[f]--; s.files[f] <= 0 {
+			delete(s.files, f)
+		}
+	}
+}
+
+func (s *pendingSet) has(file string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.files[file] > 0
+}
+
+// archiveAsync 在后台处理已轮转出去的文件:先按需压缩,再按需上传,压缩失败时上传原文件。
+// 处理完成前该文件不会被清理
+func (rl *RotateLogs) archiveAsync(filename string) {
+	if rl.compressor == nil && rl.uploader == nil {
+		return
+	}
+	pending := []string{filename}
+	if rl.compressor != nil {
+		pending = append(pending, filename+rl.compressor.Extension())
+	}
+	rl.pending.add(pending...)
+
+	rl.bg.Add(1)
+	go func() {
+		defer rl.bg.Done()
+		defer rl.pending.remove(pending...)
+
+		// 压缩失败或不需要压缩时上传原文件
+		if rl.compressor != nil {
+			dest, err := rl.compressFile(filename)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to compress %s %s\n", filename, err.Error())
+				rl.emitError(ErrorOpCompress, filename, err)
+			} else if dest != "" {
+				if h := rl.eventHandler; h != nil {
+					h.Handle(&FileCompressedEvent{
+						source:      filename,
+						destination: dest,
+					})
+				}
+				filename = dest
+			}
+		}
+		if rl.uploader != nil {
+			if _, err := os.Stat(filename); err == nil {
+				rl.uploadFile(filename)
+			}
+		}
+	}()
+}
+
+// uploadFile 上传文件,失败时按指数退避重试,成功后写入上传清单并发送FileUploadedEvent。
+// Close会取消正在进行的上传和重试等待
+func (rl *RotateLogs) uploadFile(filename string) {
+	var (
+		entry *ManifestEntry
+		err   error
+	)
+	backoff := rl.uploadRetry.backoff
+	attempts := 0
+	for attempts < rl.uploadRetry.attempts {
+		if attempts > 0 {
+			if !sleepCtx(rl.uploadCtx, backoff) {
+				break
+			}
+			backoff *= 2
+		}
+		attempts++
+		if entry, err = rl.uploadOnce(rl.uploadCtx, filename); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to upload %s after %d attempts %w", filename, attempts, err)
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		rl.emitError(ErrorOpUpload, filename, err)
+		return
+	}
+
+	entry.Attempts = attempts
+	if err := rl.appendManifest(entry); err != nil {
+		err = fmt.Errorf("failed to write upload manifest %w", err)
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		rl.emitError(ErrorOpUpload, filename, err)
+	}
+	if h := rl.eventHandler; h != nil {
+		h.Handle(&FileUploadedEvent{
+			file:     filename,
+			location: entry.Location,
+			attempts: attempts,
+		})
+	}
+}
+
+func (rl *RotateLogs) uploadOnce(ctx context.Context, filename string) (*ManifestEntry, error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	location, err := rl.uploader.Upload(ctx, filepath.Base(filename), io.TeeReader(fh, hash), fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &ManifestEntry{
+		File:       filename,
+		Location:   location,
+		Size:       fi.Size(),
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		UploadedAt: time.Now(),
+	}, nil
+}
+
+// sleepCtx 等待d,ctx结束时提前返回false
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// appendManifest 以JSON Lines格式向上传清单追加一条记录,未设置清单路径时忽略
+func (rl *RotateLogs) appendManifest(entry *ManifestEntry) error {
+	if rl.manifest == "" {
+		return nil
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	rl.manifestMutex.Lock()
+	defer rl.manifestMutex.Unlock()
+	fh, err := os.OpenFile(rl.manifest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.Write(append(b, '\n')); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// ReadManifest 读取WithUploadManifest写入的上传清单
+func ReadManifest(path string) ([]ManifestEntry, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var entries []ManifestEntry
+	dec := json.NewDecoder(fh)
+	for {
+		var entry ManifestEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// DirUploader 将文件复制到本地(或挂载的网络)目录中归档的上传器
+type DirUploader struct {
+	Dir string // 归档目录,不存在时自动创建
+}
+
+func (u *DirUploader) Upload(ctx context.Context, name string, r io.Reader, size int64) (string, error) {
+	if err := os.MkdirAll(u.Dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s %w", u.Dir, err)
+	}
+	dest := filepath.Join(u.Dir, name)
+	tmp := dest + "_uploading"
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(fh, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("short copy: %d of %d bytes", n, size)
+	}
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return dest, nil
+}
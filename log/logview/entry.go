@@ -0,0 +1,188 @@
+// Package logview 解析ZapLogger输出的日志(控制台对齐格式或JSON格式),
+// 支持跨轮转文件按时间合并、条件过滤以及跟随当前日志文件
+package logview
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// TimeLayout 日志时间格式,与ZapLogger.timeEncoder保持一致
+const TimeLayout = "2006-01-02 15:04:05.000"
+
+// 日志字段键,与ZapLogger编码器配置保持一致
+const (
+	keyLevel   = "level"
+	keyTime    = "time"
+	keyCaller  = "caller"
+	keyMsg     = "msg"
+	keyLogger  = "logger"
+	keyPID     = "PID"
+	keyVersion = "version"
+)
+
+var (
+	ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	pidRegexp  = regexp.MustCompile(`^\[PID:(\d+)\]$`)
+
+	// ErrNotLogLine 表示该行不是可识别的日志行(例如panic堆栈的续行)
+	ErrNotLogLine = errs.New("not a log line")
+)
+
+// Entry 一条解析后的日志记录
+type Entry struct {
+	Time    time.Time      // 日志时间
+	Level   string         // 日志级别(大写),如INFO、WARN
+	PID     int            // 进程ID
+	Module  string         // 模块名称(JSON格式下为logger名称)
+	Version string         // 模块版本
+	Caller  string         // 调用位置
+	Message string         // 日志消息(已去除对齐空格)
+	Fields  map[string]any // 键值对字段
+	File    string         // 来源文件
+	Raw     string         // 原始日志行
+}
+
+// OperationID 返回记录中的operationID
+func (e *Entry) OperationID() string {
+	return e.FieldString("operationID")
+}
+
+// OpUserID 返回记录中的opUserID
+func (e *Entry) OpUserID() string {
+	return e.FieldString("opUserID")
+}
+
+// FieldString 以字符串形式返回字段值,字段不存在时返回空字符串
+func (e *Entry) FieldString(key string) string {
+	v, ok := e.Fields[key]
+	if !ok || v == nil {
+		return ""
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
+
+// ParseLine 解析一行日志,自动识别JSON格式和控制台格式
+func ParseLine(line string) (*Entry, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" {
+		return nil, ErrNotLogLine
+	}
+	var (
+		e   *Entry
+		err error
+	)
+	if strings.HasPrefix(line, "{") {
+		e, err = parseJSON(line)
+	} else {
+		e, err = parseConsole(line)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.Raw = line
+	return e, nil
+}
+
+func parseJSON(line string) (*Entry, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	fields := make(map[string]any)
+	if err := dec.Decode(&fields); err != nil {
+		return nil, ErrNotLogLine
+	}
+	ts, _ := fields[keyTime].(string)
+	t, err := time.ParseInLocation(TimeLayout, ts, time.Local)
+	if err != nil {
+		return nil, ErrNotLogLine
+	}
+	e := &Entry{Time: t}
+	e.Level, _ = fields[keyLevel].(string)
+	e.Caller, _ = fields[keyCaller].(string)
+	e.Module, _ = fields[keyLogger].(string)
+	e.Version, _ = fields[keyVersion].(string)
+	msg, _ := fields[keyMsg].(string)
+	e.Message = strings.TrimRight(msg, " ")
+	if n, ok := fields[keyPID].(json.Number); ok {
+		pid, _ := n.Int64()
+		e.PID = int(pid)
+	}
+	for _, k := range []string{keyLevel, keyTime, keyCaller, keyMsg, keyLogger, keyPID, keyVersion} {
+		delete(fields, k)
+	}
+	e.Fields = fields
+	return e, nil
+}
+
+// parseConsole 解析控制台格式:
+// time \t LEVEL \t [PID:n] \t module \t [version] \t [caller] \t msg \t {fields}
+// 其中module、version、caller均为可选列
+func parseConsole(line string) (*Entry, error) {
+	cols := strings.Split(ansiRegexp.ReplaceAllString(line, ""), "\t")
+	if len(cols) < 3 {
+		return nil, ErrNotLogLine
+	}
+	t, err := time.ParseInLocation(TimeLayout, cols[0], time.Local)
+	if err != nil {
+		return nil, ErrNotLogLine
+	}
+	e := &Entry{Time: t, Level: strings.TrimSpace(cols[1]), Fields: map[string]any{}}
+	rest := cols[2:]
+
+	// 最后一列为JSON字段时单独解析
+	if last := strings.TrimSpace(rest[len(rest)-1]); len(rest) > 1 && strings.HasPrefix(last, "{") && strings.HasSuffix(last, "}") {
+		dec := json.NewDecoder(strings.NewReader(last))
+		dec.UseNumber()
+		if err := dec.Decode(&e.Fields); err == nil {
+			rest = rest[:len(rest)-1]
+		}
+	}
+	e.Message = strings.TrimRight(rest[len(rest)-1], " ")
+	rest = rest[:len(rest)-1]
+
+	for _, col := range rest {
+		col = strings.TrimSpace(col)
+		switch {
+		case col == "":
+		case pidRegexp.MatchString(col):
+			e.PID, _ = strconv.Atoi(pidRegexp.FindStringSubmatch(col)[1])
+		case strings.HasPrefix(col, "[") && strings.HasSuffix(col, "]"):
+			// 版本列在[sdkType/platform]列之前,后者不记录
+			inner := col[1 : len(col)-1]
+			if isCaller(inner) {
+				e.Caller = inner
+			} else if e.Version == "" {
+				e.Version = inner
+			}
+		default:
+			e.Module = col
+		}
+	}
+	return e, nil
+}
+
+// isCaller 判断是否为"file.go:line"形式的调用位置
+func isCaller(s string) bool {
+	i := strings.LastIndexByte(s, ':')
+	if i <= 0 || i == len(s)-1 {
+		return false
+	}
+	_, err := strconv.Atoi(s[i+1:])
+	return err == nil && strings.HasSuffix(s[:i], ".go")
+}
@@ -0,0 +1,101 @@
+package logview
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// levelOrder 日志级别从低到高的顺序
+var levelOrder = map[string]int{
+	"DEBUG":  0,
+	"INFO":   1,
+	"WARN":   2,
+	"ERROR":  3,
+	"DPANIC": 4,
+	"PANIC":  5,
+	"FATAL":  6,
+}
+
+// Filter 日志过滤条件,零值表示不过滤
+type Filter struct {
+	MinLevel    string            // 最低日志级别,如"warn"表示只保留WARN及以上
+	OperationID string            // 按operationID精确匹配
+	OpUserID    string            // 按opUserID精确匹配
+	Since       time.Time         // 起始时间(包含)
+	Until       time.Time         // 截止时间(不包含)
+	KV          map[string]string // 任意字段精确匹配
+}
+
+// ParseLevel 校验并规范化日志级别名称
+func ParseLevel(level string) (string, error) {
+	l := strings.ToUpper(strings.TrimSpace(level))
+	if _, ok := levelOrder[l]; !ok {
+		return "", errs.ErrArgs.WrapMsg("unknown log level", "level", level)
+	}
+	return l, nil
+}
+
+// ParseKV 将"key=value"形式的参数解析为过滤条件
+func ParseKV(pairs []string) (map[string]string, error) {
+	kv := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, errs.ErrArgs.WrapMsg("kv filter must be key=value", "kv", p)
+		}
+		kv[k] = v
+	}
+	return kv, nil
+}
+
+// ParseTime 解析时间参数,支持日志时间格式、RFC3339以及相对当前时间的时长(如"1h"表示一小时前)
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{TimeLayout, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errs.ErrArgs.WrapMsg("invalid time", "time", s)
+}
+
+// Match 判断日志记录是否满足过滤条件
+func (f *Filter) Match(e *Entry) bool {
+	if f == nil {
+		return true
+	}
+	if f.MinLevel != "" {
+		min, ok := levelOrder[strings.ToUpper(f.MinLevel)]
+		if lv, known := levelOrder[e.Level]; ok && known && lv < min {
+			return false
+		}
+	}
+	if f.OperationID != "" && e.OperationID() != f.OperationID {
+		return false
+	}
+	if f.OpUserID != "" && e.OpUserID() != f.OpUserID {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	for k, v := range f.KV {
+		if _, ok := e.Fields[k]; !ok || e.FieldString(k) != v {
+			return false
+		}
+	}
+	return true
+}
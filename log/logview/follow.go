@@ -0,0 +1,156 @@
+package logview
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// DefaultFollowInterval 跟随模式下的默认轮询间隔
+const DefaultFollowInterval = 500 * time.Millisecond
+
+// Follow 跟随WithLinkName创建的符号链接所指向的当前日志文件,类似`tail -F`。
+// 从当前文件末尾开始读取,当符号链接切换到新文件时,读完旧文件(包括末尾没有换行的行)后从新文件开头继续。
+// ctx取消时返回nil
+func Follow(ctx context.Context, linkName string, interval time.Duration, filter *Filter, fn func(*Entry) error) error {
+	if interval <= 0 {
+		interval = DefaultFollowInterval
+	}
+	target, err := filepath.EvalSymlinks(linkName)
+	if err != nil {
+		return errs.WrapMsg(err, "resolve link failed", "link", linkName)
+	}
+	fh, err := os.Open(target)
+	if err != nil {
+		return errs.WrapMsg(err, "open log file failed", "file", target)
+	}
+	defer func() { fh.Close() }()
+	if _, err := fh.Seek(0, io.SeekEnd); err != nil {
+		return errs.WrapMsg(err, "seek log file failed", "file", target)
+	}
+
+	t := &tailer{file: target, filter: filter, fn: fn}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// 文件持续写入时不会等待ticker,每次循环都要检查ctx
+		if ctx.Err() != nil {
+			return nil
+		}
+		n, err := t.readFrom(ctx, fh)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if err := t.flush(); err != nil {
+				return err
+			}
+			// 没有新数据时检查符号链接是否已指向新文件
+			if next, err := filepath.EvalSymlinks(linkName); err == nil && next != target {
+				nfh, err := os.Open(next)
+				if err == nil {
+					if err := t.finish(ctx, fh); err != nil {
+						nfh.Close()
+						return err
+					}
+					fh.Close()
+					fh, target = nfh, next
+					t.file = next
+					continue
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// tailer 维护跟随模式下未完成的行和待输出的记录
+type tailer struct {
+	file    string
+	filter  *Filter
+	fn      func(*Entry) error
+	partial []byte
+	pending *Entry
+	buf     [32 * 1024]byte
+}
+
+// readFrom 读取文件中的新数据并处理完整的行,返回读取的字节数,ctx取消时提前返回
+func (t *tailer) readFrom(ctx context.Context, r io.Reader) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := r.Read(t.buf[:])
+		total += n
+		t.partial = append(t.partial, t.buf[:n]...)
+		for ctx.Err() == nil {
+			i := bytes.IndexByte(t.partial, '\n')
+			if i < 0 {
+				break
+			}
+			line := string(t.partial[:i])
+			t.partial = t.partial[i+1:]
+			if err := t.line(line); err != nil {
+				return total, err
+			}
+		}
+		if err == io.EOF || n == 0 {
+			return total, nil
+		}
+		if err != nil {
+			return total, errs.WrapMsg(err, "read log file failed", "file", t.file)
+		}
+	}
+	return total, nil
+}
+
+// finish 读完切换前的旧文件,轮转时被截断的最后一行没有换行,同样作为完整的行输出
+func (t *tailer) finish(ctx context.Context, r io.Reader) error {
+	if _, err := t.readFrom(ctx, r); err != nil {
+		return err
+	}
+	if len(t.partial) > 0 {
+		line := string(t.partial)
+		t.partial = t.partial[:0]
+		if err := t.line(line); err != nil {
+			return err
+		}
+	}
+	return t.flush()
+}
+
+func (t *tailer) line(line string) error {
+	e, err := ParseLine(line)
+	if err != nil {
+		if t.pending != nil {
+			t.pending.Raw += "\n" + line
+		}
+		return nil
+	}
+	e.File = t.file
+	if err := t.flush(); err != nil {
+		return err
+	}
+	t.pending = e
+	return nil
+}
+
+// flush 输出待处理的记录
+func (t *tailer) flush() error {
+	e := t.pending
+	if e == nil {
+		return nil
+	}
+	t.pending = nil
+	if !t.filter.Match(e) {
+		return nil
+	}
+	return t.fn(e)
+}
@@ -0,0 +1,62 @@
+package logview
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 与log包保持一致的级别颜色
+var levelColor = map[string]int{
+	"DEBUG":  37,
+	"INFO":   34,
+	"WARN":   33,
+	"ERROR":  31,
+	"DPANIC": 31,
+	"PANIC":  31,
+	"FATAL":  31,
+}
+
+// 优先输出的上下文字段
+var leadingKeys = []string{"operationID", "opUserID", "platform", "connID", "triggerID", "remoteAddr"}
+
+// Format 将日志记录格式化为便于阅读的单行文本,续行(如堆栈)保留在其后
+func Format(e *Entry, color bool) string {
+	var b strings.Builder
+	b.WriteString(e.Time.Format(TimeLayout))
+	b.WriteByte(' ')
+	level := fmt.Sprintf("%-5s", e.Level)
+	if c, ok := levelColor[e.Level]; ok && color {
+		level = fmt.Sprintf("\x1b[%dm%s\x1b[0m", c, level)
+	}
+	b.WriteString(level)
+	if e.Module != "" {
+		b.WriteString(" [" + e.Module + "]")
+	}
+	if e.Caller != "" {
+		b.WriteString(" " + e.Caller)
+	}
+	b.WriteString(" " + e.Message)
+
+	seen := make(map[string]struct{}, len(leadingKeys))
+	for _, k := range leadingKeys {
+		if _, ok := e.Fields[k]; ok {
+			seen[k] = struct{}{}
+			b.WriteString(" " + k + "=" + e.FieldString(k))
+		}
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		if _, ok := seen[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(" " + k + "=" + e.FieldString(k))
+	}
+	if i := strings.IndexByte(e.Raw, '\n'); i >= 0 {
+		b.WriteString(e.Raw[i:])
+	}
+	return b.String()
+}
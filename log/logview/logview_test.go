@@ -0,0 +1,224 @@
+package logview
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	consoleLine = "2026-10-18 16:57:01.487\t\x1b[34mINFO\x1b[0m\t\x1b[34m[PID:5714]     \x1b[0m\t\x1b[34mmod                      \x1b[0m\t[v1.0]                        \t[log/zap.go:467]                                  \thello world                                       \t{\"operationID\": \"op123\", \"opUserID\": \"u1\", \"k\": \"v\", \"n\": 3}"
+	jsonLine    = `{"level":"WARN","time":"2026-10-18 16:57:01.489","caller":"log/zap.go:476","msg":"warned                                            ","PID":5714,"version":"v1.0","operationID":"op123","opUserID":"u1","k":"v2","error":"boom"}`
+)
+
+func TestParseLine(t *testing.T) {
+	e, err := ParseLine(consoleLine)
+	require.NoError(t, err)
+	assert.Equal(t, "INFO", e.Level)
+	assert.Equal(t, 5714, e.PID)
+	assert.Equal(t, "mod", e.Module)
+	assert.Equal(t, "v1.0", e.Version)
+	assert.Equal(t, "log/zap.go:467", e.Caller)
+	assert.Equal(t, "hello world", e.Message)
+	assert.Equal(t, "op123", e.OperationID())
+	assert.Equal(t, "u1", e.OpUserID())
+	assert.Equal(t, "3", e.FieldString("n"))
+	assert.Equal(t, time.Date(2026, 10, 18, 16, 57, 1, 487e6, time.Local), e.Time)
+
+	e, err = ParseLine(jsonLine)
+	require.NoError(t, err)
+	assert.Equal(t, "WARN", e.Level)
+	assert.Equal(t, 5714, e.PID)
+	assert.Equal(t, "v1.0", e.Version)
+	assert.Equal(t, "warned", e.Message)
+	assert.Equal(t, "boom", e.FieldString("error"))
+	assert.NotContains(t, e.Fields, "msg")
+
+	_, err = ParseLine("goroutine 1 [running]:")
+	assert.ErrorIs(t, err, ErrNotLogLine)
+}
+
+func TestFilterMatch(t *testing.T) {
+	e, err := ParseLine(jsonLine)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"min level below", Filter{MinLevel: "INFO"}, true},
+		{"min level above", Filter{MinLevel: "ERROR"}, false},
+		{"operationID", Filter{OperationID: "op123"}, true},
+		{"other operationID", Filter{OperationID: "op124"}, false},
+		{"opUserID", Filter{OpUserID: "u2"}, false},
+		{"since", Filter{Since: e.Time}, true},
+		{"until", Filter{Until: e.Time}, false},
+		{"kv", Filter{KV: map[string]string{"k": "v2", "PID": ""}}, false},
+		{"kv match", Filter{KV: map[string]string{"k": "v2", "error": "boom"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(e))
+		})
+	}
+}
+
+func writeLog(t *testing.T, path string, mod time.Time, lines ...string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	require.NoError(t, os.Chtimes(path, mod, mod))
+}
+
+func writeGzipLog(t *testing.T, path string, mod time.Time, lines ...string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	require.NoError(t, os.Chtimes(path, mod, mod))
+}
+
+func jsonAt(ts, msg string) string {
+	return `{"level":"INFO","time":"` + ts + `","msg":"` + msg + `"}`
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeLog(t, filepath.Join(dir, "app.2026-10-17"), now.Add(-time.Hour),
+		jsonAt("2026-10-17 10:00:00.000", "a1"),
+		jsonAt("2026-10-17 10:00:02.000", "a2"),
+		"panic: stack line",
+	)
+	writeLog(t, filepath.Join(dir, "app.2026-10-17.1"), now,
+		jsonAt("2026-10-17 10:00:01.000", "b1"),
+		jsonAt("2026-10-17 10:00:03.000", "b2"),
+	)
+	writeGzipLog(t, filepath.Join(dir, "app.2026-10-16.gz"), now.Add(-2*time.Hour),
+		jsonAt("2026-10-16 23:59:59.000", "z1"),
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.2026-10-17_lock"), nil, 0644))
+	require.NoError(t, os.Symlink("app.2026-10-17.1", filepath.Join(dir, "app.current")))
+
+	files, err := Files(filepath.Join(dir, "app.%Y-%m-%d*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "app.2026-10-16.gz"), filepath.Join(dir, "app.2026-10-17"), filepath.Join(dir, "app.2026-10-17.1")}, files)
+
+	var got []string
+	var stack string
+	err = Merge(files, &Filter{}, func(e *Entry) error {
+		got = append(got, e.Message)
+		if e.Message == "a2" {
+			stack = e.Raw
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"z1", "a1", "b1", "a2", "b2"}, got)
+	assert.Contains(t, stack, "\npanic: stack line")
+	assert.Contains(t, Format(&Entry{Message: "a2", Raw: stack}, false), "\npanic: stack line")
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "app.1")
+	second := filepath.Join(dir, "app.2")
+	link := filepath.Join(dir, "app.log")
+	writeLog(t, first, time.Now(), jsonAt("2026-10-17 10:00:00.000", "old"))
+	require.NoError(t, os.Symlink("app.1", link))
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Follow(ctx, link, 10*time.Millisecond, &Filter{}, func(e *Entry) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, e.Message)
+			return nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	appendString := func(path, s string) {
+		fh, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = fh.WriteString(s)
+		require.NoError(t, err)
+		require.NoError(t, fh.Close())
+	}
+	appendLine := func(path, line string) { appendString(path, line+"\n") }
+	appendLine(first, jsonAt("2026-10-17 10:00:01.000", "new1"))
+	time.Sleep(50 * time.Millisecond)
+	// 轮转前的最后一行没有换行
+	appendString(first, jsonAt("2026-10-17 10:00:01.500", "cut"))
+	time.Sleep(50 * time.Millisecond)
+
+	// 模拟rotatelogs轮转:写入新文件并切换符号链接
+	appendLine(second, jsonAt("2026-10-17 10:00:02.000", "new2"))
+	require.NoError(t, os.Symlink("app.2", link+"_symlink"))
+	require.NoError(t, os.Rename(link+"_symlink", link))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 3
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"new1", "cut", "new2"}, got)
+}
+
+func TestFollowCancel(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.1")
+	link := filepath.Join(dir, "app.log")
+	writeLog(t, file, time.Now())
+	require.NoError(t, os.Symlink("app.1", link))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var count int
+	done := make(chan error, 1)
+	go func() {
+		// 收到第一条记录后取消,剩余的记录不再处理
+		done <- Follow(ctx, link, 10*time.Millisecond, &Filter{}, func(e *Entry) error {
+			count++
+			cancel()
+			return nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, jsonAt("2026-10-17 10:00:00.000", "line"))
+	}
+	fh, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = fh.WriteString(strings.Join(lines, "\n") + "\n")
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx取消后Follow应该立即返回")
+	}
+}
@@ -0,0 +1,224 @@
+package logview
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// maxLineSize 单行日志的最大长度
+const maxLineSize = 4 * 1024 * 1024
+
+// 与rotatelogs保持一致的strftime到glob的转换规则
+var patternConversionRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`%[%+A-Za-z]`),
+	regexp.MustCompile(`\*+`),
+}
+
+// GlobPattern 将rotatelogs的strftime文件名模式转换为glob模式,普通glob模式原样返回
+func GlobPattern(pattern string) string {
+	for _, re := range patternConversionRegexps {
+		pattern = re.ReplaceAllString(pattern, "*")
+	}
+	return pattern
+}
+
+// Files 返回匹配模式的日志文件(包括WithCompression压缩后的.gz文件),按修改时间从旧到新排序。
+// 锁文件、临时文件以及WithLinkName创建的符号链接会被忽略
+func Files(pattern string) ([]string, error) {
+	glob := GlobPattern(pattern)
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "invalid glob pattern", "pattern", pattern)
+	}
+	if compressed, err := filepath.Glob(glob + ".gz"); err == nil {
+		matches = append(matches, compressed...)
+	}
+	seen := make(map[string]struct{}, len(matches))
+	type file struct {
+		path string
+		mod  int64
+	}
+	files := make([]file, 0, len(matches))
+	for _, path := range matches {
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") || strings.HasSuffix(path, "_compressing") {
+			continue
+		}
+		fi, err := os.Lstat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, file{path: path, mod: fi.ModTime().UnixNano()})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].mod == files[j].mod {
+			return files[i].path < files[j].path
+		}
+		return files[i].mod < files[j].mod
+	})
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+// entryReader 从单个文件中逐条读取日志记录,无法解析的行作为上一条记录的续行
+type entryReader struct {
+	file    string
+	scanner *bufio.Scanner
+	pending *Entry
+	done    bool
+}
+
+func newEntryReader(file string, r io.Reader) *entryReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &entryReader{file: file, scanner: s}
+}
+
+// Next 返回下一条完整的日志记录,读取完毕时返回io.EOF
+func (r *entryReader) Next() (*Entry, error) {
+	for !r.done {
+		if !r.scanner.Scan() {
+			r.done = true
+			if err := r.scanner.Err(); err != nil {
+				return nil, errs.WrapMsg(err, "read log file failed", "file", r.file)
+			}
+			break
+		}
+		line := r.scanner.Text()
+		e, err := ParseLine(line)
+		if err != nil {
+			if r.pending != nil {
+				r.pending.Raw += "\n" + line
+			}
+			continue
+		}
+		e.File = r.file
+		prev := r.pending
+		r.pending = e
+		if prev != nil {
+			return prev, nil
+		}
+	}
+	if r.pending != nil {
+		e := r.pending
+		r.pending = nil
+		return e, nil
+	}
+	return nil, io.EOF
+}
+
+// mergeItem 堆中的元素,记录所属文件的序号以保证同一时间的记录稳定排序
+type mergeItem struct {
+	entry *Entry
+	index int
+}
+
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].entry.Time.Equal(h[j].entry.Time) {
+		return h[i].index < h[j].index
+	}
+	return h[i].entry.Time.Before(h[j].entry.Time)
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x any) { *h = append(*h, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// Merge 按时间顺序合并多个日志文件,对满足过滤条件的记录调用fn。
+// fn返回错误时停止合并并返回该错误
+func Merge(files []string, filter *Filter, fn func(*Entry) error) error {
+	readers := make([]*entryReader, 0, len(files))
+	for _, file := range files {
+		fh, err := openLog(file)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		readers = append(readers, newEntryReader(file, fh))
+	}
+
+	h := make(mergeHeap, 0, len(readers))
+	for i, r := range readers {
+		e, err := r.Next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h = append(h, mergeItem{entry: e, index: i})
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		item := heap.Pop(&h).(mergeItem)
+		if filter.Match(item.entry) {
+			if err := fn(item.entry); err != nil {
+				return err
+			}
+		}
+		e, err := readers[item.index].Next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		heap.Push(&h, mergeItem{entry: e, index: item.index})
+	}
+	return nil
+}
+
+// openLog 打开日志文件,rotatelogs压缩过的.gz文件会被透明解压
+func openLog(file string) (io.ReadCloser, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "open log file failed", "file", file)
+	}
+	if filepath.Ext(file) != ".gz" {
+		return fh, nil
+	}
+	zr, err := gzip.NewReader(fh)
+	if err != nil {
+		fh.Close()
+		return nil, errs.WrapMsg(err, "open gzip log file failed", "file", file)
+	}
+	return &gzipFile{Reader: zr, file: fh}, nil
+}
+
+// gzipFile 关闭时同时关闭gzip读取器和底层文件
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
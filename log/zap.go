@@ -22,6 +22,9 @@ var (
 		errs.ErrInternalServer.Code(): LevelError,
 	}
 	AsyncWrite = false
+	// AsyncWriteOptions AsyncWrite开启时传给rotatelogs.NewAsyncWriter的参数,
+	// 如队列大小、批量大小和队列满时的处理策略
+	AsyncWriteOptions []rotatelogs.Option
 )
 
 // LogFormatter 日志格式化器接口,用于自定义日志输出格式
@@ -127,6 +130,14 @@ func ZDebug(ctx context.Context, msg string, keysAndValues ...any) {
 	pkgLogger.Debug(ctx, msg, keysAndValues...)
 }
 
+// DebugEnabled 返回全局日志记录器是否输出调试级别日志,用于在关闭时跳过只为调试日志准备参数的开销
+func DebugEnabled() bool {
+	if l, ok := pkgLogger.(interface{ DebugEnabled() bool }); ok {
+		return l.DebugEnabled()
+	}
+	return true
+}
+
 // ZInfo 记录信息级别日志
 func ZInfo(ctx context.Context, msg string, keysAndValues ...any) {
 	pkgLogger.Info(ctx, msg, keysAndValues...)
@@ -297,19 +308,11 @@ func (l *ZapLogger) cores(isStdout bool, isJson bool, logLocation string, rotate
 	}
 
 	fileEncoder = &alignEncoder{Encoder: fileEncoder}
-	writer, err := l.getWriter(logLocation, rotateCount)
+	writer, err := l.getWriter(logLocation, rotateCount, !isStdout && AsyncWrite)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isStdout && AsyncWrite {
-		writer = &zapcore.BufferedWriteSyncer{
-			WS:            writer,
-			FlushInterval: time.Second * 2,
-			Size:          1024 * 512,
-		}
-	}
-
 	var cores []zapcore.Core
 	if logLocation != "" {
 		cores = []zapcore.Core{
@@ -406,7 +409,8 @@ func (l *ZapLogger) timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder)
 	enc.AppendString(t.Format(layout))
 }
 
-func (l *ZapLogger) getWriter(logLocation string, rorateCount uint) (zapcore.WriteSyncer, error) {
+// getWriter 创建按时间轮转的文件输出,async为true时包装为非阻塞的rotatelogs.AsyncWriter
+func (l *ZapLogger) getWriter(logLocation string, rorateCount uint, async bool) (zapcore.WriteSyncer, error) {
 	var path string
 	if l.rotationTime%(time.Hour*time.Duration(hoursPerDay)) == 0 {
 		path = logLocation + sp + l.loggerPrefixName + ".%Y-%m-%d"
@@ -422,6 +426,9 @@ func (l *ZapLogger) getWriter(logLocation string, rorateCount uint) (zapcore.Wri
 	if err != nil {
 		return nil, err
 	}
+	if async {
+		return rotatelogs.NewAsyncWriter(logf, AsyncWriteOptions...), nil
+	}
 	return zapcore.AddSync(logf), nil
 }
 
@@ -458,6 +465,11 @@ func (l *ZapLogger) Debug(ctx context.Context, msg string, keysAndValues ...any)
 	l.zap.Debugw(msg, keysAndValues...)
 }
 
+// DebugEnabled 返回是否输出调试级别日志
+func (l *ZapLogger) DebugEnabled() bool {
+	return l.level <= zapcore.DebugLevel
+}
+
 // Info 记录信息级别日志
 func (l *ZapLogger) Info(ctx context.Context, msg string, keysAndValues ...any) {
 	if l.level > zapcore.InfoLevel {
@@ -535,6 +547,10 @@ func (l *ZapLogger) kvAppend(ctx context.Context, keysAndValues []any) []any {
 		}
 	}
 
+    // BaggagePolicy.Log中配置的baggage成员
+    if baggage := mcontext.BaggageLogFields(ctx); len(baggage) > 0 {
+        keysAndValues = append(baggage, keysAndValues...)
+    }
     if opUserID != "" {
         keysAndValues = append([]any{constant.OpUserID, opUserID}, keysAndValues...)
     }
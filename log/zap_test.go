@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Cospk/base-tools/mcontext"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -298,3 +299,18 @@ func TestZapUncoveredFunctions_Integration(t *testing.T) {
 		// 两种方式都应该正常工作
 	})
 }
+
+// TestKvAppendBaggage 测试BaggagePolicy.Log中的baggage成员被追加到日志
+func TestKvAppendBaggage(t *testing.T) {
+	old := mcontext.GetBaggagePolicy()
+	defer mcontext.SetBaggagePolicy(old)
+	mcontext.SetBaggagePolicy(mcontext.BaggagePolicy{Log: []string{"ab.*"}})
+
+	ctx := mcontext.NewCtx("op-1")
+	ctx, _ = mcontext.SetBaggage(ctx, "ab.bucket", "B")
+	ctx, _ = mcontext.SetBaggage(ctx, "clientVersion", "1.2.0")
+
+	l := &ZapLogger{}
+	kv := l.kvAppend(ctx, []any{"key", "value"})
+	assert.Equal(t, []any{"operationID", "op-1", "ab.bucket", "B", "key", "value"}, kv)
+}
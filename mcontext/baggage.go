@@ -0,0 +1,271 @@
+package mcontext
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Cospk/base-tools/errs"
+	constant "github.com/Cospk/base-tools/utils/constants"
+)
+
+// W3C Baggage规范建议的默认限制
+const (
+	DefaultBaggageMaxMembers = 64
+	DefaultBaggageMaxBytes   = 8192
+)
+
+// BaggagePolicy baggage的限制和跨进程传递、日志输出的键,键支持"ab.*"形式的前缀匹配
+type BaggagePolicy struct {
+	// Allow 可以通过Inject传出和通过Extract传入的键,为空时baggage只在进程内使用
+	Allow []string
+	// Log 由log包自动输出到日志的键
+	Log []string
+	// MaxMembers 最大成员数,0时使用DefaultBaggageMaxMembers
+	MaxMembers int
+	// MaxBytes 按W3C格式编码后的最大字节数,0时使用DefaultBaggageMaxBytes
+	MaxBytes int
+}
+
+var baggagePolicy atomic.Pointer[BaggagePolicy]
+
+func init() {
+	SetBaggagePolicy(BaggagePolicy{})
+}
+
+// SetBaggagePolicy 设置全局的baggage策略,通常在程序启动时调用
+func SetBaggagePolicy(p BaggagePolicy) {
+	if p.MaxMembers <= 0 {
+		p.MaxMembers = DefaultBaggageMaxMembers
+	}
+	if p.MaxBytes <= 0 {
+		p.MaxBytes = DefaultBaggageMaxBytes
+	}
+	p.Allow = append([]string(nil), p.Allow...)
+	p.Log = append([]string(nil), p.Log...)
+	baggagePolicy.Store(&p)
+}
+
+// GetBaggagePolicy 返回当前的baggage策略
+func GetBaggagePolicy() BaggagePolicy {
+	return *baggagePolicy.Load()
+}
+
+type baggageKey struct{}
+
+// member baggage成员,props为原样保留的W3C属性,如"k=v;ttl=30"中的"ttl=30"
+type member struct {
+	key, value, props string
+}
+
+// SetBaggage 返回携带该baggage成员的新context,key已存在时替换其值。
+// key必须是HTTP token,超过成员数或字节数限制时返回错误
+func SetBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	if !isToken(key) {
+		return ctx, errs.ErrArgs.WrapMsg("invalid baggage key", "key", key)
+	}
+	old := baggageMembers(ctx)
+	members := make([]member, 0, len(old)+1)
+	for _, m := range old {
+		if m.key != key {
+			members = append(members, m)
+		}
+	}
+	members = append(members, member{key: key, value: value})
+	p := baggagePolicy.Load()
+	if len(members) > p.MaxMembers {
+		return ctx, errs.ErrArgs.WrapMsg("baggage exceeds max members", "key", key, "maxMembers", p.MaxMembers)
+	}
+	if n := len(encodeBaggage(members)); n > p.MaxBytes {
+		return ctx, errs.ErrArgs.WrapMsg("baggage exceeds max bytes", "key", key, "bytes", n, "maxBytes", p.MaxBytes)
+	}
+	return context.WithValue(ctx, baggageKey{}, members), nil
+}
+
+// DeleteBaggage 返回删除该baggage成员的新context
+func DeleteBaggage(ctx context.Context, key string) context.Context {
+	old := baggageMembers(ctx)
+	members := make([]member, 0, len(old))
+	for _, m := range old {
+		if m.key != key {
+			members = append(members, m)
+		}
+	}
+	if len(members) == len(old) {
+		return ctx
+	}
+	return context.WithValue(ctx, baggageKey{}, members)
+}
+
+// LookupBaggage 返回baggage成员的值,未设置时返回false
+func LookupBaggage(ctx context.Context, key string) (string, bool) {
+	for _, m := range baggageMembers(ctx) {
+		if m.key == key {
+			return m.value, true
+		}
+	}
+	return "", false
+}
+
+// GetBaggage 返回baggage成员的值,未设置时返回空字符串
+func GetBaggage(ctx context.Context, key string) string {
+	v, _ := LookupBaggage(ctx, key)
+	return v
+}
+
+// AllBaggage 返回所有baggage成员,没有时返回nil
+func AllBaggage(ctx context.Context) map[string]string {
+	members := baggageMembers(ctx)
+	if len(members) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(members))
+	for _, b := range members {
+		m[b.key] = b.value
+	}
+	return m
+}
+
+// BaggageLogFields 按BaggagePolicy.Log返回需要输出到日志的键值对,供log包使用
+func BaggageLogFields(ctx context.Context) []any {
+	p := baggagePolicy.Load()
+	if len(p.Log) == 0 {
+		return nil
+	}
+	var kv []any
+	for _, m := range baggageMembers(ctx) {
+		if matchKey(p.Log, m.key) {
+			kv = append(kv, m.key, m.value)
+		}
+	}
+	return kv
+}
+
+func baggageMembers(ctx context.Context) []member {
+	members, _ := ctx.Value(baggageKey{}).([]member)
+	return members
+}
+
+// injectBaggage 将允许传出的成员按W3C格式写入constants.Baggage头
+func injectBaggage(ctx context.Context, c Carrier) {
+	p := baggagePolicy.Load()
+	if len(p.Allow) == 0 {
+		return
+	}
+	var members []member
+	for _, m := range baggageMembers(ctx) {
+		if matchKey(p.Allow, m.key) {
+			members = append(members, m)
+		}
+	}
+	if len(members) > 0 {
+		c.Set(constant.Baggage, encodeBaggage(members))
+	}
+}
+
+// extractBaggage 从constants.Baggage头取出允许传入的成员,与ctx中已有的成员合并,
+// 无法解析的成员被忽略,超出限制的成员被丢弃
+func extractBaggage(ctx context.Context, c Carrier) context.Context {
+	p := baggagePolicy.Load()
+	header := c.Get(constant.Baggage)
+	if len(p.Allow) == 0 || header == "" {
+		return ctx
+	}
+	members := append([]member(nil), baggageMembers(ctx)...)
+	for _, item := range strings.Split(header, ",") {
+		m, ok := parseMember(item)
+		if !ok || !matchKey(p.Allow, m.key) {
+			continue
+		}
+		// 在副本上替换同名成员,超过限制时保留原成员
+		next := make([]member, 0, len(members)+1)
+		for _, old := range members {
+			if old.key != m.key {
+				next = append(next, old)
+			}
+		}
+		next = append(next, m)
+		if len(next) > p.MaxMembers || len(encodeBaggage(next)) > p.MaxBytes {
+			continue
+		}
+		members = next
+	}
+	return context.WithValue(ctx, baggageKey{}, members)
+}
+
+// parseMember 解析"key=value;prop1;prop2=v"形式的成员,value按百分号编码解码
+func parseMember(s string) (member, bool) {
+	s, props, _ := strings.Cut(s, ";")
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || !isToken(key) {
+		return member{}, false
+	}
+	value, err := url.PathUnescape(strings.TrimSpace(value))
+	if err != nil {
+		return member{}, false
+	}
+	return member{key: key, value: value, props: strings.TrimSpace(props)}, true
+}
+
+func encodeBaggage(members []member) string {
+	var b strings.Builder
+	for i, m := range members {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(m.key)
+		b.WriteByte('=')
+		b.WriteString(escapeBaggage(m.value))
+		if m.props != "" {
+			b.WriteByte(';')
+			b.WriteString(m.props)
+		}
+	}
+	return b.String()
+}
+
+// escapeBaggage 对W3C baggage-octet之外的字节以及"%"进行百分号编码
+func escapeBaggage(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c > 0x20 && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// isToken 判断是否为RFC 7230的token
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0 {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// matchKey 判断key是否在列表中,"ab.*"匹配以"ab."开头的键,"*"匹配所有键
+func matchKey(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if p == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package mcontext
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	constant "github.com/Cospk/base-tools/utils/constants"
+)
+
+func setTestBaggagePolicy(t *testing.T, p BaggagePolicy) {
+	old := GetBaggagePolicy()
+	t.Cleanup(func() { SetBaggagePolicy(old) })
+	SetBaggagePolicy(p)
+}
+
+// TestBaggage 测试baggage的读写和限制
+func TestBaggage(t *testing.T) {
+	setTestBaggagePolicy(t, BaggagePolicy{MaxMembers: 2, MaxBytes: 32})
+	ctx, err := SetBaggage(context.Background(), "ab", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, _ = SetBaggage(ctx, "ver", "1.0")
+	ctx, _ = SetBaggage(ctx, "ab", "C")
+	if GetBaggage(ctx, "ab") != "C" || len(AllBaggage(ctx)) != 2 {
+		t.Errorf("baggage错误: %v", AllBaggage(ctx))
+	}
+	if _, err := SetBaggage(ctx, "third", "x"); err == nil {
+		t.Error("超过成员数限制时应该返回错误")
+	}
+	if _, err := SetBaggage(ctx, "ver", strings.Repeat("x", 32)); err == nil {
+		t.Error("超过字节数限制时应该返回错误")
+	}
+	if _, err := SetBaggage(ctx, "bad key", "x"); err == nil {
+		t.Error("无效的键应该返回错误")
+	}
+	ctx = DeleteBaggage(ctx, "ab")
+	if _, ok := LookupBaggage(ctx, "ab"); ok {
+		t.Error("删除后不应该存在")
+	}
+}
+
+// TestBaggagePropagate 测试baggage按允许列表跨进程传递
+func TestBaggagePropagate(t *testing.T) {
+	setTestBaggagePolicy(t, BaggagePolicy{Allow: []string{"ab.*", "ver"}})
+	ctx, _ := SetBaggage(NewCtx("op-1"), "ab.bucket", "B 1,;%")
+	ctx, _ = SetBaggage(ctx, "ver", "1.0")
+	ctx, _ = SetBaggage(ctx, "secret", "s")
+
+	m := ToMap(ctx)
+	if m[constant.Baggage] != "ab.bucket=B%201%2C%3B%25,ver=1.0" {
+		t.Fatalf("baggage头错误: %q", m[constant.Baggage])
+	}
+
+	m[constant.Baggage] += ",secret=s,ab.x=y;ttl=30,invalid"
+	remote := FromMap(context.Background(), m)
+	if GetBaggage(remote, "ab.bucket") != "B 1,;%" || GetBaggage(remote, "ab.x") != "y" {
+		t.Errorf("取出的baggage错误: %v", AllBaggage(remote))
+	}
+	if _, ok := LookupBaggage(remote, "secret"); ok {
+		t.Error("不在允许列表中的键不应该被取出")
+	}
+	if got := ToMap(remote)[constant.Baggage]; !strings.Contains(got, "ab.x=y;ttl=30") {
+		t.Errorf("属性应该原样传递: %q", got)
+	}
+
+	setTestBaggagePolicy(t, BaggagePolicy{Allow: []string{"*"}, MaxMembers: 1})
+	remote = FromMap(context.Background(), m)
+	if len(AllBaggage(remote)) != 1 {
+		t.Errorf("超出限制的成员应该被丢弃: %v", AllBaggage(remote))
+	}
+
+	// 替换后超出限制时保留原成员
+	setTestBaggagePolicy(t, BaggagePolicy{Allow: []string{"*"}, MaxBytes: 16})
+	local, _ := SetBaggage(context.Background(), "ab", "1")
+	local = FromMap(local, MapCarrier{constant.Baggage: "ab=" + strings.Repeat("x", 16)})
+	if GetBaggage(local, "ab") != "1" {
+		t.Errorf("替换被拒绝时应该保留原成员: %v", AllBaggage(local))
+	}
+
+	setTestBaggagePolicy(t, BaggagePolicy{})
+	if _, ok := ToMap(ctx)[constant.Baggage]; ok {
+		t.Error("没有允许列表时不应该传出baggage")
+	}
+}
@@ -0,0 +1,189 @@
+package mcontext
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// AnyKey Key[T]的非泛型视图,用于遍历注册的所有键
+type AnyKey interface {
+	// Name 返回键名,如"operationID"
+	Name() string
+	// IsRequired 返回是否为GetMustCtxInfo和CheckRequired要求的必需键
+	IsRequired() bool
+	// Value 返回ctx中的值,未设置时返回false
+	Value(ctx context.Context) (any, bool)
+}
+
+// Key 类型化的context键,通过NewKey创建并注册,键名全局唯一:
+//
+//	var TenantID = mcontext.NewKey[string]("tenantID", mcontext.Required())
+//
+//	ctx = TenantID.Set(ctx, "t1")
+//	tenant := TenantID.Get(ctx)
+type Key[T any] struct {
+	name string
+}
+
+// KeyOption NewKey的配置选项
+type KeyOption func(*keyEntry)
+
+// Required 将键标记为必需,缺少时GetMustCtxInfo和CheckRequired返回错误
+func Required() KeyOption {
+	return func(e *keyEntry) {
+		e.required = true
+	}
+}
+
+// Propagate 将键标记为跨进程传递,Inject和Extract会通过HTTP头或gRPC metadata传递其值,
+// 只支持字符串类型的值
+func Propagate() KeyOption {
+	return func(e *keyEntry) {
+		e.propagated = true
+	}
+}
+
+type keyEntry struct {
+	key        AnyKey
+	required   bool
+	propagated bool
+}
+
+// registry 所有注册的键,按注册顺序排列
+var registry = struct {
+	sync.RWMutex
+	entries []*keyEntry
+	byName  map[string]*keyEntry
+}{byName: make(map[string]*keyEntry)}
+
+// NewKey 创建并注册一个键,键名重复时panic,通常在包级变量中调用
+func NewKey[T any](name string, opts ...KeyOption) *Key[T] {
+	k := &Key[T]{name: name}
+	e := &keyEntry{key: k}
+	for _, opt := range opts {
+		opt(e)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.byName[name]; ok {
+		panic(fmt.Sprintf("mcontext: key %q already registered", name))
+	}
+	registry.byName[name] = e
+	registry.entries = append(registry.entries, e)
+	return k
+}
+
+// Name 返回键名
+func (k *Key[T]) Name() string {
+	return k.name
+}
+
+// IsRequired 返回是否为必需键
+func (k *Key[T]) IsRequired() bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.byName[k.name].required
+}
+
+// Set 返回携带该值的新context
+func (k *Key[T]) Set(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, ctxKey(k.name), v)
+}
+
+// Lookup 返回ctx中的值,兼容使用原始字符串键名写入的值,未设置或类型不符时返回false
+func (k *Key[T]) Lookup(ctx context.Context) (T, bool) {
+	if v, ok := ctx.Value(ctxKey(k.name)).(T); ok {
+		return v, true
+	}
+	v, ok := ctx.Value(k.name).(T)
+	return v, ok
+}
+
+// Get 返回ctx中的值,未设置时返回零值
+func (k *Key[T]) Get(ctx context.Context) T {
+	v, _ := k.Lookup(ctx)
+	return v
+}
+
+// Value 实现AnyKey
+func (k *Key[T]) Value(ctx context.Context) (any, bool) {
+	return k.Lookup(ctx)
+}
+
+// Keys 返回所有注册的键,按注册顺序排列
+func Keys() []AnyKey {
+	registry.RLock()
+	defer registry.RUnlock()
+	keys := make([]AnyKey, 0, len(registry.entries))
+	for _, e := range registry.entries {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// LookupKey 按键名查找注册的键
+func LookupKey(name string) (AnyKey, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	e, ok := registry.byName[name]
+	if !ok {
+		return nil, false
+	}
+	return e.key, true
+}
+
+// SetRequired 修改已注册的键是否为必需键,键不存在时返回错误
+func SetRequired(name string, required bool) error {
+	registry.Lock()
+	defer registry.Unlock()
+	e, ok := registry.byName[name]
+	if !ok {
+		return errs.ErrArgs.WrapMsg("mcontext key not registered", "key", name)
+	}
+	e.required = required
+	return nil
+}
+
+// RequiredKeys 返回所有必需键的键名,按注册顺序排列
+func RequiredKeys() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	var names []string
+	for _, e := range registry.entries {
+		if e.required {
+			names = append(names, e.key.Name())
+		}
+	}
+	return names
+}
+
+// PropagatedKeys 返回所有跨进程传递的键名,按注册顺序排列
+func PropagatedKeys() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	var names []string
+	for _, e := range registry.entries {
+		if e.propagated {
+			names = append(names, e.key.Name())
+		}
+	}
+	return names
+}
+
+// CheckRequired 按注册顺序检查必需键,返回第一个缺少的键对应的错误
+func CheckRequired(ctx context.Context) error {
+	for _, name := range RequiredKeys() {
+		k, _ := LookupKey(name)
+		if _, ok := k.Value(ctx); !ok {
+			return missingKey(k)
+		}
+	}
+	return nil
+}
+
+func missingKey(k AnyKey) error {
+	return errs.ErrArgs.WrapMsg("ctx missing " + k.Name())
+}
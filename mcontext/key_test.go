@@ -0,0 +1,73 @@
+package mcontext
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestKey 测试类型化键的读写和原始字符串键的兼容
+func TestKey(t *testing.T) {
+	deadline := NewKey[time.Time]("test.deadline")
+	ctx := context.Background()
+	if _, ok := deadline.Lookup(ctx); ok {
+		t.Error("未设置的键应该返回false")
+	}
+	now := time.Now()
+	ctx = deadline.Set(ctx, now)
+	if !deadline.Get(ctx).Equal(now) {
+		t.Errorf("期望 %v, 实际为 %v", now, deadline.Get(ctx))
+	}
+
+	raw := context.WithValue(context.Background(), "operationID", "op-raw")
+	if GetOperationID(raw) != "op-raw" {
+		t.Errorf("应该兼容原始字符串键, 实际为 %q", GetOperationID(raw))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("重复注册键名应该panic")
+		}
+	}()
+	NewKey[string]("test.deadline")
+}
+
+// TestGetMustCtxInfo 测试必需键的注册和检查
+func TestGetMustCtxInfo(t *testing.T) {
+	ctx := WithMustInfoCtx([]string{"op-1", "u1", "ios", "c1"})
+	opID, userID, platform, connID, err := GetMustCtxInfo(ctx)
+	if err != nil || opID != "op-1" || userID != "u1" || platform != "ios" || connID != "c1" {
+		t.Errorf("获取上下文信息错误: %s %s %s %s %v", opID, userID, platform, connID, err)
+	}
+
+	_, _, _, _, err = GetMustCtxInfo(NewCtx("op-1"))
+	if err == nil || !strings.Contains(err.Error(), "ctx missing opUserID") {
+		t.Errorf("缺少opUserID时应该返回错误, 实际为 %v", err)
+	}
+	if _, _, _, _, err := GetCtxInfos(NewCtx("op-1")); err != nil {
+		t.Errorf("GetCtxInfos只要求operationID: %v", err)
+	}
+
+	// 新增的必需键无需修改GetMustCtxInfo
+	tenant := NewKey[string]("test.tenantID", Required())
+	defer SetRequired(tenant.Name(), false)
+	if _, _, _, _, err := GetMustCtxInfo(ctx); err == nil || !strings.Contains(err.Error(), "test.tenantID") {
+		t.Errorf("缺少新注册的必需键时应该返回错误, 实际为 %v", err)
+	}
+	if _, _, _, _, err := GetMustCtxInfo(tenant.Set(ctx, "t1")); err != nil {
+		t.Errorf("设置必需键后不应该返回错误: %v", err)
+	}
+
+	if err := SetRequired(OpUserPlatformKey.Name(), false); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRequired(OpUserPlatformKey.Name(), true)
+	want := []string{OperationIDKey.Name(), OpUserIDKey.Name(), "test.tenantID"}
+	if got := RequiredKeys(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("期望必需键 %v, 实际为 %v", want, got)
+	}
+	if err := SetRequired("not-registered", true); err == nil {
+		t.Error("未注册的键应该返回错误")
+	}
+}
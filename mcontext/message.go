@@ -0,0 +1,68 @@
+package mcontext
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// MapCarrier 以map[string]string为载体,用于Kafka、NATS、Redis Stream等消息头,键区分大小写
+type MapCarrier map[string]string
+
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// ToMap 将跨进程传递的上下文字段序列化为map,可以直接作为消息头发送,没有需要传递的字段时返回空map:
+//
+//	for k, v := range mcontext.ToMap(ctx) {
+//		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+//	}
+func ToMap(ctx context.Context) map[string]string {
+	m := make(MapCarrier)
+	Inject(ctx, m)
+	return m
+}
+
+// FromMap 从ToMap生成的map中取出上下文字段,返回基于ctx的新context
+func FromMap(ctx context.Context, m map[string]string) context.Context {
+	return Extract(ctx, MapCarrier(m))
+}
+
+// Marshal 将跨进程传递的上下文字段序列化为紧凑的JSON,用于只能携带单个字段的场景,如Redis Stream的一个field
+func Marshal(ctx context.Context) ([]byte, error) {
+	data, err := json.Marshal(ToMap(ctx))
+	if err != nil {
+		return nil, errs.WrapMsg(err, "mcontext marshal failed")
+	}
+	return data, nil
+}
+
+// Unmarshal 从Marshal生成的数据中取出上下文字段,返回基于ctx的新context,data为空时原样返回ctx
+func Unmarshal(ctx context.Context, data []byte) (context.Context, error) {
+	if len(data) == 0 {
+		return ctx, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ctx, errs.ErrArgs.WrapMsg("invalid mcontext data: "+err.Error(), "data", string(data))
+	}
+	return FromMap(ctx, m), nil
+}
+
+// Detach 返回保留ctx中所有值(包括mcontext字段)但不随ctx取消、也没有截止时间的新context,
+// 用于请求结束后仍需执行的后台任务,任务日志可以继续使用原请求的operationID:
+//
+//	go func(ctx context.Context) {
+//		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+//		defer cancel()
+//		sendEmail(ctx)
+//	}(mcontext.Detach(ctx))
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
@@ -0,0 +1,102 @@
+package mcontext
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMessage 测试通过消息头传递上下文字段
+func TestMessage(t *testing.T) {
+	ctx := WithMustInfoCtx([]string{"op-1", "u1", "ios"})
+	ctx = WithTriggerIDContext(ctx, "job-1")
+	m := ToMap(ctx)
+	if m["operationID"] != "op-1" || m["triggerID"] != "job-1" || len(m) != 5 {
+		t.Fatalf("ToMap结果错误: %v", m)
+	}
+
+	worker := FromMap(context.Background(), m)
+	if _, _, _, _, err := GetMustCtxInfo(worker); err != nil || GetTriggerID(worker) != "job-1" {
+		t.Errorf("FromMap取出的上下文字段错误: %v", err)
+	}
+
+	data, err := Marshal(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err = Unmarshal(context.Background(), data)
+	if err != nil || GetOpUserID(worker) != "u1" {
+		t.Errorf("Unmarshal结果错误: %q %v", GetOpUserID(worker), err)
+	}
+	if _, err := Unmarshal(context.Background(), []byte("{")); err == nil {
+		t.Error("无效的数据应该返回错误")
+	}
+	if len(ToMap(context.Background())) != 0 {
+		t.Error("没有上下文字段时应该返回空map")
+	}
+}
+
+// TestForward 测试未注册的自定义键经过多跳后继续传递
+func TestForward(t *testing.T) {
+	in := MapCarrier{"operationID": "op-1", "test.forward": "v", "customHeader": "operationID,test.forward"}
+	ctx := FromMap(context.Background(), in)
+	out := ToMap(ctx)
+	if out["test.forward"] != "v" || out["customHeader"] != "operationID,test.forward" {
+		t.Errorf("未注册的键应该继续传递: %v", out)
+	}
+}
+
+// TestDetach 测试Detach保留值但不随父context取消
+func TestDetach(t *testing.T) {
+	parent, cancel := context.WithTimeout(NewCtx("op-1"), time.Hour)
+	detached := Detach(parent)
+	cancel()
+	if parent.Err() == nil {
+		t.Fatal("父context应该已取消")
+	}
+	if detached.Err() != nil || detached.Done() != nil {
+		t.Error("Detach返回的context不应该被取消")
+	}
+	if _, ok := detached.Deadline(); ok {
+		t.Error("Detach返回的context不应该有截止时间")
+	}
+	if GetOperationID(detached) != "op-1" {
+		t.Errorf("期望operationID为op-1, 实际为 %q", GetOperationID(detached))
+	}
+}
+
+// TestForwardRegistered 测试只继续传递未注册的键,且数量有限制
+func TestForwardRegistered(t *testing.T) {
+	in := MapCarrier{
+		"test.forward.later": "v",
+		"remoteAddr":         "6.6.6.6",
+		"customHeader":       "test.forward.later,remoteAddr",
+	}
+	ctx := SetRemoteAddr(FromMap(context.Background(), in), "10.0.0.1")
+	NewKey[string]("test.forward.later")
+	out := ToMap(ctx)
+	if _, ok := out["test.forward.later"]; ok {
+		t.Errorf("之后注册为不传递的键不应该继续传递: %v", out)
+	}
+	if _, ok := out["remoteAddr"]; ok {
+		t.Errorf("remoteAddr不应该传递: %v", out)
+	}
+
+	ctx = context.Background()
+	for i := 0; i < 3; i++ {
+		m := MapCarrier{}
+		var names []string
+		for j := 0; j < maxCustomKeys; j++ {
+			name := fmt.Sprintf("test.forward.%d.%d", i, j)
+			m[name] = "v"
+			names = append(names, name)
+		}
+		m["customHeader"] = strings.Join(names, ",")
+		ctx = FromMap(ctx, m)
+	}
+	if n := len(ctx.Value(forwardKey{}).([]string)); n != maxCustomKeys {
+		t.Errorf("继续传递的键最多 %d 个, 实际为 %d", maxCustomKeys, n)
+	}
+}
@@ -0,0 +1,148 @@
+package mcontext
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	constant "github.com/Cospk/base-tools/utils/constants"
+)
+
+// Carrier 跨进程传递上下文字段的载体,如HTTP头或gRPC metadata
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// HeaderCarrier 以http.Header为载体,头名不区分大小写
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// MetadataCarrier 以gRPC metadata(metadata.MD)为载体,键统一转为小写
+type MetadataCarrier map[string][]string
+
+func (c MetadataCarrier) Get(key string) string {
+	if v := c[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c MetadataCarrier) Set(key, value string) {
+	c[strings.ToLower(key)] = []string{value}
+}
+
+// forwardKey context中记录Extract取出的未注册键名,Inject时继续传递
+type forwardKey struct{}
+
+// Inject 将所有跨进程传递的键(见PropagatedKeys)以及Extract取出的未注册键中非空的值写入载体,
+// BaggagePolicy.Allow允许的baggage成员写入constants.Baggage头,
+// 并在constants.RpcCustomHeader中记录写入的键名,使对端无需注册也能取出自定义的键
+func Inject(ctx context.Context, c Carrier) {
+	var names []string
+	for _, name := range propagatedNames(ctx) {
+		if s := stringValue(ctx, name); s != "" {
+			c.Set(name, s)
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		c.Set(constant.RpcCustomHeader, strings.Join(names, ","))
+	}
+	injectBaggage(ctx, c)
+}
+
+// 从载体中通过constants.RpcCustomHeader接收的未注册键的限制,超出的键被忽略
+const (
+	maxCustomKeys  = 16
+	maxCustomBytes = 4096
+)
+
+// Extract 从载体中取出跨进程传递的键以及constants.RpcCustomHeader中列出的未注册键,返回携带这些值的新context。
+// 已注册但未标记Propagate的键(如remoteAddr)不会从载体中取出;未注册的键最多取出16个、键名和值共4096字节,
+// 可以通过之后注册的Key[string]读取,并在Inject时继续传递。baggage只取出BaggagePolicy.Allow允许的成员
+func Extract(ctx context.Context, c Carrier) context.Context {
+	for _, name := range PropagatedKeys() {
+		if v := c.Get(name); v != "" {
+			ctx = context.WithValue(ctx, ctxKey(name), v)
+		}
+	}
+
+	forward, _ := ctx.Value(forwardKey{}).([]string)
+	n := len(forward)
+	seen := make(map[string]struct{})
+	var size int
+	for _, name := range strings.Split(c.Get(constant.RpcCustomHeader), ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := seen[name]; ok || name == "" || isRegistered(name) {
+			continue
+		}
+		v := c.Get(name)
+		if v == "" {
+			continue
+		}
+		if size += len(name) + len(v); len(seen) >= maxCustomKeys || size > maxCustomBytes {
+			break
+		}
+		seen[name] = struct{}{}
+		ctx = context.WithValue(ctx, ctxKey(name), v)
+		if !slices.Contains(forward, name) && len(forward) < maxCustomKeys {
+			forward = append(forward, name)
+		}
+	}
+	if len(forward) > n {
+		ctx = context.WithValue(ctx, forwardKey{}, slices.Clip(forward))
+	}
+	return extractBaggage(ctx, c)
+}
+
+// propagatedNames 返回需要写入载体的键名:注册的传递键加上Extract记录的、至今仍未注册的键,
+// 之后注册的键按其是否标记Propagate处理
+func propagatedNames(ctx context.Context) []string {
+	names := PropagatedKeys()
+	if forward, ok := ctx.Value(forwardKey{}).([]string); ok {
+		for _, name := range forward {
+			if !isRegistered(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// stringValue 返回键名对应的字符串值,已注册的键兼容使用原始字符串键名写入的值
+func stringValue(ctx context.Context, name string) string {
+	var v any
+	if k, ok := LookupKey(name); ok {
+		v, _ = k.Value(ctx)
+	} else {
+		v = ctx.Value(ctxKey(name))
+	}
+	s, _ := v.(string)
+	return s
+}
+
+func isRegistered(name string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	_, ok := registry.byName[name]
+	return ok
+}
+
+// InjectHTTP 将上下文字段写入发出的HTTP请求头
+func InjectHTTP(ctx context.Context, req *http.Request) {
+	Inject(ctx, HeaderCarrier(req.Header))
+}
+
+// ExtractHTTP 从收到的HTTP请求头中取出上下文字段,返回基于req.Context()的新context
+func ExtractHTTP(req *http.Request) context.Context {
+	return Extract(req.Context(), HeaderCarrier(req.Header))
+}
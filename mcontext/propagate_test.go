@@ -0,0 +1,94 @@
+package mcontext
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	constant "github.com/Cospk/base-tools/utils/constants"
+)
+
+// TestPropagateHTTP 测试通过HTTP头传递上下文字段
+func TestPropagateHTTP(t *testing.T) {
+	ctx := WithMustInfoCtx([]string{"op-1", "u1", "ios", "c1"})
+	ctx = WithTriggerIDContext(ctx, "tr-1")
+	ctx = SetRemoteAddr(ctx, "10.0.0.1")
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	InjectHTTP(ctx, req)
+	if req.Header.Get("operationID") != "op-1" || req.Header.Get("triggerID") != "tr-1" {
+		t.Errorf("请求头缺少上下文字段: %v", req.Header)
+	}
+	if req.Header.Get(constant.RemoteAddr) != "" {
+		t.Error("remoteAddr不应该跨进程传递")
+	}
+
+	in, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	in.Header = req.Header
+	got := ExtractHTTP(in)
+	opID, userID, platform, connID, err := GetMustCtxInfo(got)
+	if err != nil || opID != "op-1" || userID != "u1" || platform != "ios" || connID != "c1" || GetTriggerID(got) != "tr-1" {
+		t.Errorf("取出的上下文字段错误: %s %s %s %s %v", opID, userID, platform, connID, err)
+	}
+}
+
+// TestPropagateCustomKey 测试自定义键通过RpcCustomHeader传递
+func TestPropagateCustomKey(t *testing.T) {
+	tenant := NewKey[string]("test.propagate.tenant", Propagate())
+	md := MetadataCarrier{}
+	Inject(tenant.Set(NewCtx("op-1"), "t1"), md)
+	if md.Get("test.propagate.tenant") != "t1" || md.Get(constant.RpcCustomHeader) == "" {
+		t.Fatalf("metadata缺少自定义键: %v", md)
+	}
+
+	// 对端只通过RpcCustomHeader得知键名
+	remote := MetadataCarrier{
+		"operationid":  {"op-2"},
+		"region":       {"eu"},
+		"secret":       {"not-listed"},
+		"customheader": {"region"},
+	}
+	ctx := Extract(context.Background(), remote)
+	if GetOperationID(ctx) != "op-2" {
+		t.Errorf("期望operationID为op-2, 实际为 %q", GetOperationID(ctx))
+	}
+	// 之后注册的同名键可以读取
+	if region := NewKey[string]("region"); region.Get(ctx) != "eu" {
+		t.Errorf("RpcCustomHeader中列出的键应该被取出: %q", region.Get(ctx))
+	}
+	if ctx.Value(ctxKey("secret")) != nil {
+		t.Error("未列出的键不应该被取出")
+	}
+}
+
+// TestExtractUntrusted 测试对端不能通过RpcCustomHeader设置已注册但不传递的键,且未注册的键有数量限制
+func TestExtractUntrusted(t *testing.T) {
+	local := NewKey[string]("test.extract.local")
+	remote := HeaderCarrier{}
+	remote.Set(constant.RpcCustomHeader, "test.extract.local,remoteAddr,test.extract.custom")
+	remote.Set("test.extract.local", "victim")
+	remote.Set(constant.RemoteAddr, "6.6.6.6")
+	remote.Set("test.extract.custom", "v")
+	ctx := Extract(context.Background(), remote)
+	if local.Get(ctx) != "" || GetRemoteAddr(ctx) != "" {
+		t.Errorf("已注册但不传递的键不应该被取出: %q %q", local.Get(ctx), GetRemoteAddr(ctx))
+	}
+	if ctx.Value(ctxKey("test.extract.custom")) != "v" {
+		t.Error("未注册的键应该被取出")
+	}
+
+	remote = HeaderCarrier{}
+	var names []string
+	for i := 0; i < maxCustomKeys+10; i++ {
+		name := fmt.Sprintf("test.extract.n%d", i)
+		names = append(names, name)
+		remote.Set(name, "v")
+	}
+	remote.Set(constant.RpcCustomHeader, strings.Join(names, ","))
+	ctx = Extract(context.Background(), remote)
+	if n := len(ctx.Value(forwardKey{}).([]string)); n != maxCustomKeys {
+		t.Errorf("未注册的键最多取出 %d 个, 实际为 %d", maxCustomKeys, n)
+	}
+}
@@ -0,0 +1,80 @@
+package mw
+
+import (
+	"context"
+	"net"
+
+	"github.com/Cospk/base-tools/mcontext"
+	"github.com/Cospk/base-tools/utils/idutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// GrpcServerContext 从incoming metadata中取出mcontext字段,缺少operationID时生成,并按peer地址设置remoteAddr
+func GrpcServerContext(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = mcontext.Extract(ctx, mcontext.MetadataCarrier(md))
+	}
+	if mcontext.GetOperationID(ctx) == "" {
+		ctx = mcontext.SetOperationID(ctx, idutil.OperationIDGenerator())
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			ip = p.Addr.String()
+		}
+		ctx = mcontext.SetRemoteAddr(ctx, ip)
+	}
+	return ctx
+}
+
+// GrpcClientContext 将mcontext字段写入outgoing metadata,保留已有的metadata
+func GrpcClientContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	mcontext.Inject(ctx, mcontext.MetadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// UnaryServerInterceptor 服务端一元拦截器,见GrpcServerContext
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(GrpcServerContext(ctx), req)
+	}
+}
+
+// StreamServerInterceptor 服务端流拦截器,见GrpcServerContext
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: GrpcServerContext(ss.Context())})
+	}
+}
+
+// UnaryClientInterceptor 客户端一元拦截器,见GrpcClientContext
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(GrpcClientContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor 客户端流拦截器,见GrpcClientContext
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(GrpcClientContext(ctx), desc, cc, method, opts...)
+	}
+}
+
+// serverStream 替换了context的grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
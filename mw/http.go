@@ -0,0 +1,46 @@
+// Package mw 提供HTTP和gRPC服务端/客户端中间件,负责在进程之间传递mcontext中的操作ID、用户ID等上下文字段
+package mw
+
+import (
+	"net/http"
+
+	"github.com/Cospk/base-tools/mcontext"
+	constant "github.com/Cospk/base-tools/utils/constants"
+	"github.com/Cospk/base-tools/utils/idutil"
+	"github.com/Cospk/base-tools/utils/network"
+)
+
+// HTTPContext 服务端HTTP中间件:从请求头取出mcontext字段,缺少operationID时用idutil.OperationIDGenerator生成,
+// 用network.RemoteIP设置remoteAddr,并在响应头中返回operationID便于排查
+func HTTPContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := mcontext.ExtractHTTP(r)
+		operationID := mcontext.GetOperationID(ctx)
+		if operationID == "" {
+			operationID = idutil.OperationIDGenerator()
+			ctx = mcontext.SetOperationID(ctx, operationID)
+		}
+		ctx = mcontext.SetRemoteAddr(ctx, network.RemoteIP(r))
+		w.Header().Set(constant.OperationID, operationID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// HTTPTransport 客户端http.RoundTripper,将请求context中的mcontext字段写入请求头。base为nil时使用http.DefaultTransport
+func HTTPTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// RoundTripper不应修改原请求
+		req = req.Clone(req.Context())
+		mcontext.InjectHTTP(req.Context(), req)
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,103 @@
+package mw
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Cospk/base-tools/mcontext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// TestHTTP 测试客户端写入请求头、服务端取出上下文字段
+func TestHTTP(t *testing.T) {
+	var got context.Context
+	srv := httptest.NewServer(HTTPContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Context()
+	})))
+	defer srv.Close()
+	client := &http.Client{Transport: HTTPTransport(nil)}
+
+	ctx := mcontext.WithMustInfoCtx([]string{"op-1", "u1", "ios", "c1"})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Header.Get("operationID") != "" {
+		t.Error("不应该修改原请求")
+	}
+	opID, userID, platform, connID, err := mcontext.GetMustCtxInfo(got)
+	if err != nil || opID != "op-1" || userID != "u1" || platform != "ios" || connID != "c1" {
+		t.Errorf("服务端取出的上下文字段错误: %s %s %s %s %v", opID, userID, platform, connID, err)
+	}
+	if mcontext.GetRemoteAddr(got) != "127.0.0.1" || resp.Header.Get("operationID") != "op-1" {
+		t.Errorf("remoteAddr或响应头错误: %q %q", mcontext.GetRemoteAddr(got), resp.Header.Get("operationID"))
+	}
+
+	// 没有operationID时生成
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("X-Real-IP", "10.1.1.1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if opID := mcontext.GetOperationID(got); opID == "" || resp.Header.Get("operationID") != opID {
+		t.Errorf("应该生成operationID并写入响应头: %q", opID)
+	}
+	if mcontext.GetRemoteAddr(got) != "10.1.1.1" {
+		t.Errorf("remoteAddr应该取X-Real-IP, 实际为 %q", mcontext.GetRemoteAddr(got))
+	}
+}
+
+// TestGrpc 测试gRPC客户端和服务端拦截器
+func TestGrpc(t *testing.T) {
+	ctx := mcontext.WithMustInfoCtx([]string{"op-1", "u1", "ios"})
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-custom", "keep")
+
+	var outgoing metadata.MD
+	err := UnaryClientInterceptor()(ctx, "/svc/Method", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			outgoing, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outgoing.Get("operationid")[0] != "op-1" || outgoing.Get("x-custom")[0] != "keep" {
+		t.Fatalf("outgoing metadata错误: %v", outgoing)
+	}
+
+	serverCtx := metadata.NewIncomingContext(context.Background(), outgoing)
+	serverCtx = peer.NewContext(serverCtx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5000}})
+	var got context.Context
+	_, err = UnaryServerInterceptor()(serverCtx, nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req any) (any, error) {
+			got = ctx
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, _, err := mcontext.GetMustCtxInfo(got); err != nil || mcontext.GetOpUserID(got) != "u1" {
+		t.Errorf("服务端取出的上下文字段错误: %v", err)
+	}
+	if mcontext.GetRemoteAddr(got) != "10.0.0.2" {
+		t.Errorf("remoteAddr应该取peer地址, 实际为 %q", mcontext.GetRemoteAddr(got))
+	}
+
+	_, _ = UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req any) (any, error) {
+			got = ctx
+			return nil, nil
+		})
+	if mcontext.GetOperationID(got) == "" {
+		t.Error("没有operationID时应该生成")
+	}
+}
@@ -0,0 +1,312 @@
+// Package breaker 提供熔断器和并发隔离（bulkhead），保护调用方在下游依赖变慢或故障时不被拖垮。
+// 可以单独使用，也可以通过 httputil.CircuitBreaker 和 httputil.Bulkhead 作为 HTTP 客户端中间件使用。
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/log"
+)
+
+// State 熔断器状态
+type State int
+
+const (
+	// StateClosed 正常放行请求，统计失败率
+	StateClosed State = iota
+	// StateOpen 拒绝所有请求，OpenTimeout后进入半开状态
+	StateOpen
+	// StateHalfOpen 放行少量探测请求，全部成功则关闭，任一失败则重新打开
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// StateChangeFunc 状态变化回调
+type StateChangeFunc func(name string, from, to State)
+
+// Option Breaker的配置选项
+type Option func(*Breaker)
+
+// WithWindow 设置关闭状态下的统计窗口,每个窗口重新计数,默认10s,非正数时使用默认值
+func WithWindow(d time.Duration) Option {
+	return func(b *Breaker) {
+		b.window = d
+	}
+}
+
+// WithMinRequests 设置窗口内至少有多少个请求才判断失败率,默认20
+func WithMinRequests(n int) Option {
+	return func(b *Breaker) {
+		b.minRequests = n
+	}
+}
+
+// WithFailureRatio 设置打开熔断器的失败率,取值(0, 1],默认0.5,超出范围时使用默认值
+func WithFailureRatio(ratio float64) Option {
+	return func(b *Breaker) {
+		b.failureRatio = ratio
+	}
+}
+
+// WithOpenTimeout 设置打开状态持续多久后进入半开状态,默认30s,非正数时使用默认值
+func WithOpenTimeout(d time.Duration) Option {
+	return func(b *Breaker) {
+		b.openTimeout = d
+	}
+}
+
+// WithHalfOpenRequests 设置半开状态下放行的探测请求数,这些请求全部成功后关闭熔断器,默认1
+func WithHalfOpenRequests(n int) Option {
+	return func(b *Breaker) {
+		b.halfOpenRequests = n
+	}
+}
+
+// WithIsFailure 设置判断结果是否计为失败的函数,默认见IsFailure。
+// 如参数错误等调用方自身的问题不应计为下游故障
+func WithIsFailure(fn func(err error) bool) Option {
+	return func(b *Breaker) {
+		b.isFailure = fn
+	}
+}
+
+// WithOnStateChange 设置状态变化回调,默认通过log.ZInfo记录,nil表示不回调。
+// 回调在锁外同步执行
+func WithOnStateChange(fn StateChangeFunc) Option {
+	return func(b *Breaker) {
+		b.onStateChange = fn
+	}
+}
+
+// IsFailure 默认的失败判断:err不为nil,且不是context.Canceled或context.DeadlineExceeded。
+// 调用方取消或超时不代表下游故障,这样的结果被忽略,需要将超时计为失败时通过WithIsFailure自定义
+func IsFailure(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Breaker 熔断器,并发安全:
+//
+//	b := breaker.New("user-service", breaker.WithFailureRatio(0.3))
+//	err := b.Execute(func() error {
+//		return callUserService(ctx)
+//	})
+//	if errs.ErrCircuitOpen.Is(err) {
+//		// 熔断中,走降级逻辑
+//	}
+type Breaker struct {
+	name             string
+	window           time.Duration
+	minRequests      int
+	failureRatio     float64
+	openTimeout      time.Duration
+	halfOpenRequests int
+	isFailure        func(err error) bool
+	onStateChange    StateChangeFunc
+	now              func() time.Time
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	expiry     time.Time // 关闭状态为窗口结束时间,打开状态为进入半开的时间
+	requests   int
+	failures   int
+	successes  int
+}
+
+// New 创建熔断器,name用于错误信息和状态变化回调
+func New(name string, opts ...Option) *Breaker {
+	b := &Breaker{
+		name:             name,
+		window:           10 * time.Second,
+		minRequests:      20,
+		failureRatio:     0.5,
+		openTimeout:      30 * time.Second,
+		halfOpenRequests: 1,
+		isFailure:        IsFailure,
+		onStateChange:    logStateChange,
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.minRequests = max(b.minRequests, 1)
+	b.halfOpenRequests = max(b.halfOpenRequests, 1)
+	if b.window <= 0 {
+		b.window = 10 * time.Second
+	}
+	if b.openTimeout <= 0 {
+		b.openTimeout = 30 * time.Second
+	}
+	if b.failureRatio <= 0 || b.failureRatio > 1 {
+		b.failureRatio = 0.5
+	}
+	if b.isFailure == nil {
+		b.isFailure = IsFailure
+	}
+	b.expiry = b.now().Add(b.window)
+	return b
+}
+
+func logStateChange(name string, from, to State) {
+	log.ZInfo(context.Background(), "circuit breaker state changed", "name", name, "from", from.String(), "to", to.String())
+}
+
+// Name 返回熔断器名称
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State 返回当前状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state, changed := b.currentState(b.now())
+	b.mu.Unlock()
+	b.notify(changed)
+	return state
+}
+
+// Allow 判断是否放行请求,放行时返回的done必须在请求结束后以请求的结果调用一次,
+// 熔断器打开时返回errs.ErrCircuitOpen。不计为失败的context.Canceled和context.DeadlineExceeded
+// 既不计为成功也不计入请求数,半开状态下会释放探测名额,不会因此关闭熔断器
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	state, changed := b.currentState(b.now())
+	if state == StateOpen || (state == StateHalfOpen && b.requests >= b.halfOpenRequests) {
+		b.mu.Unlock()
+		b.notify(changed)
+		return nil, errs.ErrCircuitOpen.WrapMsg("circuit breaker is open", "name", b.name, "state", state.String())
+	}
+	b.requests++
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(changed)
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, b.outcome(err))
+		})
+	}, nil
+}
+
+// Execute 在熔断器保护下执行fn,熔断器打开时不执行fn并返回errs.ErrCircuitOpen
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(err)
+	return err
+}
+
+// outcome 请求结果的分类
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeIgnored // 调用方取消或超时,无法判断下游是否正常
+)
+
+func (b *Breaker) outcome(err error) outcome {
+	switch {
+	case b.isFailure(err):
+		return outcomeFailure
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return outcomeIgnored
+	}
+	return outcomeSuccess
+}
+
+func (b *Breaker) done(generation uint64, result outcome) {
+	b.mu.Lock()
+	now := b.now()
+	state, changed := b.currentState(now)
+	// 状态已经变化,忽略之前放行的请求的结果
+	if generation != b.generation {
+		b.mu.Unlock()
+		b.notify(changed)
+		return
+	}
+	switch {
+	case result == outcomeIgnored:
+		b.requests--
+	case state == StateClosed:
+		if result == outcomeFailure {
+			b.failures++
+			if b.requests >= b.minRequests && float64(b.failures)/float64(b.requests) >= b.failureRatio {
+				changed = append(changed, b.setState(StateOpen, now))
+			}
+		}
+	case state == StateHalfOpen:
+		if result == outcomeFailure {
+			changed = append(changed, b.setState(StateOpen, now))
+		} else if b.successes++; b.successes >= b.halfOpenRequests {
+			changed = append(changed, b.setState(StateClosed, now))
+		}
+	}
+	b.mu.Unlock()
+	b.notify(changed)
+}
+
+// currentState 处理窗口和打开超时到期,返回当前状态和发生的状态变化,调用方需持有锁
+func (b *Breaker) currentState(now time.Time) (State, [][2]State) {
+	var changed [][2]State
+	switch b.state {
+	case StateClosed:
+		if !now.Before(b.expiry) {
+			b.reset(now.Add(b.window))
+		}
+	case StateOpen:
+		if !now.Before(b.expiry) {
+			changed = append(changed, b.setState(StateHalfOpen, now))
+		}
+	}
+	return b.state, changed
+}
+
+func (b *Breaker) setState(state State, now time.Time) [2]State {
+	from := b.state
+	b.state = state
+	switch state {
+	case StateClosed:
+		b.reset(now.Add(b.window))
+	case StateOpen:
+		b.reset(now.Add(b.openTimeout))
+	case StateHalfOpen:
+		b.reset(time.Time{})
+	}
+	return [2]State{from, state}
+}
+
+// reset 开始新的计数周期,之前放行的请求的结果不再计入
+func (b *Breaker) reset(expiry time.Time) {
+	b.generation++
+	b.expiry = expiry
+	b.requests, b.failures, b.successes = 0, 0, 0
+}
+
+func (b *Breaker) notify(changed [][2]State) {
+	if b.onStateChange == nil {
+		return
+	}
+	for _, c := range changed {
+		b.onStateChange(b.name, c[0], c[1])
+	}
+}
@@ -0,0 +1,222 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+var errDownstream = errors.New("downstream error")
+
+// testClock 可手动推进的时钟
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(opts ...Option) (*Breaker, *testClock, *[]string) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	var changes []string
+	opts = append([]Option{
+		WithMinRequests(4),
+		WithFailureRatio(0.5),
+		WithOpenTimeout(time.Second),
+		WithOnStateChange(func(name string, from, to State) {
+			changes = append(changes, from.String()+"->"+to.String())
+		}),
+	}, opts...)
+	b := New("test", opts...)
+	b.now = clock.Now
+	b.expiry = clock.Now().Add(b.window)
+	return b, clock, &changes
+}
+
+func TestBreaker_States(t *testing.T) {
+	b, clock, changes := newTestBreaker(WithHalfOpenRequests(2))
+
+	// 请求数未达到MinRequests时不打开
+	for i := 0; i < 3; i++ {
+		b.Execute(func() error { return errDownstream })
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("请求数不足时不应该打开, 实际为 %s", b.State())
+	}
+	b.Execute(func() error { return errDownstream })
+	if b.State() != StateOpen {
+		t.Fatalf("失败率达到阈值时应该打开, 实际为 %s", b.State())
+	}
+
+	called := false
+	err := b.Execute(func() error { called = true; return nil })
+	if called || !errs.ErrCircuitOpen.Is(err) {
+		t.Fatalf("打开状态应该拒绝请求并返回ErrCircuitOpen: %v", err)
+	}
+
+	// 超时后进入半开状态,只放行HalfOpenRequests个探测请求
+	clock.Add(time.Second)
+	done1, err1 := b.Allow()
+	done2, err2 := b.Allow()
+	_, err3 := b.Allow()
+	if err1 != nil || err2 != nil || err3 == nil {
+		t.Fatalf("半开状态应该只放行2个请求: %v %v %v", err1, err2, err3)
+	}
+	done1(nil)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("探测请求未全部成功时应该保持半开, 实际为 %s", b.State())
+	}
+	done2(nil)
+	done2(errDownstream) // 重复调用无效
+	if b.State() != StateClosed {
+		t.Fatalf("探测请求全部成功后应该关闭, 实际为 %s", b.State())
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if len(*changes) != len(want) {
+		t.Fatalf("状态变化回调错误: %v", *changes)
+	}
+	for i := range want {
+		if (*changes)[i] != want[i] {
+			t.Errorf("状态变化回调错误: %v", *changes)
+		}
+	}
+}
+
+func TestBreaker_HalfOpenFailure(t *testing.T) {
+	b, clock, _ := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		b.Execute(func() error { return errDownstream })
+	}
+	clock.Add(time.Second)
+	if err := b.Execute(func() error { return errDownstream }); !errors.Is(err, errDownstream) {
+		t.Fatalf("半开状态应该放行探测请求: %v", err)
+	}
+	if b.State() != StateOpen {
+		t.Errorf("探测请求失败后应该重新打开, 实际为 %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenIgnored(t *testing.T) {
+	b, clock, _ := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		b.Execute(func() error { return errDownstream })
+	}
+	// 探测请求被调用方取消,不能证明下游已经恢复,释放探测名额后保持半开
+	clock.Add(time.Second)
+	b.Execute(func() error { return context.Canceled })
+	if b.State() != StateHalfOpen {
+		t.Fatalf("被取消的探测请求不应该关闭熔断器, 实际为 %s", b.State())
+	}
+	if err := b.Execute(func() error { return nil }); err != nil {
+		t.Fatalf("取消的探测请求应该释放名额: %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("探测请求成功后应该关闭, 实际为 %s", b.State())
+	}
+}
+
+func TestBreaker_Window(t *testing.T) {
+	b, clock, _ := newTestBreaker(WithWindow(time.Second), WithIsFailure(func(err error) bool {
+		return err != nil && !errs.ErrArgs.Is(err)
+	}))
+	for i := 0; i < 3; i++ {
+		b.Execute(func() error { return errDownstream })
+	}
+	// 新窗口重新计数
+	clock.Add(time.Second)
+	b.Execute(func() error { return errDownstream })
+	if b.State() != StateClosed {
+		t.Errorf("窗口过期后应该重新计数, 实际为 %s", b.State())
+	}
+	// 不计为失败的错误
+	for i := 0; i < 4; i++ {
+		b.Execute(func() error { return errs.ErrArgs.WrapMsg("bad request") })
+	}
+	if b.State() != StateClosed {
+		t.Errorf("IsFailure返回false的错误不应该计为失败, 实际为 %s", b.State())
+	}
+}
+
+func TestBreaker_Defaults(t *testing.T) {
+	b, clock, _ := newTestBreaker(WithWindow(0), WithOpenTimeout(-1), WithFailureRatio(0))
+	if b.window != 10*time.Second || b.openTimeout != 30*time.Second || b.failureRatio != 0.5 {
+		t.Fatalf("非正数的配置应该使用默认值: %v %v %v", b.window, b.openTimeout, b.failureRatio)
+	}
+	// 调用方取消或超时不计为失败
+	for _, err := range []error{context.Canceled, errs.WrapMsg(context.DeadlineExceeded, "timeout"), nil, nil} {
+		clock.Add(time.Millisecond)
+		b.Execute(func() error { return err })
+	}
+	if b.State() != StateClosed {
+		t.Errorf("context的错误不应该计为失败, 实际为 %s", b.State())
+	}
+}
+
+func TestBreaker_Concurrent(t *testing.T) {
+	b := New("concurrent", WithOnStateChange(nil), WithMinRequests(10))
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b.Execute(func() error {
+				if i%2 == 0 {
+					return errDownstream
+				}
+				return nil
+			})
+			b.State()
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestBulkhead(t *testing.T) {
+	bh := NewBulkhead("test", 2, 0)
+	ctx := context.Background()
+	r1, _ := bh.Acquire(ctx)
+	r2, _ := bh.Acquire(ctx)
+	if _, err := bh.Acquire(ctx); !errs.ErrBulkheadFull.Is(err) || bh.InUse() != 2 {
+		t.Fatalf("并发数已满时应该返回ErrBulkheadFull: %v", err)
+	}
+	r1()
+	r1() // 重复调用无效
+	if bh.InUse() != 1 {
+		t.Errorf("释放后InUse应该为1, 实际为 %d", bh.InUse())
+	}
+	r2()
+
+	// 等待名额
+	bh = NewBulkhead("wait", 1, time.Second)
+	release, _ := bh.Acquire(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	if err := bh.Execute(ctx, func() error { return nil }); err != nil {
+		t.Errorf("等待期间释放名额后应该执行成功: %v", err)
+	}
+
+	release, _ = bh.Acquire(ctx)
+	defer release()
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := bh.Execute(cancelCtx, func() error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx结束时应该返回ctx的错误: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// Bulkhead 限制对同一依赖的并发调用数,避免一个变慢的依赖占满调用方的goroutine和连接,并发安全:
+//
+//	bh := breaker.NewBulkhead("user-service", 50, 100*time.Millisecond)
+//	err := bh.Execute(ctx, func() error {
+//		return callUserService(ctx)
+//	})
+type Bulkhead struct {
+	name    string
+	sem     chan struct{}
+	maxWait time.Duration
+}
+
+// NewBulkhead 创建Bulkhead,maxConcurrent为最大并发数,maxWait为并发数已满时最多等待的时间,0表示不等待
+func NewBulkhead(name string, maxConcurrent int, maxWait time.Duration) *Bulkhead {
+	return &Bulkhead{
+		name:    name,
+		sem:     make(chan struct{}, max(maxConcurrent, 1)),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire 获取一个并发名额,成功时返回的release必须在调用结束后调用,重复调用无效。
+// 等待maxWait后仍没有名额时返回errs.ErrBulkheadFull,ctx结束时返回ctx的错误
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case b.sem <- struct{}{}:
+		return sync.OnceFunc(b.release), nil
+	default:
+	}
+	if b.maxWait <= 0 {
+		return nil, b.fullError()
+	}
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+	select {
+	case b.sem <- struct{}{}:
+		return sync.OnceFunc(b.release), nil
+	case <-timer.C:
+		return nil, b.fullError()
+	case <-ctx.Done():
+		return nil, errs.WrapMsg(ctx.Err(), "bulkhead wait canceled", "name", b.name)
+	}
+}
+
+// Execute 获取并发名额后执行fn,没有名额时不执行fn并返回errs.ErrBulkheadFull
+func (b *Bulkhead) Execute(ctx context.Context, fn func() error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// InUse 返回当前正在执行的调用数
+func (b *Bulkhead) InUse() int {
+	return len(b.sem)
+}
+
+// Name 返回名称
+func (b *Bulkhead) Name() string {
+	return b.name
+}
+
+func (b *Bulkhead) release() {
+	<-b.sem
+}
+
+func (b *Bulkhead) fullError() error {
+	return errs.ErrBulkheadFull.WrapMsg("bulkhead is full", "name", b.name, "maxConcurrent", cap(b.sem))
+}
@@ -10,4 +10,5 @@ const (
 	CheckKey        = "CheckKey"
 	TriggerID       = "triggerID"
 	RemoteAddr      = "remoteAddr"
+	Baggage         = "baggage" // W3C Baggage请求头
 )
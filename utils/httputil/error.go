@@ -0,0 +1,62 @@
+package httputil
+
+import (
+	"net/http"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// maxErrorBody StatusError.Error 中最多包含的响应体字节数。
+const maxErrorBody = 256
+
+// StatusError 表示响应状态码不是 2xx，可以通过 errors.As 取出响应，
+// errs.Unwrap 返回 StatusCodeError 对应的 errs 错误码。
+type StatusError struct {
+	Method   string
+	URL      string
+	Response *Response
+	err      error
+}
+
+func newStatusError(method, url string, resp *Response) *StatusError {
+	body := resp.Body
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return &StatusError{
+		Method:   method,
+		URL:      url,
+		Response: resp,
+		err: StatusCodeError(resp.StatusCode).WrapMsg("unexpected HTTP status",
+			"method", method, "url", url, "status", resp.StatusCode, "body", string(body)),
+	}
+}
+
+func (e *StatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode 返回响应状态码。
+func (e *StatusError) StatusCode() int {
+	return e.Response.StatusCode
+}
+
+// StatusCodeError 将 HTTP 状态码映射为 errs 错误码：400、422 为 ErrArgs，401、403 为 ErrNoPermission，
+// 404 为 ErrRecordNotFound，409 为 ErrDuplicateKey，其它为 ErrInternalServer。
+func StatusCodeError(status int) errs.CodeError {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return errs.ErrArgs
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errs.ErrNoPermission
+	case http.StatusNotFound:
+		return errs.ErrRecordNotFound
+	case http.StatusConflict:
+		return errs.ErrDuplicateKey
+	}
+	return errs.ErrInternalServer
+}
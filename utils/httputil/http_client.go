@@ -4,16 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"github.com/Cospk/base-tools/errs"
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/Cospk/base-tools/errs"
 )
 
 // ClientConfig 定义 HTTP 客户端的配置。
 type ClientConfig struct {
 	Timeout         time.Duration
 	MaxConnsPerHost int
+	// Retry 是 NewRequest 创建的请求默认使用的重试策略，Get、Post 和 PostReturn 不重试。
+	Retry RetryPolicy
+	// Middlewares 是创建客户端时通过 Use 添加的中间件。
+	Middlewares []Middleware
 }
 
 // NewClientConfig 创建默认的客户端配置。
@@ -21,18 +26,21 @@ func NewClientConfig() *ClientConfig {
 	return &ClientConfig{
 		Timeout:         15 * time.Second,
 		MaxConnsPerHost: 100,
+		Retry:           NewRetryPolicy(),
 	}
 }
 
 // HTTPClient 封装 http.Client 并包含额外的配置。
 type HTTPClient struct {
-	client *http.Client
-	config *ClientConfig
+	client      *http.Client
+	config      *ClientConfig
+	base        http.RoundTripper
+	middlewares []Middleware
 }
 
 // NewHTTPClient 使用提供的配置创建新的 HTTPClient。
 func NewHTTPClient(config *ClientConfig) *HTTPClient {
-	return &HTTPClient{
+	c := &HTTPClient{
 		client: &http.Client{
 			Timeout: config.Timeout,
 			Transport: &http.Transport{
@@ -41,14 +49,35 @@ func NewHTTPClient(config *ClientConfig) *HTTPClient {
 		},
 		config: config,
 	}
+	return c.Use(config.Middlewares...)
 }
 
 // NewHTTPClientWithClient 使用提供的配置和 http.Client 创建新的 HTTPClient。
 func NewHTTPClientWithClient(client *http.Client, config *ClientConfig) *HTTPClient {
-	return &HTTPClient{
+	c := &HTTPClient{
 		client: client,
 		config: config,
 	}
+	if config != nil {
+		c.Use(config.Middlewares...)
+	}
+	return c
+}
+
+// Use 追加中间件，所有请求（包括 Get、Post）都会经过中间件，先添加的先执行。
+// 应在发送请求前调用，不会修改 NewHTTPClientWithClient 传入的 http.Client。
+func (c *HTTPClient) Use(middlewares ...Middleware) *HTTPClient {
+	if len(middlewares) == 0 {
+		return c
+	}
+	if c.middlewares == nil {
+		c.base = c.client.Transport
+	}
+	c.middlewares = append(c.middlewares, middlewares...)
+	client := *c.client
+	client.Transport = Chain(c.base, c.middlewares...)
+	c.client = &client
+	return c
 }
 
 // Get 执行 HTTP GET 请求并返回响应体。
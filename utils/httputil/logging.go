@@ -0,0 +1,193 @@
+package httputil
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/log"
+)
+
+const redacted = "***"
+
+// debugEnabled 判断是否输出调试日志，测试时可以替换。
+var debugEnabled = log.DebugEnabled
+
+// LogOption 定义 Logging 中间件的配置选项。
+type LogOption func(*logOptions)
+
+type logOptions struct {
+	bodyLimit     int
+	redactHeaders map[string]struct{}
+	redactFields  []string
+}
+
+// WithLogBodyLimit 设置日志中请求体和响应体的最大字节数，默认 1024，0 表示不记录请求体和响应体。
+func WithLogBodyLimit(n int) LogOption {
+	return func(o *logOptions) {
+		o.bodyLimit = n
+	}
+}
+
+// WithRedactHeaders 追加需要脱敏的请求头和响应头，默认为 Authorization、Proxy-Authorization、Cookie、Set-Cookie 和 X-Api-Key。
+func WithRedactHeaders(names ...string) LogOption {
+	return func(o *logOptions) {
+		for _, name := range names {
+			o.redactHeaders[http.CanonicalHeaderKey(name)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactFields 追加需要脱敏的字段，作用于 URL 查询参数、表单和 JSON 请求体/响应体，默认为 password、token 和 secret。
+func WithRedactFields(names ...string) LogOption {
+	return func(o *logOptions) {
+		o.redactFields = append(o.redactFields, names...)
+	}
+}
+
+// Logging 返回通过 log.ZDebug 记录请求和响应的中间件，请求头、查询参数和请求体中的敏感信息会被替换为 "***"。
+// 请求体和响应体只读取前 bodyLimit 个字节用于日志，不影响实际发送和读取的内容，SSE 响应不读取响应体。
+// 未开启调试日志时直接发送请求，不做任何额外处理。
+func Logging(opts ...LogOption) Middleware {
+	o := &logOptions{
+		bodyLimit: 1024,
+		redactHeaders: map[string]struct{}{
+			"Authorization":       {},
+			"Proxy-Authorization": {},
+			"Cookie":              {},
+			"Set-Cookie":          {},
+			"X-Api-Key":           {},
+		},
+		redactFields: []string{"password", "token", "secret"},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	fieldRe := jsonFieldRegexp(o.redactFields)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !debugEnabled() {
+				return next.RoundTrip(req)
+			}
+			ctx := req.Context()
+			kv := []any{"method", req.Method, "url", o.redactURL(req.URL), "header", o.redactHeader(req.Header)}
+			if o.bodyLimit > 0 && req.Body != nil && req.Body != http.NoBody {
+				var body []byte
+				body, req = peekRequestBody(req, o.bodyLimit)
+				kv = append(kv, "body", o.redactBody(req.Header.Get("Content-Type"), body, fieldRe))
+			}
+			log.ZDebug(ctx, "HTTP request", kv...)
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			kv = []any{"method", req.Method, "url", o.redactURL(req.URL), "cost", time.Since(start)}
+			if err != nil {
+				log.ZDebug(ctx, "HTTP request failed", append(kv, "error", err.Error())...)
+				return resp, err
+			}
+			kv = append(kv, "status", resp.StatusCode, "header", o.redactHeader(resp.Header))
+			if o.bodyLimit > 0 && resp.Body != nil && !isEventStream(resp.Header) {
+				var body []byte
+				body, resp.Body = peekBody(resp.Body, o.bodyLimit)
+				kv = append(kv, "body", o.redactBody(resp.Header.Get("Content-Type"), body, fieldRe))
+			}
+			log.ZDebug(ctx, "HTTP response", kv...)
+			return resp, nil
+		})
+	}
+}
+
+// isEventStream 判断是否为 SSE 响应，读取其响应体会一直阻塞到服务端发送足够的数据。
+func isEventStream(h http.Header) bool {
+	return strings.HasPrefix(h.Get("Content-Type"), "text/event-stream")
+}
+
+// peekRequestBody 读取请求体的前 limit 个字节，有 GetBody 时读取副本，否则返回替换了 Body 的请求副本。
+func peekRequestBody(req *http.Request, limit int) ([]byte, *http.Request) {
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			defer rc.Close()
+			body, _ := io.ReadAll(io.LimitReader(rc, int64(limit)))
+			return body, req
+		}
+	}
+	req = req.Clone(req.Context())
+	var body []byte
+	body, req.Body = peekBody(req.Body, limit)
+	return body, req
+}
+
+// peekBody 读取 rc 的前 limit 个字节，返回可以完整读取原内容的新 ReadCloser。
+func peekBody(rc io.ReadCloser, limit int) ([]byte, io.ReadCloser) {
+	prefix, _ := io.ReadAll(io.LimitReader(rc, int64(limit)))
+	return prefix, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), rc), rc}
+}
+
+func (o *logOptions) redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for name := range out {
+		if _, ok := o.redactHeaders[name]; ok {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
+
+func (o *logOptions) redactURL(u *url.URL) string {
+	if u.RawQuery == "" || len(o.redactFields) == 0 {
+		return u.String()
+	}
+	c := *u
+	c.RawQuery = o.redactValues(u.RawQuery)
+	return c.String()
+}
+
+// redactValues 替换 URL 编码的键值对中需要脱敏的字段，无法解析时原样返回。
+func (o *logOptions) redactValues(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	changed := false
+	for _, field := range o.redactFields {
+		if _, ok := values[field]; ok {
+			values.Set(field, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return values.Encode()
+}
+
+func (o *logOptions) redactBody(contentType string, body []byte, fieldRe *regexp.Regexp) string {
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		return o.redactValues(string(body))
+	case fieldRe != nil:
+		// 按正则替换，被截断的 JSON 也能脱敏
+		return fieldRe.ReplaceAllString(string(body), `${1}"`+redacted+`"`)
+	}
+	return string(body)
+}
+
+// jsonFieldRegexp 匹配 JSON 中指定字段的字符串、数字等标量值。
+func jsonFieldRegexp(fields []string) *regexp.Regexp {
+	if len(fields) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = regexp.QuoteMeta(f)
+	}
+	return regexp.MustCompile(`("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+}
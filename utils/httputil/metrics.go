@@ -0,0 +1,87 @@
+package httputil
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HostStats 是单个 host 的请求统计。
+type HostStats struct {
+	// Requests 请求总数。
+	Requests int64
+	// Errors 网络错误和 5xx 响应的数量。
+	Errors int64
+	// TotalLatency 所有请求从发送到收到响应头的总耗时。
+	TotalLatency time.Duration
+	// MaxLatency 单个请求的最大耗时。
+	MaxLatency time.Duration
+}
+
+// AvgLatency 返回平均耗时。
+func (s HostStats) AvgLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Requests)
+}
+
+// HostMetrics 按 host 统计请求数、错误数和耗时，可以定期通过 Snapshot 导出到监控系统：
+//
+//	metrics := httputil.NewHostMetrics()
+//	client := httputil.NewHTTPClient(config).Use(metrics.Middleware())
+type HostMetrics struct {
+	mu    sync.Mutex
+	hosts map[string]*HostStats
+}
+
+// NewHostMetrics 创建 HostMetrics。
+func NewHostMetrics() *HostMetrics {
+	return &HostMetrics{hosts: make(map[string]*HostStats)}
+}
+
+// Middleware 返回统计请求的中间件，重试的每次尝试分别计数。
+func (m *HostMetrics) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			m.observe(req.URL.Host, time.Since(start), err != nil || resp.StatusCode >= http.StatusInternalServerError)
+			return resp, err
+		})
+	}
+}
+
+func (m *HostMetrics) observe(host string, latency time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.hosts[host]
+	if !ok {
+		s = &HostStats{}
+		m.hosts[host] = s
+	}
+	s.Requests++
+	if failed {
+		s.Errors++
+	}
+	s.TotalLatency += latency
+	s.MaxLatency = max(s.MaxLatency, latency)
+}
+
+// Snapshot 返回各 host 当前的统计，key 为 host（包括端口）。
+func (m *HostMetrics) Snapshot() map[string]HostStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]HostStats, len(m.hosts))
+	for host, s := range m.hosts {
+		out[host] = *s
+	}
+	return out
+}
+
+// Reset 清空统计，通常在导出 Snapshot 后调用以按周期统计。
+func (m *HostMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hosts = make(map[string]*HostStats)
+}
@@ -0,0 +1,103 @@
+package httputil
+
+import (
+	"net/http"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/mcontext"
+	"github.com/Cospk/base-tools/utils/breaker"
+)
+
+// Middleware 是客户端中间件，包装 http.RoundTripper 以便在请求前后添加鉴权头、日志、指标等处理。
+// 与 http.RoundTripper 的约定相同，中间件不应修改传入的请求，需要修改时先 Clone。
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc 将函数适配为 http.RoundTripper。
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip 实现 http.RoundTripper。
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain 用中间件包装 base，第一个中间件在最外层、最先执行。base 为 nil 时使用 http.DefaultTransport。
+func Chain(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		base = middlewares[i](base)
+	}
+	return base
+}
+
+// SetHeader 返回为每个请求设置请求头的中间件，value 在每次请求时调用，可用于添加会过期的鉴权 token。
+// value 返回空字符串时不设置，返回错误时请求失败。
+func SetHeader(key string, value func(req *http.Request) (string, error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			v, err := value(req)
+			if err != nil {
+				return nil, err
+			}
+			if v != "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(key, v)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// PropagateContext 返回将请求 context 中的 mcontext 字段（operationID、opUserID 等）写入请求头的中间件，
+// 对端可以使用 mw.HTTPContext 取出。
+func PropagateContext() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			mcontext.InjectHTTP(req.Context(), req)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// CircuitBreaker 返回在熔断器保护下发送请求的中间件，网络错误和 5xx 响应计为失败，
+// 请求 context 被取消或超时（包括 Request.Timeout 和 ClientConfig.Timeout）时以 context 的错误结束，
+// 默认既不计为成功也不计为失败，见 breaker.IsFailure。
+// 熔断器打开时不发送请求并返回 errs.ErrCircuitOpen，该错误不会被 DefaultRetryOn 重试。
+func CircuitBreaker(b *breaker.Breaker) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			done, err := b.Allow()
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			switch {
+			case req.Context().Err() != nil:
+				// 请求 context 被取消或超时，无法判断下游是否正常
+				done(req.Context().Err())
+			case err == nil && resp.StatusCode >= http.StatusInternalServerError:
+				done(errs.ErrInternalServer.WrapMsg("unexpected HTTP status", "status", resp.StatusCode))
+			default:
+				done(err)
+			}
+			return resp, err
+		})
+	}
+}
+
+// Bulkhead 返回限制并发请求数的中间件，并发数已满时返回 errs.ErrBulkheadFull。
+// 名额在收到响应头后释放，不包括读取响应体的时间。
+func Bulkhead(b *breaker.Bulkhead) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			release, err := b.Acquire(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			return next.RoundTrip(req)
+		})
+	}
+}
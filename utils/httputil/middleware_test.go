@@ -0,0 +1,243 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+	"github.com/Cospk/base-tools/mcontext"
+	"github.com/Cospk/base-tools/utils/breaker"
+)
+
+func TestHTTPClient_Use(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	raw := &http.Client{}
+	config := NewClientConfig()
+	config.Middlewares = []Middleware{mark("a")}
+	client := NewHTTPClientWithClient(raw, config).Use(mark("b")).Use(mark("c"), SetHeader("Authorization", func(*http.Request) (string, error) {
+		return "Bearer t1", nil
+	}))
+	body, err := client.Get(server.URL)
+	if err != nil || string(body) != "Bearer t1" {
+		t.Fatalf("SetHeader未生效: %q %v", body, err)
+	}
+	if strings.Join(order, ",") != "a,b,c" {
+		t.Errorf("中间件应该按添加顺序执行, 实际为 %v", order)
+	}
+	if raw.Transport != nil {
+		t.Error("不应该修改传入的http.Client")
+	}
+}
+
+func TestPropagateContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mcontext.GetOperationID(mcontext.ExtractHTTP(r))))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(NewClientConfig()).Use(PropagateContext())
+	resp, err := client.NewRequest(http.MethodGet, server.URL).Do(mcontext.NewCtx("op-1"))
+	if err != nil || resp.String() != "op-1" {
+		t.Errorf("operationID应该通过请求头传递: %v %v", resp, err)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	// 日志只读取前几个字节，不影响实际发送和读取的内容
+	client := NewHTTPClient(NewClientConfig()).Use(Logging(WithLogBodyLimit(4)))
+	data := strings.Repeat("x", 100)
+	resp, err := client.NewRequest(http.MethodPost, server.URL).Body([]byte(data), "text/plain").Do(context.Background())
+	if err != nil || resp.String() != data {
+		t.Errorf("请求体或响应体被截断: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader(data)))
+	httpResp, err := client.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	if got, _ := io.ReadAll(httpResp.Body); string(got) != data {
+		t.Errorf("没有GetBody的请求体被截断: %d", len(got))
+	}
+}
+
+func TestLogging_Skip(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var got io.ReadCloser
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Body
+		header := http.Header{"Content-Type": {"text/event-stream"}}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: pr}, nil
+	})
+	body := io.NopCloser(strings.NewReader("data"))
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", body)
+
+	// SSE 响应不读取响应体，否则会一直阻塞
+	resp, err := Chain(next, Logging()).RoundTrip(req)
+	if err != nil || got == body || resp.Body != pr {
+		t.Errorf("开启调试日志时应该读取请求体、不读取 SSE 响应体: %v", err)
+	}
+
+	defer func(old func() bool) { debugEnabled = old }(debugEnabled)
+	debugEnabled = func() bool { return false }
+	req.Body = body
+	resp, err = Chain(next, Logging()).RoundTrip(req)
+	if err != nil || got != body || resp.Body != pr {
+		t.Errorf("未开启调试日志时不应该替换请求体和响应体: %v", err)
+	}
+}
+
+func TestLogging_Redact(t *testing.T) {
+	o := &logOptions{redactHeaders: map[string]struct{}{}}
+	WithRedactHeaders("authorization")(o)
+	WithRedactFields("password", "token")(o)
+	re := jsonFieldRegexp(o.redactFields)
+
+	h := o.redactHeader(http.Header{"Authorization": {"Bearer t"}, "Accept": {"*/*"}})
+	if h.Get("Authorization") != redacted || h.Get("Accept") != "*/*" {
+		t.Errorf("请求头脱敏错误: %v", h)
+	}
+	u, _ := url.Parse("https://example.com/p?token=abc&page=1")
+	if got := o.redactURL(u); got != "https://example.com/p?page=1&token=%2A%2A%2A" {
+		t.Errorf("查询参数脱敏错误: %s", got)
+	}
+	tests := []struct {
+		contentType, body, want string
+	}{
+		{"application/json", `{"user":"u","password":"p\"w","token": 123}`, `{"user":"u","password":"***","token": "***"}`},
+		{"application/json", `{"password":"trunc`, `{"password":"***"`},
+		{"application/x-www-form-urlencoded", "password=p&user=u", "password=%2A%2A%2A&user=u"},
+	}
+	for _, tt := range tests {
+		if got := o.redactBody(tt.contentType, []byte(tt.body), re); got != tt.want {
+			t.Errorf("请求体脱敏错误: %s, 期望 %s", got, tt.want)
+		}
+	}
+}
+
+func TestHostMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	metrics := NewHostMetrics()
+	client := NewHTTPClient(NewClientConfig()).Use(metrics.Middleware())
+	client.Get(server.URL)
+	client.NewRequest(http.MethodGet, server.URL+"/fail").NoRetry().Do(context.Background())
+	client.Get("http://127.0.0.1:1")
+
+	u, _ := url.Parse(server.URL)
+	s := metrics.Snapshot()[u.Host]
+	if s.Requests != 2 || s.Errors != 1 || s.AvgLatency() <= 0 || s.MaxLatency < s.AvgLatency() {
+		t.Errorf("统计错误: %+v", s)
+	}
+	if s := metrics.Snapshot()["127.0.0.1:1"]; s.Requests != 1 || s.Errors != 1 {
+		t.Errorf("网络错误应该计入错误数: %+v", s)
+	}
+	metrics.Reset()
+	if len(metrics.Snapshot()) != 0 {
+		t.Error("Reset后应该没有统计")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls, attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	count := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts.Add(1)
+			return next.RoundTrip(req)
+		})
+	}
+	b := breaker.New("test", breaker.WithMinRequests(2), breaker.WithOnStateChange(nil))
+	config := NewClientConfig()
+	config.Retry.BaseDelay = time.Millisecond
+	config.Retry.MaxRetries = 5
+	client := NewHTTPClient(config).Use(count, CircuitBreaker(b))
+
+	// 两次失败后熔断器打开,第三次尝试返回ErrCircuitOpen且不再重试
+	_, err := client.NewRequest(http.MethodGet, server.URL).Do(context.Background())
+	if !errors.Is(err, errs.ErrCircuitOpen) || calls.Load() != 2 || b.State() != breaker.StateOpen {
+		t.Fatalf("熔断器应该打开并返回ErrCircuitOpen: %v, 请求 %d 次", err, calls.Load())
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("ErrCircuitOpen不应该重试, 实际尝试 %d 次", attempts.Load())
+	}
+	if _, err := client.Get(server.URL); !errors.Is(err, errs.ErrCircuitOpen) || calls.Load() != 2 {
+		t.Errorf("熔断器打开时不应该发送请求: %v", err)
+	}
+}
+
+func TestCircuitBreaker_ContextError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	b := breaker.New("test", breaker.WithMinRequests(1), breaker.WithOnStateChange(nil))
+	client := NewHTTPClient(NewClientConfig()).Use(CircuitBreaker(b))
+	_, err := client.NewRequest(http.MethodGet, server.URL).Timeout(10 * time.Millisecond).NoRetry().Do(context.Background())
+	if err == nil || b.State() != breaker.StateClosed {
+		t.Errorf("请求超时不应该计为失败: %v, 状态 %s", err, b.State())
+	}
+}
+
+func TestBulkhead(t *testing.T) {
+	entered, block := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-block
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(NewClientConfig()).Use(Bulkhead(breaker.NewBulkhead("test", 1, 0)))
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Get(server.URL)
+		result <- err
+	}()
+	<-entered
+	_, err := client.Get(server.URL)
+	close(block)
+	if !errors.Is(err, errs.ErrBulkheadFull) {
+		t.Errorf("并发数已满时应该返回ErrBulkheadFull: %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("第一个请求应该成功: %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// Request 是 HTTP 请求构造器，通过 HTTPClient.NewRequest 创建，方法可以链式调用：
+//
+//	var out Result
+//	resp, err := client.NewRequest(http.MethodGet, "https://api.example.com/users").
+//		Query("page", "1").
+//		Header("Authorization", "Bearer "+token).
+//		Do(ctx)
+//	if err == nil {
+//		err = resp.JSON(&out)
+//	}
+type Request struct {
+	client      *HTTPClient
+	method      string
+	url         string
+	header      http.Header
+	query       url.Values
+	body        []byte
+	contentType string
+	parts       []part
+	timeout     time.Duration
+	retry       *RetryPolicy
+	idempotent  bool
+	err         error
+}
+
+// part 是 multipart 请求体中的一个字段或文件。
+type part struct {
+	field, filename, value string
+	content                io.Reader
+}
+
+// Response 是已读取完响应体的 HTTP 响应。
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Attempts 实际发送请求的次数，包括重试。
+	Attempts int
+}
+
+// JSON 将响应体解码到 v。
+func (r *Response) JSON(v any) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return errs.WrapMsg(err, "JSON unmarshal failed")
+	}
+	return nil
+}
+
+// String 返回响应体字符串。
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// NewRequest 创建请求构造器，method 为 http.MethodGet 等任意方法。
+func (c *HTTPClient) NewRequest(method, rawURL string) *Request {
+	return &Request{
+		client: c,
+		method: strings.ToUpper(method),
+		url:    rawURL,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+// Header 设置请求头。
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Headers 批量设置请求头。
+func (r *Request) Headers(headers map[string]string) *Request {
+	for k, v := range headers {
+		r.header.Set(k, v)
+	}
+	return r
+}
+
+// Query 添加查询参数，与 URL 中已有的参数合并。
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Queries 批量添加查询参数。
+func (r *Request) Queries(values url.Values) *Request {
+	for k, vs := range values {
+		for _, v := range vs {
+			r.query.Add(k, v)
+		}
+	}
+	return r
+}
+
+// Body 设置原始请求体和 Content-Type。
+func (r *Request) Body(body []byte, contentType string) *Request {
+	r.body, r.contentType, r.parts = body, contentType, nil
+	return r
+}
+
+// JSON 将 v 编码为 JSON 请求体。
+func (r *Request) JSON(v any) *Request {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = errs.WrapMsg(err, "JSON encode failed", "url", r.url)
+		return r
+	}
+	return r.Body(data, "application/json; charset=utf-8")
+}
+
+// Form 设置 application/x-www-form-urlencoded 请求体。
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body([]byte(values.Encode()), "application/x-www-form-urlencoded")
+}
+
+// FormField 添加 multipart/form-data 请求体中的普通字段。
+func (r *Request) FormField(field, value string) *Request {
+	r.body = nil
+	r.parts = append(r.parts, part{field: field, value: value})
+	return r
+}
+
+// FormFile 添加 multipart/form-data 请求体中的文件，content 在发送前被完整读取，以便重试时重新发送。
+func (r *Request) FormFile(field, filename string, content io.Reader) *Request {
+	r.body = nil
+	r.parts = append(r.parts, part{field: field, filename: filename, content: content})
+	return r
+}
+
+// Timeout 设置每次尝试的超时时间，默认使用 ClientConfig.Timeout。
+func (r *Request) Timeout(d time.Duration) *Request {
+	r.timeout = d
+	return r
+}
+
+// Retry 设置本次请求的重试策略，默认使用 ClientConfig.Retry。
+func (r *Request) Retry(p RetryPolicy) *Request {
+	r.retry = &p
+	return r
+}
+
+// NoRetry 禁用本次请求的重试。
+func (r *Request) NoRetry() *Request {
+	return r.Retry(RetryPolicy{})
+}
+
+// Idempotent 将请求标记为幂等，POST、PATCH 等请求也会按重试策略重试。
+func (r *Request) Idempotent() *Request {
+	r.idempotent = true
+	return r
+}
+
+// Do 发送请求并读取响应体。失败时按重试策略重试，状态码不是 2xx 时返回 *StatusError，
+// 此时 Response 也会返回，便于读取错误响应体。
+func (r *Request) Do(ctx context.Context) (*Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	target, err := r.buildURL()
+	if err != nil {
+		return nil, err
+	}
+	body, contentType, err := r.buildBody()
+	if err != nil {
+		return nil, err
+	}
+	policy := r.retryPolicy()
+	retryable := r.idempotent || policy.RetryNonIdempotent || isIdempotent(r.method, r.header)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := r.send(ctx, target, body, contentType)
+		if resp != nil {
+			resp.Attempts = attempt + 1
+		}
+		if !retryable || attempt >= policy.MaxRetries || !policy.shouldRetry(ctx, resp, err) {
+			if err != nil {
+				return nil, errs.WrapMsg(err, "HTTP request failed", "method", r.method, "url", target, "attempts", attempt+1)
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return resp, newStatusError(r.method, target, resp)
+			}
+			return resp, nil
+		}
+		if err := sleepCtx(ctx, policy.backoff(attempt, resp)); err != nil {
+			return nil, errs.WrapMsg(err, "HTTP request canceled while waiting to retry", "method", r.method, "url", target, "attempts", attempt+1)
+		}
+	}
+}
+
+// send 发送一次请求，返回的响应和错误只有一个非 nil。
+func (r *Request) send(ctx context.Context, target string, body []byte, contentType string) (*Response, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.header.Clone()
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.client.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: data}, nil
+}
+
+func (r *Request) buildURL() (string, error) {
+	if len(r.query) == 0 {
+		return r.url, nil
+	}
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return "", errs.WrapMsg(err, "invalid URL", "url", r.url)
+	}
+	q := u.Query()
+	for k, vs := range r.query {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// buildBody 返回请求体，multipart 请求体在这里一次性生成，以便重试时重新发送。
+func (r *Request) buildBody() ([]byte, string, error) {
+	if len(r.parts) == 0 {
+		return r.body, r.contentType, nil
+	}
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range r.parts {
+		if p.content == nil {
+			if err := w.WriteField(p.field, p.value); err != nil {
+				return nil, "", errs.WrapMsg(err, "write multipart field failed", "field", p.field)
+			}
+			continue
+		}
+		fw, err := w.CreateFormFile(p.field, p.filename)
+		if err != nil {
+			return nil, "", errs.WrapMsg(err, "create multipart file failed", "field", p.field)
+		}
+		if _, err := io.Copy(fw, p.content); err != nil {
+			return nil, "", errs.WrapMsg(err, "read multipart file failed", "field", p.field, "filename", p.filename)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", errs.WrapMsg(err, "close multipart writer failed")
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func (r *Request) retryPolicy() RetryPolicy {
+	if r.retry != nil {
+		return *r.retry
+	}
+	if r.client.config != nil {
+		return r.client.config.Retry
+	}
+	return RetryPolicy{}
+}
@@ -0,0 +1,192 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+func TestRequest_Build(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query":
+			w.Write([]byte(r.Method + " " + r.URL.RawQuery + " " + r.Header.Get("X-Test")))
+		case "/form":
+			r.ParseForm()
+			w.Write([]byte(r.PostForm.Get("name")))
+		case "/multipart":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("解析multipart失败: %v", err)
+				return
+			}
+			f, h, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("读取文件失败: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(f)
+			w.Write([]byte(r.FormValue("desc") + " " + h.Filename + " " + string(data)))
+		case "/json":
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			io.Copy(w, r.Body)
+		}
+	}))
+	defer server.Close()
+	client := NewHTTPClient(NewClientConfig())
+	ctx := context.Background()
+
+	resp, err := client.NewRequest("delete", server.URL+"/query?a=1").
+		Query("b", "2").Queries(url.Values{"c": {"3"}}).Header("X-Test", "h").Do(ctx)
+	if err != nil || resp.String() != "DELETE a=1&b=2&c=3 h" {
+		t.Errorf("查询参数或请求头错误: %v %v", resp, err)
+	}
+
+	resp, err = client.NewRequest(http.MethodPost, server.URL+"/form").Form(url.Values{"name": {"n"}}).Do(ctx)
+	if err != nil || resp.String() != "n" {
+		t.Errorf("表单请求错误: %v %v", resp, err)
+	}
+
+	resp, err = client.NewRequest(http.MethodPut, server.URL+"/multipart").
+		FormField("desc", "d").FormFile("file", "a.txt", strings.NewReader("content")).Do(ctx)
+	if err != nil || resp.String() != "d a.txt content" {
+		t.Errorf("multipart请求错误: %v %v", resp, err)
+	}
+
+	var out struct{ Key string }
+	resp, err = client.NewRequest(http.MethodPatch, server.URL+"/json").JSON(map[string]string{"key": "v"}).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.JSON(&out); err != nil || out.Key != "v" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Errorf("JSON请求错误: %+v %v", out, err)
+	}
+
+	if _, err := client.NewRequest(http.MethodPost, server.URL).JSON(make(chan int)).Do(ctx); err == nil {
+		t.Error("JSON编码失败时应该返回错误")
+	}
+}
+
+func TestRequest_StatusError(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, errs.ErrArgs},
+		{http.StatusForbidden, errs.ErrNoPermission},
+		{http.StatusNotFound, errs.ErrRecordNotFound},
+		{http.StatusConflict, errs.ErrDuplicateKey},
+		{http.StatusTeapot, errs.ErrInternalServer},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte("error body"))
+		}))
+		resp, err := NewHTTPClient(NewClientConfig()).NewRequest(http.MethodGet, server.URL).Do(context.Background())
+		server.Close()
+
+		var se *StatusError
+		if !errors.As(err, &se) || se.StatusCode() != tt.status || string(resp.Body) != "error body" {
+			t.Errorf("状态码 %d 应该返回StatusError和响应: %v", tt.status, err)
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("状态码 %d 的错误码错误: %v", tt.status, err)
+		}
+	}
+}
+
+func TestRequest_Retry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := NewClientConfig()
+	config.Retry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	client := NewHTTPClient(config)
+	ctx := context.Background()
+
+	resp, err := client.NewRequest(http.MethodGet, server.URL).Do(ctx)
+	if err != nil || resp.Attempts != 3 || resp.String() != "ok" {
+		t.Fatalf("GET应该重试后成功: %v %v", resp, err)
+	}
+
+	// 非幂等请求默认不重试
+	calls.Store(0)
+	resp, err = client.NewRequest(http.MethodPost, server.URL).Do(ctx)
+	if err == nil || resp.Attempts != 1 {
+		t.Errorf("POST默认不应该重试: %v", err)
+	}
+
+	// 带Idempotency-Key或标记为幂等时重试
+	calls.Store(0)
+	resp, err = client.NewRequest(http.MethodPost, server.URL).Header("Idempotency-Key", "k1").Do(ctx)
+	if err != nil || resp.Attempts != 3 {
+		t.Errorf("带Idempotency-Key的POST应该重试: %v", err)
+	}
+	calls.Store(0)
+	if resp, err = client.NewRequest(http.MethodPost, server.URL).Idempotent().Do(ctx); err != nil || resp.Attempts != 3 {
+		t.Errorf("标记为幂等的POST应该重试: %v", err)
+	}
+
+	// 重试次数用完时返回最后一次的错误
+	calls.Store(0)
+	resp, err = client.NewRequest(http.MethodGet, server.URL).Retry(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}).Do(ctx)
+	if !errors.Is(err, errs.ErrInternalServer) || resp.Attempts != 2 {
+		t.Errorf("重试次数用完时应该返回StatusError: %v", err)
+	}
+	calls.Store(0)
+	if resp, _ = client.NewRequest(http.MethodGet, server.URL).NoRetry().Do(ctx); resp.Attempts != 1 {
+		t.Errorf("NoRetry不应该重试, 实际尝试 %d 次", resp.Attempts)
+	}
+}
+
+func TestRequest_RetryCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewHTTPClient(NewClientConfig()).NewRequest(http.MethodGet, server.URL).Do(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Errorf("等待重试时context结束应该立即返回: %v", err)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		if d := p.backoff(attempt, nil); d < want/2 || d > want {
+			t.Errorf("第 %d 次重试的等待时间 %v 不在 [%v, %v] 内", attempt, d, want/2, want)
+		}
+	}
+	resp := &Response{Header: http.Header{"Retry-After": {"3"}}}
+	if d := p.backoff(0, resp); d != time.Second {
+		t.Errorf("Retry-After应该被MaxDelay限制, 实际为 %v", d)
+	}
+	resp.Header.Set("Retry-After", time.Now().Add(500*time.Millisecond).UTC().Format(http.TimeFormat))
+	if d := p.backoff(0, resp); d > time.Second {
+		t.Errorf("HTTP日期格式的Retry-After解析错误: %v", d)
+	}
+}
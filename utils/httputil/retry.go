@@ -0,0 +1,116 @@
+package httputil
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Cospk/base-tools/errs"
+)
+
+// RetryPolicy 定义请求失败后的重试策略。
+type RetryPolicy struct {
+	// MaxRetries 最大重试次数，0 表示不重试。
+	MaxRetries int
+	// BaseDelay 第一次重试前的等待时间，之后每次翻倍，默认 100ms。
+	BaseDelay time.Duration
+	// MaxDelay 单次等待时间的上限，也限制 Retry-After 指定的时间，默认 10s。
+	MaxDelay time.Duration
+	// RetryNonIdempotent 是否重试 POST、PATCH 等非幂等请求，默认只重试幂等请求。
+	RetryNonIdempotent bool
+	// RetryOn 判断是否需要重试，resp 和 err 只有一个非 nil，默认见 DefaultRetryOn。
+	RetryOn func(resp *Response, err error) bool
+}
+
+// NewRetryPolicy 创建默认的重试策略：最多重试 2 次，等待时间从 100ms 开始指数增长。
+func NewRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  100 * time.Millisecond,
+		MaxDelay:   10 * time.Second,
+	}
+}
+
+// DefaultRetryOn 在网络错误以及 408、429、500、502、503、504 状态码时重试，
+// 熔断器打开（errs.ErrCircuitOpen）和并发数已满（errs.ErrBulkheadFull）时不重试。
+func DefaultRetryOn(resp *Response, err error) bool {
+	if err != nil {
+		return !errs.ErrCircuitOpen.Is(err) && !errs.ErrBulkheadFull.Is(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (p *RetryPolicy) shouldRetry(ctx context.Context, resp *Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if p.RetryOn != nil {
+		return p.RetryOn(resp, err)
+	}
+	return DefaultRetryOn(resp, err)
+}
+
+// backoff 返回第 attempt 次重试（从 0 开始）前的等待时间：优先使用响应的 Retry-After，
+// 否则为 BaseDelay*2^attempt，并在 [d/2, d] 之间随机抖动，避免大量客户端同时重试。
+func (p *RetryPolicy) backoff(attempt int, resp *Response) time.Duration {
+	base, limit := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, limit)
+		}
+	}
+	d := base
+	for i := 0; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式。
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(n, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// isIdempotent 判断请求方法是否幂等，带有 Idempotency-Key 头的请求也视为幂等。
+func isIdempotent(method string, header http.Header) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return header.Get("Idempotency-Key") != ""
+}
+
+// sleepCtx 等待 d，ctx 结束时提前返回 ctx 的错误。
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}